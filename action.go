@@ -2,6 +2,65 @@
 // Implements the action
 package gorule
 
+// ActionType represents types of action
+type ActionType int
+
+const (
+	// AssignActionType represents an action setting a fact ex. tier = "GOLD"
+	AssignActionType ActionType = 1
+)
+
 // Action defining generic actions
 type Action interface {
+	GetType() ActionType
+	Execute(ctx Context) (bool, error)
+}
+
+// AssignAction represents an action which asserts (or modifies) a fact in the
+// working memory
+// Format: path = value
+type AssignAction struct {
+	Type  ActionType `json:"type"`
+	Path  string     `json:"path"`
+	Value Condition  `json:"value"`
+}
+
+// GetType gets the type of action
+func (_a *AssignAction) GetType() ActionType {
+	return _a.Type
+}
+
+// Execute evaluates the value and sets it as a fact in the working memory
+// attached to the context. Returns true if the fact was changed
+func (_a *AssignAction) Execute(ctx Context) (bool, error) {
+	value, err := _a.Value.Evaluate(ctx)
+	if err != nil {
+		return false, err
+	}
+	memory, ok := ctx.GetValue(WorkingMemoryKey).(*WorkingMemory)
+	if !ok {
+		return false, &MissingWorkingMemoryError{}
+	}
+	ctxKey := resolveContextKey(_a.Path, ctx)
+	ctx.SetValue(ctxKey, value)
+	return memory.Set(ctxKey, value), nil
+}
+
+// ActionList represents the actions of a rule executed in the order of definition
+// Format: { ACTION ; ACTION ; ... }
+type ActionList []Action
+
+// executeActions executes all the actions and returns the paths of the changed facts
+func executeActions(actions ActionList, ctx Context) ([]string, error) {
+	var changed []string
+	for _, action := range actions {
+		isChanged, err := action.Execute(ctx)
+		if err != nil {
+			return nil, err
+		}
+		if assignAction, ok := action.(*AssignAction); ok && isChanged {
+			changed = append(changed, resolveContextKey(assignAction.Path, ctx))
+		}
+	}
+	return changed, nil
 }
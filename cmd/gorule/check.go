@@ -0,0 +1,107 @@
+// File: check.go
+// Implements the check command validating rule files
+package main
+
+import (
+	"encoding/json"
+	"flag"
+	"fmt"
+	"io"
+	"os"
+
+	"github.com/praks-1529/gorule"
+)
+
+func runCheck(args []string, stdin io.Reader, stdout io.Writer, stderr io.Writer) int {
+	flags := flag.NewFlagSet("check", flag.ContinueOnError)
+	flags.SetOutput(stderr)
+	jsonOutput := flags.Bool("json", false, "print the diagnostics as a JSON array")
+	schemaFile := flags.String("schema", "", "type check the rules against the JSON Schema of the payload")
+	flags.Usage = func() {
+		fmt.Fprintln(stderr, "Usage: gorule check [-json] [-schema file] files")
+		fmt.Fprintln(stderr, "Parses and lints the rule files, exits with 1 if any error is found")
+		flags.PrintDefaults()
+	}
+	if err := flags.Parse(args); err != nil {
+		return 2
+	}
+	if flags.NArg() == 0 {
+		flags.Usage()
+		return 2
+	}
+	var schema *gorule.Schema
+	if *schemaFile != "" {
+		data, err := os.ReadFile(*schemaFile)
+		if err == nil {
+			schema, err = gorule.ParseSchema(data)
+		}
+		if err != nil {
+			fmt.Fprintf(stderr, "gorule: %s: %s\n", *schemaFile, err)
+			return 1
+		}
+	}
+	diagnostics := []diagnostic{}
+	for _, path := range flags.Args() {
+		diagnostics = append(diagnostics, checkFile(path, schema)...)
+	}
+	if *jsonOutput {
+		encoder := json.NewEncoder(stdout)
+		encoder.SetIndent("", "  ")
+		encoder.Encode(diagnostics)
+	} else {
+		for _, diag := range diagnostics {
+			diag.print(stderr)
+		}
+	}
+	for _, diag := range diagnostics {
+		if diag.Severity == "error" {
+			return 1
+		}
+	}
+	return 0
+}
+
+// checkFile returns the problems found in the rule file. The rules are type
+// checked if the schema is set
+func checkFile(path string, schema *gorule.Schema) []diagnostic {
+	src, err := os.ReadFile(path)
+	if err != nil {
+		return []diagnostic{{File: path, Severity: "error", Message: err.Error()}}
+	}
+	source, err := parseRuleSource(string(src))
+	if err != nil {
+		return []diagnostic{newDiagnostic(path, string(src), err)}
+	}
+	var diagnostics []diagnostic
+	ruleSet := source.rules(path)
+	for _, name := range ruleSet.Names() {
+		rule, _ := ruleSet.Get(name)
+		// Rules the compiler rejects (ex. a FOR without a valid range) fail at runtime
+		if _, err := gorule.CompileRule(rule); err != nil {
+			diagnostics = append(diagnostics, diagnostic{File: path, Severity: "error", Message: fmt.Sprintf("rule %s: %s", name, err)})
+		}
+	}
+	for _, warning := range gorule.LintRuleSet(ruleSet, source.sourceMap) {
+		diagnostics = append(diagnostics, sourceDiagnostic(path, string(src), source, warning.Rule, "warning", warning, warning.Offset))
+	}
+	if schema == nil {
+		return diagnostics
+	}
+	for _, typeErr := range gorule.CheckRuleSetSchema(ruleSet, schema, source.sourceMap) {
+		diagnostics = append(diagnostics, sourceDiagnostic(path, string(src), source, typeErr.Rule, string(typeErr.Severity), typeErr, typeErr.Offset))
+	}
+	return diagnostics
+}
+
+// sourceDiagnostic creates the diagnostic of a problem found in a rule at the
+// offset of the source (-1 if unknown)
+func sourceDiagnostic(path string, src string, source *ruleSource, rule string, severity string, err error, offset int) diagnostic {
+	diag := diagnostic{File: path, Severity: severity, Message: err.Error()}
+	if source.ruleSet != nil {
+		diag.Message = fmt.Sprintf("rule %s: %s", rule, err)
+	}
+	if offset >= 0 {
+		diag.Line, diag.Column, diag.text = sourceLine(src, offset)
+	}
+	return diag
+}
@@ -0,0 +1,85 @@
+// File: cover.go
+// Implements the cover command reporting the coverage of the rules over payloads
+package main
+
+import (
+	"encoding/json"
+	"flag"
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+	"strings"
+
+	"github.com/praks-1529/gorule"
+)
+
+func runCover(args []string, stdin io.Reader, stdout io.Writer, stderr io.Writer) int {
+	flags := flag.NewFlagSet("cover", flag.ContinueOnError)
+	flags.SetOutput(stderr)
+	format := flags.String("format", "text", "output format: text, json or annotated")
+	flags.Usage = func() {
+		fmt.Fprintln(stderr, "Usage: gorule cover [-format text|json|annotated] rules [payloads]")
+		fmt.Fprintln(stderr, "Reports which conditions the payloads (JSON or NDJSON, default stdin) evaluate true, false or never reach")
+		flags.PrintDefaults()
+	}
+	if err := flags.Parse(args); err != nil {
+		return 2
+	}
+	if flags.NArg() < 1 || flags.NArg() > 2 || (*format != "text" && *format != "json" && *format != "annotated") {
+		flags.Usage()
+		return 2
+	}
+	rulesFile := flags.Arg(0)
+	src, source, err := readRuleSource(rulesFile)
+	if err != nil {
+		printSourceError(stderr, rulesFile, src, err)
+		return 1
+	}
+	var input []byte
+	if inputFile := flags.Arg(1); inputFile == "" || inputFile == "-" {
+		input, err = io.ReadAll(stdin)
+	} else {
+		input, err = os.ReadFile(inputFile)
+	}
+	if err != nil {
+		fmt.Fprintln(stderr, "gorule:", err)
+		return 1
+	}
+	documents, err := splitDocuments(input)
+	if err != nil {
+		fmt.Fprintln(stderr, "gorule: input", err)
+		return 1
+	}
+	name := strings.TrimSuffix(filepath.Base(rulesFile), filepath.Ext(rulesFile))
+	coverage := gorule.NewCoverage(source.rules(name))
+	for i, document := range documents {
+		if err = recordCoverage(coverage, document); err != nil {
+			fmt.Fprintf(stderr, "gorule: document %d: %s\n", i+1, err)
+			return 1
+		}
+	}
+	writeCoverage(stdout, coverage.Report(), *format)
+	return 0
+}
+
+// recordCoverage records the document. A panic of the engine is reported as
+// an error
+func recordCoverage(coverage *gorule.Coverage, document []byte) error {
+	return gorule.SafeEvaluate(func() error {
+		return coverage.Record(document)
+	})
+}
+
+func writeCoverage(w io.Writer, report *gorule.CoverageReport, format string) {
+	switch format {
+	case "json":
+		encoder := json.NewEncoder(w)
+		encoder.SetIndent("", "  ")
+		encoder.Encode(report)
+	case "annotated":
+		report.WriteAnnotated(w)
+	default:
+		report.WriteText(w)
+	}
+}
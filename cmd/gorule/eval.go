@@ -0,0 +1,136 @@
+// File: eval.go
+// Implements the eval command evaluating rules against JSON payloads
+package main
+
+import (
+	"encoding/json"
+	"flag"
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+	"strings"
+	"text/tabwriter"
+
+	"github.com/praks-1529/gorule"
+)
+
+// evalResult represents the outcome of evaluating the rules for one document
+type evalResult struct {
+	Document int                    `json:"document"`
+	Winners  []string               `json:"winners,omitempty"`
+	Results  map[string]interface{} `json:"results,omitempty"`
+	// Outputs holds the outputs of the winners having actions
+	Outputs map[string]*gorule.RuleOutput `json:"outputs,omitempty"`
+	Error   string                        `json:"error,omitempty"`
+}
+
+func runEval(args []string, stdin io.Reader, stdout io.Writer, stderr io.Writer) int {
+	flags := flag.NewFlagSet("eval", flag.ContinueOnError)
+	flags.SetOutput(stderr)
+	jsonOutput := flags.Bool("json", false, "print one JSON result per document (NDJSON)")
+	strategyName := flags.String("strategy", "all", "conflict strategy: all, first, priority or action")
+	flags.Usage = func() {
+		fmt.Fprintln(stderr, "Usage: gorule eval [-json] [-strategy name] rules [input]")
+		fmt.Fprintln(stderr, "Evaluates the rules for every document of the input (JSON or NDJSON, default stdin)")
+		flags.PrintDefaults()
+	}
+	if err := flags.Parse(args); err != nil {
+		return 2
+	}
+	strategy, err := gorule.ParseConflictStrategy(*strategyName)
+	if err != nil {
+		fmt.Fprintln(stderr, "gorule:", err)
+		return 2
+	}
+	if flags.NArg() < 1 || flags.NArg() > 2 {
+		flags.Usage()
+		return 2
+	}
+	rulesFile := flags.Arg(0)
+	src, source, err := readRuleSource(rulesFile)
+	if err != nil {
+		printSourceError(stderr, rulesFile, src, err)
+		return 1
+	}
+	var input []byte
+	if inputFile := flags.Arg(1); inputFile == "" || inputFile == "-" {
+		input, err = io.ReadAll(stdin)
+	} else {
+		input, err = os.ReadFile(inputFile)
+	}
+	if err != nil {
+		fmt.Fprintln(stderr, "gorule:", err)
+		return 1
+	}
+	documents, err := splitDocuments(input)
+	if err != nil {
+		fmt.Fprintln(stderr, "gorule: input", err)
+		return 1
+	}
+	name := strings.TrimSuffix(filepath.Base(rulesFile), filepath.Ext(rulesFile))
+	ruleSet := source.rules(name)
+	engine := gorule.NewRuleEngine()
+	encoder := json.NewEncoder(stdout)
+	exitCode := 0
+	for i, document := range documents {
+		result := evalResult{Document: i + 1}
+		// A document with fields of unexpected types fails alone
+		if err := gorule.SafeEvaluate(func() error {
+			res, err := engine.EvaluateRuleSetWithStrategy(ruleSet, document, strategy)
+			if err == nil {
+				result.Winners, result.Results, result.Outputs = res.Winners, res.Results, res.Outputs
+			}
+			return err
+		}); err != nil {
+			result.Error = err.Error()
+			exitCode = 1
+		}
+		if *jsonOutput {
+			encoder.Encode(result)
+		} else {
+			printEvalResult(stdout, ruleSet, result)
+		}
+	}
+	return exitCode
+}
+
+// printEvalResult prints the result of a document in a human readable form
+//
+//	[1] matched: high_value_cc
+//	    high_value_cc  true   {"score":100}
+//	    foreign_items  [false false]
+func printEvalResult(w io.Writer, ruleSet *gorule.RuleSet, result evalResult) {
+	if result.Error != "" {
+		fmt.Fprintf(w, "[%d] error: %s\n", result.Document, result.Error)
+		return
+	}
+	if len(result.Winners) == 0 {
+		fmt.Fprintf(w, "[%d] no match\n", result.Document)
+	} else {
+		fmt.Fprintf(w, "[%d] matched: %s\n", result.Document, strings.Join(result.Winners, ", "))
+	}
+	tw := tabwriter.NewWriter(w, 0, 0, 2, ' ', 0)
+	for _, name := range ruleSet.Names() {
+		res, ok := result.Results[name]
+		if !ok {
+			continue
+		}
+		if output, ok := result.Outputs[name]; ok {
+			text, _ := json.Marshal(ruleOutputValue(output))
+			fmt.Fprintf(tw, "    %s\t%v\t%s\n", name, res, text)
+		} else {
+			fmt.Fprintf(tw, "    %s\t%v\n", name, res)
+		}
+	}
+	tw.Flush()
+}
+
+// ruleOutputValue returns the outputs of a scalar rule or the outputs of the
+// iterations of a vector rule
+func ruleOutputValue(output *gorule.RuleOutput) interface{} {
+	if output.Iterations != nil {
+		return output.Iterations
+	}
+	return output.Outputs
+}
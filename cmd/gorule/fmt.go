@@ -0,0 +1,81 @@
+// File: fmt.go
+// Implements the fmt command rewriting rule files in the canonical format
+package main
+
+import (
+	"flag"
+	"fmt"
+	"io"
+	"os"
+)
+
+func runFmt(args []string, stdin io.Reader, stdout io.Writer, stderr io.Writer) int {
+	flags := flag.NewFlagSet("fmt", flag.ContinueOnError)
+	flags.SetOutput(stderr)
+	write := flags.Bool("w", false, "write the result to the file instead of stdout")
+	list := flags.Bool("l", false, "list the files whose formatting differs")
+	optimize := flags.Bool("O", false, "simplify the conditions (fold constants, flatten and reorder the chains)")
+	flags.Usage = func() {
+		fmt.Fprintln(stderr, "Usage: gorule fmt [-w] [-l] [-O] [files]")
+		fmt.Fprintln(stderr, "Formats the rule files (or stdin) in the canonical format")
+		flags.PrintDefaults()
+	}
+	if err := flags.Parse(args); err != nil {
+		return 2
+	}
+	if flags.NArg() == 0 {
+		if *write {
+			fmt.Fprintln(stderr, "gorule: can not use -w with stdin")
+			return 2
+		}
+		src, err := io.ReadAll(stdin)
+		if err != nil {
+			fmt.Fprintln(stderr, "gorule:", err)
+			return 1
+		}
+		return formatSource("<stdin>", string(src), false, *list, *optimize, stdout, stderr)
+	}
+	exitCode := 0
+	for _, path := range flags.Args() {
+		src, err := os.ReadFile(path)
+		if err != nil {
+			fmt.Fprintln(stderr, "gorule:", err)
+			exitCode = 1
+			continue
+		}
+		if code := formatSource(path, string(src), *write, *list, *optimize, stdout, stderr); code != 0 {
+			exitCode = code
+		}
+	}
+	return exitCode
+}
+
+func formatSource(path string, src string, write bool, list bool, optimize bool, stdout io.Writer, stderr io.Writer) int {
+	source, err := parseRuleSource(src)
+	if err != nil {
+		printSourceError(stderr, path, src, err)
+		return 1
+	}
+	if optimize {
+		source = source.optimize()
+	}
+	formatted := source.format()
+	if list {
+		if formatted != src {
+			fmt.Fprintln(stdout, path)
+		}
+		return 0
+	}
+	if write {
+		if formatted == src {
+			return 0
+		}
+		if err = os.WriteFile(path, []byte(formatted), 0o644); err != nil {
+			fmt.Fprintln(stderr, "gorule:", err)
+			return 1
+		}
+		return 0
+	}
+	fmt.Fprint(stdout, formatted)
+	return 0
+}
@@ -0,0 +1,73 @@
+// File: gen.go
+// Implements the gen command generating Go code from rules
+package main
+
+import (
+	"flag"
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+	"strings"
+
+	"github.com/praks-1529/gorule"
+)
+
+func runGen(args []string, stdin io.Reader, stdout io.Writer, stderr io.Writer) int {
+	flags := flag.NewFlagSet("gen", flag.ContinueOnError)
+	flags.SetOutput(stderr)
+	rulesFile := flags.String("rules", "", "file holding the rules (required)")
+	dir := flags.String("dir", ".", "directory of the Go package holding the struct")
+	typeName := flags.String("type", "", "name of the struct the rules operate on (required)")
+	out := flags.String("out", "", "generated file (default <dir>/<rules>_gen.go)")
+	samplesFile := flags.String("samples", "", "NDJSON file of payloads used by the generated parity tests")
+	flags.Usage = func() {
+		fmt.Fprintln(stderr, "Usage: gorule gen -rules file -type Struct [-dir dir] [-out file] [-samples file]")
+		flags.PrintDefaults()
+	}
+	if err := flags.Parse(args); err != nil {
+		return 2
+	}
+	if *rulesFile == "" || *typeName == "" {
+		flags.Usage()
+		return 2
+	}
+	source, err := os.ReadFile(*rulesFile)
+	if err != nil {
+		fmt.Fprintln(stderr, "gorule:", err)
+		return 1
+	}
+	parser := gorule.NewRuleParser(string(source))
+	ruleSet, err := parser.ParseRuleSet()
+	if err != nil {
+		printSourceError(stderr, *rulesFile, string(source), newSourceError(parser, err))
+		return 1
+	}
+	opts := gorule.GoGenOptions{Dir: *dir, TypeName: *typeName, RuleSource: string(source)}
+	if *samplesFile != "" {
+		if opts.Samples, err = readNDJSON(*samplesFile); err != nil {
+			fmt.Fprintln(stderr, "gorule:", err)
+			return 1
+		}
+	}
+	generated, err := gorule.GenerateGo(ruleSet, opts)
+	if err != nil {
+		fmt.Fprintf(stderr, "%s: %s\n", *rulesFile, err)
+		return 1
+	}
+	if *out == "" {
+		base := strings.TrimSuffix(filepath.Base(*rulesFile), filepath.Ext(*rulesFile))
+		*out = filepath.Join(*dir, base+"_gen.go")
+	}
+	testOut := strings.TrimSuffix(*out, ".go") + "_test.go"
+	if err = os.WriteFile(*out, generated.Code, 0o644); err != nil {
+		fmt.Fprintln(stderr, "gorule:", err)
+		return 1
+	}
+	if err = os.WriteFile(testOut, generated.TestCode, 0o644); err != nil {
+		fmt.Fprintln(stderr, "gorule:", err)
+		return 1
+	}
+	fmt.Fprintf(stdout, "Generated %s and %s\n", *out, testOut)
+	return 0
+}
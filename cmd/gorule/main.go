@@ -0,0 +1,65 @@
+// File: main.go
+// Command line tool to work with gorule rules
+package main
+
+import (
+	"fmt"
+	"io"
+	"os"
+	"sort"
+)
+
+// command represents one sub command of the tool
+type command struct {
+	description string
+	run         func(args []string, stdin io.Reader, stdout io.Writer, stderr io.Writer) int
+}
+
+var commands = map[string]command{
+	"check":    {description: "Parse and lint rule files", run: runCheck},
+	"cover":    {description: "Report the coverage of the rule conditions over payloads", run: runCover},
+	"eval":     {description: "Evaluate rules against JSON or NDJSON payloads", run: runEval},
+	"fmt":      {description: "Format rule files in the canonical format", run: runFmt},
+	"gen":      {description: "Generate Go code from rules", run: runGen},
+	"overlap":  {description: "Report the rules which can match the same input", run: runOverlap},
+	"payloads": {description: "Generate test payloads from rules", run: runPayloads},
+	"repl":     {description: "Interactively evaluate rules against a payload", run: runRepl},
+	"sql":      {description: "Export rules as SQL WHERE clauses", run: runSQL},
+	"table":    {description: "Evaluate CSV decision tables against JSON or NDJSON payloads", run: runTable},
+	"test":     {description: "Run the rule test fixtures", run: runTest},
+}
+
+func usage(w io.Writer) {
+	fmt.Fprintln(w, "Usage: gorule <command> [arguments]")
+	fmt.Fprintln(w, "")
+	fmt.Fprintln(w, "Commands:")
+	var names []string
+	for name := range commands {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	for _, name := range names {
+		fmt.Fprintf(w, "  %-9s %s\n", name, commands[name].description)
+	}
+	fmt.Fprintln(w, "")
+	fmt.Fprintln(w, "Run 'gorule <command> -h' for the arguments of the command")
+}
+
+// run executes the command and returns the exit code
+func run(args []string, stdin io.Reader, stdout io.Writer, stderr io.Writer) int {
+	if len(args) == 0 {
+		usage(stderr)
+		return 2
+	}
+	cmd, ok := commands[args[0]]
+	if !ok {
+		fmt.Fprintf(stderr, "gorule: unknown command %q\n\n", args[0])
+		usage(stderr)
+		return 2
+	}
+	return cmd.run(args[1:], stdin, stdout, stderr)
+}
+
+func main() {
+	os.Exit(run(os.Args[1:], os.Stdin, os.Stdout, os.Stderr))
+}
@@ -0,0 +1,353 @@
+// File: main_test.go
+package main
+
+import (
+	"bytes"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+const testRules = `RULE "high_value" PRIORITY 5:
+	IF: { amount > 100 }
+RULE "credit_card":
+	IF: { type == "CC" } THEN: { flag = 1 }
+`
+
+func writeFile(t *testing.T, name string, content string) string {
+	path := filepath.Join(t.TempDir(), name)
+	assert.Nil(t, os.WriteFile(path, []byte(content), 0o644))
+	return path
+}
+
+func runCommand(stdin string, args ...string) (int, string, string) {
+	var stdout, stderr bytes.Buffer
+	code := run(args, strings.NewReader(stdin), &stdout, &stderr)
+	return code, stdout.String(), stderr.String()
+}
+
+func TestEval(t *testing.T) {
+	rules := writeFile(t, "txn.rules", testRules)
+	input := "{ \"amount\": 200, \"type\": \"CC\" }\n\n{ \"amount\": 20, \"type\": \"DC\" }\n"
+	code, stdout, _ := runCommand(input, "eval", rules)
+	assert.Equal(t, 0, code)
+	assert.Contains(t, stdout, "[1] matched: high_value, credit_card\n")
+	assert.Contains(t, stdout, "[2] no match\n")
+
+	code, stdout, _ = runCommand(input, "eval", "-json", "-strategy", "first", rules, "-")
+	assert.Equal(t, 0, code)
+	assert.Equal(t, `{"document":1,"winners":["high_value"],"results":{"high_value":[true]}}`+"\n"+
+		`{"document":2,"results":{"credit_card":[false],"high_value":[false]}}`+"\n", stdout)
+
+	// A rule without header is named after the file, a JSON document is a single input
+	rule := writeFile(t, "amount.rules", "IF: { amount > 100 }")
+	payload := writeFile(t, "payload.json", "{\n \"amount\": 200\n}")
+	code, stdout, _ = runCommand("", "eval", "-json", rule, payload)
+	assert.Equal(t, 0, code)
+	assert.Equal(t, `{"document":1,"winners":["amount"],"results":{"amount":[true]}}`+"\n", stdout)
+
+	code, _, stderr := runCommand("{ \"amount\": 1 }\nnot json\n", "eval", rules)
+	assert.Equal(t, 1, code)
+	assert.Equal(t, "gorule: input line 2: invalid JSON\n", stderr)
+
+	code, _, _ = runCommand("", "eval", "-strategy", "unknown", rules)
+	assert.Equal(t, 2, code)
+
+	// A document with a field of another type is reported and the next ones evaluated
+	code, stdout, _ = runCommand("{ \"amount\": 200, \"type\": \"CC\" }\n{ \"amount\": \"x\", \"type\": \"CC\" }\n{ \"amount\": 20, \"type\": \"DC\" }\n", "eval", rules)
+	assert.Equal(t, 1, code)
+	assert.Contains(t, stdout, "[1] matched: high_value, credit_card\n")
+	assert.Contains(t, stdout, "[2] error: Operands type not matching\n")
+	assert.Contains(t, stdout, "[3] no match\n")
+
+	// The outputs of the winners with actions are printed along with their result
+	scoring := writeFile(t, "score.rules", `RULE "score": IF: { amount > 100 } THEN: { score = amount * 0.01; bucket = "HIGH" }`)
+	code, stdout, _ = runCommand(`{ "amount": 200 }`, "eval", scoring)
+	assert.Equal(t, 0, code)
+	assert.Equal(t, "[1] matched: score\n    score  [true]  {\"bucket\":\"HIGH\",\"score\":2}\n", stdout)
+	code, stdout, _ = runCommand(`{ "amount": 200 }`, "eval", "-json", scoring)
+	assert.Equal(t, 0, code)
+	assert.Equal(t, `{"document":1,"winners":["score"],"results":{"score":[true]},"outputs":{"score":{"matched":true,"outputs":{"bucket":"HIGH","score":2}}}}`+"\n", stdout)
+}
+
+func TestCheck(t *testing.T) {
+	rules := writeFile(t, "txn.rules", testRules)
+	code, _, stderr := runCommand("", "check", rules)
+	assert.Equal(t, 0, code)
+	assert.Equal(t, "", stderr)
+
+	broken := writeFile(t, "broken.rules", "RULE \"a\":\n\tIF: { ( amount > 100 }\n")
+	code, _, stderr = runCommand("", "check", broken)
+	assert.Equal(t, 1, code)
+	assert.Equal(t, broken+":2:23: Syntax error : Expected ) found }\n"+
+		"    \tIF: { ( amount > 100 }\n"+
+		"    \t                     ^\n", stderr)
+
+	code, stdout, _ := runCommand("", "check", "-json", rules, broken)
+	assert.Equal(t, 1, code)
+	assert.Contains(t, stdout, `"line": 2,`)
+	assert.Contains(t, stdout, `"column": 23,`)
+
+	malformedFor := writeFile(t, "for.rules", "IF: { FOR: i { a == 1 } }\n")
+	code, _, stderr = runCommand("", "check", malformedFor)
+	assert.Equal(t, 1, code)
+	assert.Equal(t, malformedFor+":1:12: Syntax error : Expected index=start:end found i\n"+
+		"    IF: { FOR: i { a == 1 } }\n"+
+		"               ^\n", stderr)
+
+	contradiction := writeFile(t, "contradiction.rules", "IF: { amount > 100 && amount < 50 }\n")
+	code, _, stderr = runCommand("", "check", contradiction)
+	assert.Equal(t, 0, code)
+	assert.Equal(t, contradiction+":1:7: Lint contradiction : the conditions on amount are never true together in amount > 100 && amount < 50\n"+
+		"    IF: { amount > 100 && amount < 50 }\n"+
+		"          ^\n", stderr)
+
+	duplicate := writeFile(t, "duplicate.rules", testRules+testRules)
+	code, _, stderr = runCommand("", "check", duplicate)
+	assert.Equal(t, 1, code)
+	assert.True(t, strings.HasPrefix(stderr, duplicate+": "))
+}
+
+func TestCheckSchema(t *testing.T) {
+	schema := writeFile(t, "schema.json", `{ "type": "object", "properties": { "amount": { "type": "integer" }, "type": { "type": "string" } } }`)
+	rules := writeFile(t, "txn.rules", testRules)
+	code, _, stderr := runCommand("", "check", "-schema", schema, rules)
+	assert.Equal(t, 0, code)
+	assert.Equal(t, "", stderr)
+
+	typo := writeFile(t, "typo.rules", "RULE \"a\":\n\tIF: { amount > 100 && tpye == \"CC\" }\n")
+	code, _, stderr = runCommand("", "check", "-schema", schema, typo)
+	assert.Equal(t, 1, code)
+	assert.Equal(t, typo+":2:24: rule a: Type error : unknown path tpye in tpye\n"+
+		"    \tIF: { amount > 100 && tpye == \"CC\" }\n"+
+		"    \t                      ^\n", stderr)
+
+	code, _, _ = runCommand("", "check", "-schema", rules, rules)
+	assert.Equal(t, 1, code)
+}
+
+func TestOverlap(t *testing.T) {
+	tiers := writeFile(t, "tiers.rules", "RULE \"silver\":\n\tIF: { amount >= 1000 && amount < 5000 }\n"+
+		"RULE \"gold\":\n\tIF: { amount >= 5000 }\nRULE \"platinum\":\n\tIF: { amount > 9000 && amount < 8000 }\n")
+	code, stdout, _ := runCommand("", "overlap", "-exclusive", tiers)
+	assert.Equal(t, 0, code)
+	assert.Equal(t, "platinum: never matches\nno two rules match the same input\n", stdout)
+
+	rules := writeFile(t, "txn.rules", testRules+"RULE \"big_card\":\n\tIF: { amount > 500 && type == \"CC\" }\n")
+	code, stdout, _ = runCommand("", "overlap", "-exclusive", rules)
+	assert.Equal(t, 1, code)
+	assert.Equal(t, "high_value, credit_card: overlap on {\"amount\":101,\"type\":\"CC\"}\n"+
+		"high_value, big_card: overlap on {\"amount\":501,\"type\":\"CC\"}, high_value subsumes big_card\n"+
+		"credit_card, big_card: overlap on {\"amount\":501,\"type\":\"CC\"}, credit_card subsumes big_card\n", stdout)
+}
+
+func TestSQL(t *testing.T) {
+	rules := writeFile(t, "txn.rules", testRules)
+	code, stdout, _ := runCommand("", "sql", rules)
+	assert.Equal(t, 0, code)
+	assert.Equal(t, "-- high_value\n(data #>> '{amount}')::numeric > $1\n-- args: [100]\n"+
+		"-- credit_card\ndata #>> '{type}' = $1\n-- args: [\"CC\"]\n", stdout)
+
+	columns := writeFile(t, "columns.json", `{"amount": "txn_amount"}`)
+	code, stdout, stderr := runCommand("", "sql", "-dialect", "columns", "-columns", columns, "-placeholder", "question", rules)
+	assert.Equal(t, 1, code)
+	assert.Equal(t, "-- high_value\ntxn_amount > ?\n-- args: [100]\n", stdout)
+	assert.Equal(t, rules+": SQL export error in rule credit_card : no column for type in type\n", stderr)
+}
+
+func TestTable(t *testing.T) {
+	table := writeFile(t, "discount.csv", "amount,tier,THEN discount,THEN label\n"+
+		">= 1000,\"\"\"GOLD\"\"\",0.1,\"\"\"gold\"\"\"\n"+
+		">= 1000,-,0.05,\n")
+	input := "{ \"amount\": 2000, \"tier\": \"GOLD\" }\n{ \"amount\": 20 }\n"
+	code, stdout, _ := runCommand(input, "table", table)
+	assert.Equal(t, 0, code)
+	assert.Equal(t, "[1] row 1: discount=0.1 label=\"gold\"\n[2] no match\n", stdout)
+
+	code, stdout, _ = runCommand(input, "table", "-hit", "collect", "-json", table)
+	assert.Equal(t, 0, code)
+	assert.Equal(t, `{"document":1,"rows":[1,2],"outputs":[{"discount":0.1,"label":"gold"},{"discount":0.05}]}`+"\n"+
+		`{"document":2,"rows":[],"outputs":[]}`+"\n", stdout)
+
+	code, stdout, stderr := runCommand(input, "table", "-hit", "unique", table)
+	assert.Equal(t, 1, code)
+	assert.Equal(t, "[1] error: Hit policy unique of discount violated : rows [1 2] matched\n[2] no match\n", stdout)
+	assert.Equal(t, table+": warning: rows 1 and 2 may both match, ex. {\"amount\":1000,\"tier\":\"GOLD\"}\n", stderr)
+
+	code, stdout, _ = runCommand("", "table", "-rules", table)
+	assert.Equal(t, 0, code)
+	assert.Equal(t, "RULE \"discount_1\":\n\tIF: { amount >= 1000 && tier == \"GOLD\" } THEN: { discount = 0.1; label = \"gold\" }\n"+
+		"RULE \"discount_2\":\n\tIF: { amount >= 1000 } THEN: { discount = 0.05 }\n", stdout)
+
+	// A document with a field of another type is reported and the next ones evaluated
+	code, stdout, _ = runCommand("{ \"amount\": \"x\", \"tier\": \"GOLD\" }\n{ \"amount\": 20 }\n", "table", table)
+	assert.Equal(t, 1, code)
+	assert.Equal(t, "[1] error: Operands type not matching\n[2] no match\n", stdout)
+
+	// An overlap not proven by evaluating the rows is given without example
+	fields := writeFile(t, "fields.csv", "amount,THEN discount\n> 10,0.1\n== limit,0.05\n")
+	code, _, stderr = runCommand("{ \"amount\": 20, \"limit\": 5 }", "table", "-hit", "unique", fields)
+	assert.Equal(t, 0, code)
+	assert.Equal(t, fields+": warning: rows 1 and 2 may both match (not all the conditions are analysed)\n", stderr)
+
+	invalid := writeFile(t, "invalid.csv", "amount,THEN discount\n>> 1,0.1\n")
+	code, _, stderr = runCommand("", "table", invalid)
+	assert.Equal(t, 1, code)
+	assert.Equal(t, invalid+": Decision table error at line 2 column amount : expecting a literal or a field, found > 1\n", stderr)
+}
+
+func TestPayloads(t *testing.T) {
+	rules := writeFile(t, "txn.rules", testRules)
+	code, stdout, _ := runCommand("", "payloads", rules)
+	assert.Equal(t, 0, code)
+	// The payloads of a rule are completed with the fields of the other rules
+	assert.Equal(t, "{\"amount\":101,\"type\":\"CC\"}\n{\"amount\":100,\"type\":\"CC\"}\n{\"amount\":99,\"type\":\"CC\"}\n"+
+		"{\"amount\":101,\"type\":\"value0\"}\n{\"amount\":101,\"type\":\"CC_other\"}\n", stdout)
+
+	code, stdout, _ = runCommand("", "payloads", "-format", "json", rules)
+	assert.Equal(t, 0, code)
+	assert.Contains(t, stdout, `"name": "amount = 99",`)
+
+	// The generated fixture passes against the rules it was generated from
+	code, stdout, _ = runCommand("", "payloads", "-format", "fixture", rules)
+	assert.Equal(t, 0, code)
+	assert.Contains(t, stdout, "  - name: 'high_value: match 1'\n")
+	fixture := filepath.Join(filepath.Dir(rules), "txn_test.yaml")
+	assert.Nil(t, os.WriteFile(fixture, []byte(stdout), 0o644))
+	code, stdout, _ = runCommand("", "test", fixture)
+	assert.Equal(t, 0, code)
+	assert.True(t, strings.HasSuffix(stdout, "5 passed, 0 failed\n"), stdout)
+}
+
+func TestFmt(t *testing.T) {
+	code, stdout, _ := runCommand("RULE \"a\"   PRIORITY 2:  IF: { x  >  1.50 }", "fmt")
+	assert.Equal(t, 0, code)
+	assert.Equal(t, "RULE \"a\" PRIORITY 2:\n\tIF: { x > 1.5 }\n", stdout)
+
+	formatted := writeFile(t, "formatted.rules", "IF: { x > 1 }\n")
+	unformatted := writeFile(t, "unformatted.rules", "IF:   { x > 1 }")
+	code, stdout, _ = runCommand("", "fmt", "-l", formatted, unformatted)
+	assert.Equal(t, 0, code)
+	assert.Equal(t, unformatted+"\n", stdout)
+
+	code, _, _ = runCommand("", "fmt", "-w", unformatted)
+	assert.Equal(t, 0, code)
+	content, _ := os.ReadFile(unformatted)
+	assert.Equal(t, "IF: { x > 1 }\n", string(content))
+
+	// Trailing input which is not a rule is an error, not silently dropped
+	code, _, stderr := runCommand("IF: { x > 1 } IF: { y > 1 }", "fmt")
+	assert.Equal(t, 1, code)
+	assert.Contains(t, stderr, "<stdin>:1:15: Syntax error : Expected end of input found IF:")
+}
+
+func TestFmtOptimize(t *testing.T) {
+	code, stdout, _ := runCommand("RULE \"a\": IF: { true && ( x > 1 && ( y == 2 && 1 < 2 ) ) } RULE \"b\": IF: { x > 1 || 2 > 1 }", "fmt", "-O")
+	assert.Equal(t, 0, code)
+	assert.Equal(t, "RULE \"a\":\n\tIF: { x > 1 && y == 2 }\nRULE \"b\":\n\tIF: { true }\n", stdout)
+}
+
+func TestRepl(t *testing.T) {
+	payload := writeFile(t, "payload.json", `{ "amount": 200, "items": [ { "price": 30 } ] }`)
+	historyFile := filepath.Join(t.TempDir(), "history")
+	input := strings.Join([]string{
+		`amount > 100 && type == "CC"`,
+		`:set type CC`,
+		`!1`,
+		`FOR: i=0:items.size()`,
+		`IF: { items[i].price > 10 }`,
+		`:set items.1 { "price": 1.50 }`,
+		`:show`,
+		`amount > 1 &&`,
+		`:unknown`,
+	}, "\n")
+	code, stdout, _ := runCommand(input, "repl", "-history", historyFile, payload)
+	assert.Equal(t, 0, code)
+	assert.Contains(t, stdout, "gorule> [false]\n"+
+		"false  amount > 100 && type == \"CC\"\n"+
+		"  true  amount > 100\n"+
+		"    200  amount\n"+
+		"  false  type == \"CC\"\n"+
+		"    <missing>  type\n"+
+		"fields:\n"+
+		"  amount  200\n"+
+		"  type    <missing>\n")
+	// !1 repeats the first entry, now with type set
+	assert.Contains(t, stdout, "gorule> amount > 100 && type == \"CC\"\n[true]\n")
+	assert.Contains(t, stdout, "   ...> [true]\n[0] true  items[i].price > 10\n")
+	assert.Contains(t, stdout, `"price": 1.50`)
+	assert.Contains(t, stdout, "error: Rule format is malformed\n")
+	assert.Contains(t, stdout, "error: unknown command :unknown")
+
+	// The history is kept across the sessions
+	code, stdout, _ = runCommand(":history\n!!\n", "repl", "-history", historyFile)
+	assert.Equal(t, 0, code)
+	assert.Contains(t, stdout, "   4  FOR: i=0:items.size()\n      IF: { items[i].price > 10 }\n")
+	assert.Contains(t, stdout, "gorule> :history\n")
+}
+
+func TestRuleTest(t *testing.T) {
+	dir := t.TempDir()
+	assert.Nil(t, os.WriteFile(filepath.Join(dir, "txn.rules"), []byte(testRules), 0o644))
+	assert.Nil(t, os.WriteFile(filepath.Join(dir, "txn_test.yaml"), []byte(`
+rules: txn.rules
+cases:
+  - name: credit card
+    input: { amount: 10, type: CC }
+    expect: { matched: [credit_card] }
+  - name: high value
+    input: { amount: 200, type: DC }
+    expect: { match: false }
+`), 0o644))
+	// Not a fixture, directories are searched for *_test.yaml only
+	assert.Nil(t, os.WriteFile(filepath.Join(dir, "other.yaml"), []byte("rules: missing.rules"), 0o644))
+	fixture := filepath.Join(dir, "txn_test.yaml")
+
+	code, stdout, _ := runCommand("", "test", "-v", dir)
+	assert.Equal(t, 1, code)
+	assert.Equal(t, "PASS  "+fixture+"  credit card\n"+
+		"FAIL  "+fixture+"  high value\n"+
+		"      match: expected false got true (winners [high_value])\n"+
+		"FAIL  "+fixture+"  1 of 2 cases passed\n"+
+		"1 passed, 1 failed\n", stdout)
+
+	code, stdout, _ = runCommand("", "test", "-json", fixture)
+	assert.Equal(t, 1, code)
+	assert.Contains(t, stdout, `"passed": false,`)
+
+	code, stdout, _ = runCommand("", "test", filepath.Join(dir, "other.yaml"))
+	assert.Equal(t, 1, code)
+	assert.Contains(t, stdout, "FAIL  "+filepath.Join(dir, "other.yaml")+"\n")
+}
+
+func TestCover(t *testing.T) {
+	rules := writeFile(t, "txn.rules", testRules)
+	input := "{ \"amount\": 200, \"type\": \"CC\" }\n{ \"amount\": 20, \"type\": \"CC\" }\n"
+	code, stdout, _ := runCommand(input, "cover", "-format", "annotated", rules)
+	assert.Equal(t, 0, code)
+	assert.Equal(t, "RULE \"high_value\" PRIORITY 5: matched 1/2\n"+
+		"  T1 F1  covered  amount > 100\n"+
+		"RULE \"credit_card\": matched 2/2\n"+
+		"  T2 F0  true only  type == \"CC\"\n", stdout)
+
+	code, stdout, _ = runCommand(input, "cover", "-format", "json", rules)
+	assert.Equal(t, 0, code)
+	assert.Contains(t, stdout, `"total_branches": 4,`)
+
+	code, stdout, _ = runCommand(input, "cover", rules)
+	assert.Equal(t, 0, code)
+	assert.True(t, strings.HasPrefix(stdout, "payloads: 2  conditions: 2/2 reached  branches: 3/4 (75.0%)\n"))
+
+	// The engine fails on comparing a missing field with a number
+	code, _, stderr := runCommand("{ \"type\": \"CC\" }", "cover", rules)
+	assert.Equal(t, 1, code)
+	assert.Contains(t, stderr, "gorule: document 1:")
+
+	fixture := writeFile(t, "txn_test.yaml", "rules: "+rules+"\ncases:\n  - input: { amount: 10, type: CC }\n")
+	code, stdout, _ = runCommand("", "test", "-cover", fixture)
+	assert.Equal(t, 0, code)
+	assert.Contains(t, stdout, "  T0 F1  false only  amount > 100\n")
+}
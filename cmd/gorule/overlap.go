@@ -0,0 +1,97 @@
+// File: overlap.go
+// Implements the overlap command reporting the rules which can match the same input
+package main
+
+import (
+	"encoding/json"
+	"flag"
+	"fmt"
+	"io"
+	"path/filepath"
+	"strings"
+
+	"github.com/praks-1529/gorule"
+)
+
+// overlapReport represents the outcome of the overlap command
+type overlapReport struct {
+	// Unsatisfiable holds the rules which never match
+	Unsatisfiable []string               `json:"unsatisfiable"`
+	Overlaps      []*gorule.RuleRelation `json:"overlaps"`
+}
+
+func runOverlap(args []string, stdin io.Reader, stdout io.Writer, stderr io.Writer) int {
+	flags := flag.NewFlagSet("overlap", flag.ContinueOnError)
+	flags.SetOutput(stderr)
+	jsonOutput := flags.Bool("json", false, "print the report as JSON")
+	exclusive := flags.Bool("exclusive", false, "exit with 1 if any two rules can match the same input")
+	flags.Usage = func() {
+		fmt.Fprintln(stderr, "Usage: gorule overlap [-json] [-exclusive] rules")
+		fmt.Fprintln(stderr, "Reports the rules which can match the same input with a witness payload, the rules subsuming")
+		fmt.Fprintln(stderr, "others and the rules which never match")
+		flags.PrintDefaults()
+	}
+	if err := flags.Parse(args); err != nil {
+		return 2
+	}
+	if flags.NArg() != 1 {
+		flags.Usage()
+		return 2
+	}
+	rulesFile := flags.Arg(0)
+	src, source, err := readRuleSource(rulesFile)
+	if err != nil {
+		printSourceError(stderr, rulesFile, src, err)
+		return 1
+	}
+	ruleSet := source.rules(strings.TrimSuffix(filepath.Base(rulesFile), filepath.Ext(rulesFile)))
+	report := &overlapReport{Unsatisfiable: []string{}, Overlaps: gorule.AnalyzeRuleSetOverlap(ruleSet)}
+	for _, name := range ruleSet.Names() {
+		rule, _ := ruleSet.Get(name)
+		if !gorule.CheckSatisfiable(rule).Satisfiable {
+			report.Unsatisfiable = append(report.Unsatisfiable, name)
+		}
+	}
+	if *jsonOutput {
+		encoder := json.NewEncoder(stdout)
+		encoder.SetIndent("", "  ")
+		encoder.Encode(report)
+	} else {
+		printOverlapReport(stdout, report)
+	}
+	if *exclusive && len(report.Overlaps) > 0 {
+		return 1
+	}
+	return 0
+}
+
+// printOverlapReport prints one line per rule which never matches and per pair
+// of rules which may overlap
+//
+//	silver, gold: overlap on {"amount":5000,"type":"CC"}
+//	gold, debit: overlap on {"amount":6000,"type":"DC"}, gold subsumes debit
+func printOverlapReport(w io.Writer, report *overlapReport) {
+	for _, name := range report.Unsatisfiable {
+		fmt.Fprintf(w, "%s: never matches\n", name)
+	}
+	for _, relation := range report.Overlaps {
+		witness, _ := json.Marshal(relation.Witness)
+		line := fmt.Sprintf("%s, %s: overlap on %s", relation.Rule1, relation.Rule2, witness)
+		if !relation.Verified {
+			line = fmt.Sprintf("%s, %s: may overlap (not all the conditions are analysed, %s is not a match of both)",
+				relation.Rule1, relation.Rule2, witness)
+		}
+		switch {
+		case relation.Subsumes && relation.SubsumedBy:
+			line += ", the rules match the same inputs"
+		case relation.Subsumes:
+			line += fmt.Sprintf(", %s subsumes %s", relation.Rule1, relation.Rule2)
+		case relation.SubsumedBy:
+			line += fmt.Sprintf(", %s subsumes %s", relation.Rule2, relation.Rule1)
+		}
+		fmt.Fprintln(w, line)
+	}
+	if len(report.Overlaps) == 0 {
+		fmt.Fprintln(w, "no two rules match the same input")
+	}
+}
@@ -0,0 +1,73 @@
+// File: payloads.go
+// Implements the payloads command generating test payloads from rules
+package main
+
+import (
+	"encoding/json"
+	"flag"
+	"fmt"
+	"io"
+	"path/filepath"
+	"strings"
+
+	"github.com/praks-1529/gorule"
+	"gopkg.in/yaml.v3"
+)
+
+// rulePayload represents a generated payload of a rule in the JSON output
+type rulePayload struct {
+	Rule string `json:"rule"`
+	*gorule.GeneratedPayload
+}
+
+func runPayloads(args []string, stdin io.Reader, stdout io.Writer, stderr io.Writer) int {
+	flags := flag.NewFlagSet("payloads", flag.ContinueOnError)
+	flags.SetOutput(stderr)
+	format := flags.String("format", "ndjson", "output format: ndjson, json or fixture")
+	flags.Usage = func() {
+		fmt.Fprintln(stderr, "Usage: gorule payloads [-format ndjson|json|fixture] rules")
+		fmt.Fprintln(stderr, "Generates payloads making the rules match and not match along with boundary payloads of each")
+		fmt.Fprintln(stderr, "comparison. The fixture format is a test fixture to save next to the rule file")
+		flags.PrintDefaults()
+	}
+	if err := flags.Parse(args); err != nil {
+		return 2
+	}
+	if flags.NArg() != 1 || (*format != "ndjson" && *format != "json" && *format != "fixture") {
+		flags.Usage()
+		return 2
+	}
+	rulesFile := flags.Arg(0)
+	src, source, err := readRuleSource(rulesFile)
+	if err != nil {
+		printSourceError(stderr, rulesFile, src, err)
+		return 1
+	}
+	ruleSet := source.rules(strings.TrimSuffix(filepath.Base(rulesFile), filepath.Ext(rulesFile)))
+	if *format != "json" {
+		// The inputs of the suite are completed with the fields of all the rules
+		suite := gorule.GenerateTestSuite(ruleSet, filepath.Base(rulesFile))
+		if *format == "fixture" {
+			encoder := yaml.NewEncoder(stdout)
+			encoder.SetIndent(2)
+			encoder.Encode(suite)
+			return 0
+		}
+		encoder := json.NewEncoder(stdout)
+		for _, testCase := range suite.Cases {
+			encoder.Encode(testCase.Input)
+		}
+		return 0
+	}
+	payloads := []rulePayload{}
+	for _, name := range ruleSet.Names() {
+		rule, _ := ruleSet.Get(name)
+		for _, payload := range gorule.GeneratePayloads(rule) {
+			payloads = append(payloads, rulePayload{Rule: name, GeneratedPayload: payload})
+		}
+	}
+	encoder := json.NewEncoder(stdout)
+	encoder.SetIndent("", "  ")
+	encoder.Encode(payloads)
+	return 0
+}
@@ -0,0 +1,340 @@
+// File: repl.go
+// Implements the repl command to interactively evaluate rules against a payload
+package main
+
+import (
+	"bufio"
+	"bytes"
+	"encoding/json"
+	"flag"
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+	"strconv"
+	"strings"
+	"text/tabwriter"
+
+	"github.com/praks-1529/gorule"
+)
+
+const replHelp = `Type a condition (ex. amount > 100 && type == "CC") or a full IF: / FOR: rule.
+A rule spans lines until its { } are balanced.
+
+Commands:
+  :load file.json    load the payload (first document of NDJSON)
+  :set path value    set a field of the payload, value is JSON or a plain string
+  :show              print the payload
+  :history           list the history, !! repeats the last entry and !n the nth
+  :help              print this help
+  :quit              exit
+`
+
+// repl represents the state of an interactive session
+type repl struct {
+	payload     []byte
+	history     []string
+	historyFile string
+	engine      *gorule.RuleEngine
+	stdout      io.Writer
+}
+
+func runRepl(args []string, stdin io.Reader, stdout io.Writer, stderr io.Writer) int {
+	flags := flag.NewFlagSet("repl", flag.ContinueOnError)
+	flags.SetOutput(stderr)
+	historyFile := flags.String("history", defaultHistoryFile(), "file the history is kept in (empty to disable)")
+	flags.Usage = func() {
+		fmt.Fprintln(stderr, "Usage: gorule repl [-history file] [payload.json]")
+		flags.PrintDefaults()
+	}
+	if err := flags.Parse(args); err != nil {
+		return 2
+	}
+	if flags.NArg() > 1 {
+		flags.Usage()
+		return 2
+	}
+	session := &repl{payload: []byte("{}"), historyFile: *historyFile, engine: gorule.NewRuleEngine(), stdout: stdout}
+	session.readHistory()
+	if flags.NArg() == 1 {
+		if err := session.load(flags.Arg(0)); err != nil {
+			fmt.Fprintln(stderr, "gorule:", err)
+			return 1
+		}
+	}
+	fmt.Fprintln(stdout, "gorule repl, :help for the commands")
+	scanner := bufio.NewScanner(stdin)
+	for {
+		entry, ok := readEntry(scanner, stdout)
+		if !ok {
+			fmt.Fprintln(stdout)
+			return 0
+		}
+		if entry == "" {
+			continue
+		}
+		if entry, ok = session.expandHistory(entry); !ok {
+			continue
+		}
+		if entry == ":quit" || entry == ":q" {
+			return 0
+		}
+		session.addHistory(entry)
+		session.execute(entry)
+	}
+}
+
+// readEntry reads one entry. A rule continues on the next lines until its
+// condition is started and the { } are balanced
+func readEntry(scanner *bufio.Scanner, stdout io.Writer) (string, bool) {
+	fmt.Fprint(stdout, "gorule> ")
+	var lines []string
+	for scanner.Scan() {
+		lines = append(lines, scanner.Text())
+		entry := strings.TrimSpace(strings.Join(lines, "\n"))
+		opened, closed := strings.Count(entry, "{"), strings.Count(entry, "}")
+		if opened <= closed && (opened > 0 || !isRuleStart(entry)) {
+			return entry, true
+		}
+		fmt.Fprint(stdout, "   ...> ")
+	}
+	return "", false
+}
+
+// execute runs a command or evaluates a rule
+func (_r *repl) execute(entry string) {
+	if strings.HasPrefix(entry, ":") {
+		_r.command(entry)
+		return
+	}
+	rule, err := parseReplRule(entry)
+	if err != nil {
+		fmt.Fprintln(_r.stdout, "error:", err)
+		return
+	}
+	explanation, err := _r.explain(rule)
+	if err != nil {
+		fmt.Fprintln(_r.stdout, "error:", err)
+		return
+	}
+	fmt.Fprint(_r.stdout, explanation)
+	if len(explanation.Fields) > 0 {
+		fmt.Fprintln(_r.stdout, "fields:")
+		tw := tabwriter.NewWriter(_r.stdout, 0, 0, 2, ' ', 0)
+		for _, field := range explanation.Fields {
+			if field.Missing {
+				fmt.Fprintf(tw, "  %s\t<missing>\n", field.Path)
+			} else {
+				fmt.Fprintf(tw, "  %s\t%v\n", field.Path, field.Value)
+			}
+		}
+		tw.Flush()
+	}
+}
+
+// explain evaluates the rule for the payload. A panic of the engine is
+// reported as an error
+func (_r *repl) explain(rule gorule.Rule) (explanation *gorule.Explanation, err error) {
+	err = gorule.SafeEvaluate(func() (err error) {
+		explanation, err = _r.engine.Explain(rule, _r.payload)
+		return err
+	})
+	return explanation, err
+}
+
+// isRuleStart checks if the entry is a full rule rather than a bare condition
+func isRuleStart(entry string) bool {
+	return strings.HasPrefix(entry, string(gorule.IfToken)) || strings.HasPrefix(entry, string(gorule.ForToken)) ||
+		strings.HasPrefix(entry, string(gorule.RuleToken)+" ")
+}
+
+// parseReplRule parses a rule. A bare condition is taken as IF: { condition }
+func parseReplRule(entry string) (gorule.Rule, error) {
+	if !isRuleStart(entry) {
+		entry = fmt.Sprintf("%s %s %s %s", gorule.IfToken, gorule.CurlyOpenBraceToken, entry, gorule.CurlyCloseBraceToken)
+	}
+	parser := gorule.NewRuleParser(entry)
+	rule, err := parser.ParseRule()
+	if err != nil {
+		return nil, err
+	}
+	return rule, parser.ValidateEOF()
+}
+
+// command runs the : commands
+func (_r *repl) command(entry string) {
+	fields := strings.Fields(entry)
+	var err error
+	switch fields[0] {
+	case ":load":
+		if len(fields) != 2 {
+			err = fmt.Errorf("usage :load file.json")
+			break
+		}
+		err = _r.load(fields[1])
+	case ":set":
+		if len(fields) < 3 {
+			err = fmt.Errorf("usage :set path value")
+			break
+		}
+		value := strings.TrimSpace(strings.TrimPrefix(strings.TrimPrefix(entry, fields[0]), " "+fields[1]))
+		err = _r.set(fields[1], value)
+	case ":show":
+		var data bytes.Buffer
+		json.Indent(&data, _r.payload, "", "  ")
+		fmt.Fprintln(_r.stdout, data.String())
+	case ":history":
+		for i, item := range _r.history {
+			fmt.Fprintf(_r.stdout, "%4d  %s\n", i+1, strings.ReplaceAll(item, "\n", "\n      "))
+		}
+	case ":help":
+		fmt.Fprint(_r.stdout, replHelp)
+	default:
+		err = fmt.Errorf("unknown command %s, :help for the commands", fields[0])
+	}
+	if err != nil {
+		fmt.Fprintln(_r.stdout, "error:", err)
+	}
+}
+
+// load loads the payload from a JSON (or NDJSON) file
+func (_r *repl) load(path string) error {
+	documents, err := readNDJSON(path)
+	if err != nil {
+		return err
+	}
+	if len(documents) == 0 {
+		return fmt.Errorf("%s: no JSON document found", path)
+	}
+	_r.payload = documents[0]
+	if len(documents) > 1 {
+		fmt.Fprintf(_r.stdout, "loaded document 1 of %d\n", len(documents))
+	}
+	return nil
+}
+
+// set sets the field at path (ex. items.0.price) of the payload. Missing objects
+// on the path are created
+func (_r *repl) set(path string, rawValue string) error {
+	value, err := decodeJSON([]byte(rawValue))
+	if err != nil {
+		// Not JSON, take it as a plain string
+		value = rawValue
+	}
+	payload, err := decodeJSON(_r.payload)
+	if err != nil {
+		return err
+	}
+	payload, err = setPath(payload, strings.Split(path, "."), value)
+	if err != nil {
+		return fmt.Errorf("%s: %s", path, err)
+	}
+	_r.payload, err = json.Marshal(payload)
+	return err
+}
+
+// decodeJSON decodes the JSON keeping the numbers as written
+func decodeJSON(data []byte) (interface{}, error) {
+	var value interface{}
+	decoder := json.NewDecoder(bytes.NewReader(data))
+	decoder.UseNumber()
+	if err := decoder.Decode(&value); err != nil {
+		return nil, err
+	}
+	if decoder.More() {
+		return nil, fmt.Errorf("unexpected data after JSON value")
+	}
+	return value, nil
+}
+
+// setPath returns node with the value set at the path
+func setPath(node interface{}, parts []string, value interface{}) (interface{}, error) {
+	if len(parts) == 0 {
+		return value, nil
+	}
+	switch current := node.(type) {
+	case []interface{}:
+		index, err := strconv.Atoi(parts[0])
+		if err != nil || index < 0 || index > len(current) {
+			return nil, fmt.Errorf("invalid array index %s", parts[0])
+		}
+		if index == len(current) {
+			// One past the end appends
+			current = append(current, nil)
+		}
+		if current[index], err = setPath(current[index], parts[1:], value); err != nil {
+			return nil, err
+		}
+		return current, nil
+	case map[string]interface{}:
+		child, err := setPath(current[parts[0]], parts[1:], value)
+		if err != nil {
+			return nil, err
+		}
+		current[parts[0]] = child
+		return current, nil
+	case nil:
+		return setPath(map[string]interface{}{}, parts, value)
+	}
+	return nil, fmt.Errorf("can not set %s of %v", parts[0], node)
+}
+
+// expandHistory replaces !! and !n with the entry from the history
+func (_r *repl) expandHistory(entry string) (string, bool) {
+	if !strings.HasPrefix(entry, "!") {
+		return entry, true
+	}
+	index := len(_r.history)
+	if entry != "!!" {
+		var err error
+		if index, err = strconv.Atoi(entry[1:]); err != nil {
+			fmt.Fprintln(_r.stdout, "error: usage !! or !n")
+			return "", false
+		}
+	}
+	if index < 1 || index > len(_r.history) {
+		fmt.Fprintln(_r.stdout, "error: no such history entry")
+		return "", false
+	}
+	entry = _r.history[index-1]
+	fmt.Fprintln(_r.stdout, entry)
+	return entry, true
+}
+
+// readHistory loads the history of the earlier sessions. Entries spanning
+// multiple lines are stored with the new lines escaped
+func (_r *repl) readHistory() {
+	if _r.historyFile == "" {
+		return
+	}
+	data, err := os.ReadFile(_r.historyFile)
+	if err != nil {
+		return
+	}
+	for _, line := range strings.Split(string(data), "\n") {
+		if line != "" {
+			_r.history = append(_r.history, strings.ReplaceAll(line, `\n`, "\n"))
+		}
+	}
+}
+
+func (_r *repl) addHistory(entry string) {
+	_r.history = append(_r.history, entry)
+	if _r.historyFile == "" {
+		return
+	}
+	file, err := os.OpenFile(_r.historyFile, os.O_APPEND|os.O_CREATE|os.O_WRONLY, 0o600)
+	if err != nil {
+		return
+	}
+	defer file.Close()
+	fmt.Fprintln(file, strings.ReplaceAll(entry, "\n", `\n`))
+}
+
+func defaultHistoryFile() string {
+	home, err := os.UserHomeDir()
+	if err != nil {
+		return ""
+	}
+	return filepath.Join(home, ".gorule_history")
+}
@@ -0,0 +1,221 @@
+// File: rules.go
+// Common helpers to load the rule files and the input documents
+package main
+
+import (
+	"bufio"
+	"bytes"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"os"
+	"strings"
+
+	"github.com/praks-1529/gorule"
+)
+
+// ruleSource represents a parsed rule file. A file holds either one rule without
+// header or rules with RULE headers
+type ruleSource struct {
+	rule    gorule.Rule
+	ruleSet *gorule.RuleSet
+	// sourceMap maps the conditions and actions to their offset in the file
+	sourceMap gorule.SourceMap
+}
+
+// sourceError represents an error while parsing a rule file along with the
+// offset of the source it was found at
+type sourceError struct {
+	Offset int
+	Err    error
+}
+
+func (_rt *sourceError) Error() string {
+	return _rt.Err.Error()
+}
+
+func (_rt *sourceError) Unwrap() error {
+	return _rt.Err
+}
+
+// parseRuleSource parses the source of a rule file
+func parseRuleSource(src string) (*ruleSource, error) {
+	parser := gorule.NewRuleParser(src)
+	if strings.HasPrefix(strings.TrimSpace(src), string(gorule.RuleToken)) {
+		ruleSet, err := parser.ParseRuleSet()
+		if err != nil {
+			return nil, newSourceError(parser, err)
+		}
+		return &ruleSource{ruleSet: ruleSet, sourceMap: parser.SourceMap()}, nil
+	}
+	rule, err := parser.ParseRule()
+	if err != nil {
+		return nil, newSourceError(parser, err)
+	}
+	if err = parser.ValidateEOF(); err != nil {
+		return nil, newSourceError(parser, err)
+	}
+	return &ruleSource{rule: rule, sourceMap: parser.SourceMap()}, nil
+}
+
+// newSourceError wraps the parse error with the offset of the offending token
+func newSourceError(parser *gorule.RuleParser, err error) error {
+	var syntaxErr *gorule.SyntaxError
+	if errors.As(err, &syntaxErr) {
+		return &sourceError{Offset: syntaxErr.Index - len(syntaxErr.Found), Err: err}
+	}
+	var duplicateErr *gorule.DuplicateRuleError
+	if errors.As(err, &duplicateErr) {
+		// The whole rule is parsed, there is no token to point at
+		return err
+	}
+	return &sourceError{Offset: parser.Position(), Err: err}
+}
+
+// readRuleSource reads and parses a rule file
+func readRuleSource(path string) (string, *ruleSource, error) {
+	src, err := os.ReadFile(path)
+	if err != nil {
+		return "", nil, err
+	}
+	source, err := parseRuleSource(string(src))
+	return string(src), source, err
+}
+
+// format returns the canonical text of the rule file
+func (_rs *ruleSource) format() string {
+	if _rs.ruleSet != nil {
+		return gorule.FormatRuleSet(_rs.ruleSet)
+	}
+	return gorule.Format(_rs.rule) + "\n"
+}
+
+// optimize returns the source with the conditions of the rules simplified
+func (_rs *ruleSource) optimize() *ruleSource {
+	if _rs.ruleSet != nil {
+		return &ruleSource{ruleSet: gorule.OptimizeRuleSet(_rs.ruleSet)}
+	}
+	return &ruleSource{rule: gorule.Optimize(_rs.rule)}
+}
+
+// rules returns the rules of the file as a rule set. A rule without header is
+// named after the file
+func (_rs *ruleSource) rules(name string) *gorule.RuleSet {
+	if _rs.ruleSet != nil {
+		return _rs.ruleSet
+	}
+	ruleSet := gorule.NewRuleSet()
+	ruleSet.Add(name, _rs.rule)
+	return ruleSet
+}
+
+// diagnostic represents a problem found in a rule file
+type diagnostic struct {
+	File     string `json:"file"`
+	Line     int    `json:"line,omitempty"`
+	Column   int    `json:"column,omitempty"`
+	Severity string `json:"severity"`
+	Message  string `json:"message"`
+	// text of the source line, used to print the caret
+	text string
+}
+
+// newDiagnostic creates the diagnostic of the error found while parsing src
+func newDiagnostic(path string, src string, err error) diagnostic {
+	diag := diagnostic{File: path, Severity: "error", Message: err.Error()}
+	var srcErr *sourceError
+	if errors.As(err, &srcErr) {
+		diag.Line, diag.Column, diag.text = sourceLine(src, srcErr.Offset)
+	}
+	return diag
+}
+
+// print prints the diagnostic as path:line:col along with the source line and a
+// caret pointing at the offending token
+//
+//	transaction.rules:3:1: Syntax error : Expected RULE found foo
+//	    foo IF: { x > 1 }
+//	    ^
+func (_d diagnostic) print(w io.Writer) {
+	if _d.Line == 0 {
+		fmt.Fprintf(w, "%s: %s\n", _d.File, _d.Message)
+		return
+	}
+	fmt.Fprintf(w, "%s:%d:%d: %s\n", _d.File, _d.Line, _d.Column, _d.Message)
+	fmt.Fprintf(w, "    %s\n", _d.text)
+	// Keep the tabs so the caret lines up with the source line
+	var pad strings.Builder
+	for _, ch := range _d.text[:_d.Column-1] {
+		if ch == '\t' {
+			pad.WriteRune('\t')
+		} else {
+			pad.WriteRune(' ')
+		}
+	}
+	fmt.Fprintf(w, "    %s^\n", pad.String())
+}
+
+// printSourceError prints the error found while parsing src
+func printSourceError(w io.Writer, path string, src string, err error) {
+	newDiagnostic(path, src, err).print(w)
+}
+
+// sourceLine returns the 1-based line and column of the offset along with the
+// text of the line
+func sourceLine(src string, offset int) (int, int, string) {
+	if offset > len(src) {
+		offset = len(src)
+	}
+	if offset < 0 {
+		offset = 0
+	}
+	// An offset at the end of a line (i.e. end of input) points past the last token
+	for offset > 0 && offset == len(src) && isSpace(src[offset-1]) {
+		offset--
+	}
+	lineStart := strings.LastIndexByte(src[:offset], '\n') + 1
+	lineEnd := strings.IndexByte(src[offset:], '\n')
+	if lineEnd < 0 {
+		lineEnd = len(src)
+	} else {
+		lineEnd += offset
+	}
+	line := strings.Count(src[:lineStart], "\n") + 1
+	return line, offset - lineStart + 1, strings.TrimRight(src[lineStart:lineEnd], "\r")
+}
+
+func isSpace(ch byte) bool {
+	return ch == ' ' || ch == '\t' || ch == '\n' || ch == '\r'
+}
+
+// splitDocuments splits the input into JSON documents. The input is either a
+// single JSON document or NDJSON (one JSON document per line)
+func splitDocuments(data []byte) ([][]byte, error) {
+	if trimmed := bytes.TrimSpace(data); json.Valid(trimmed) {
+		return [][]byte{trimmed}, nil
+	}
+	var documents [][]byte
+	scanner := bufio.NewScanner(bytes.NewReader(data))
+	scanner.Buffer(make([]byte, 0, 64*1024), len(data)+1)
+	for lineNo := 1; scanner.Scan(); lineNo++ {
+		line := bytes.TrimSpace(scanner.Bytes())
+		if len(line) == 0 {
+			continue
+		}
+		if !json.Valid(line) {
+			return nil, fmt.Errorf("line %d: invalid JSON", lineNo)
+		}
+		documents = append(documents, append([]byte(nil), line...))
+	}
+	return documents, scanner.Err()
+}
+
+// readNDJSON reads a file holding JSON documents (see splitDocuments)
+func readNDJSON(path string) ([][]byte, error) {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return nil, err
+	}
+	return splitDocuments(data)
+}
@@ -0,0 +1,111 @@
+// File: sql.go
+// Implements the sql command exporting the rules as SQL WHERE clauses
+package main
+
+import (
+	"encoding/json"
+	"flag"
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+	"strings"
+
+	"github.com/praks-1529/gorule"
+)
+
+// ruleSQL represents the WHERE clause of a rule in the JSON output
+type ruleSQL struct {
+	Rule string `json:"rule"`
+	*gorule.SQLWhere
+}
+
+func runSQL(args []string, stdin io.Reader, stdout io.Writer, stderr io.Writer) int {
+	flags := flag.NewFlagSet("sql", flag.ContinueOnError)
+	flags.SetOutput(stderr)
+	dialect := flags.String("dialect", "jsonb", "how the fields are read: jsonb (PostgreSQL JSONB column) or columns")
+	column := flags.String("column", "data", "JSONB column holding the payload")
+	columnsFile := flags.String("columns", "", "JSON file mapping the paths of the fields to columns (columns dialect)")
+	placeholder := flags.String("placeholder", "dollar", "placeholders of the arguments: dollar ($1) or question (?)")
+	jsonOutput := flags.Bool("json", false, "print the clauses as JSON")
+	flags.Usage = func() {
+		fmt.Fprintln(stderr, "Usage: gorule sql [-dialect jsonb|columns] [-column data] [-columns file] [-placeholder dollar|question] [-json] rules")
+		fmt.Fprintln(stderr, "Prints the parameterized WHERE clause of every rule along with its arguments")
+		flags.PrintDefaults()
+	}
+	if err := flags.Parse(args); err != nil {
+		return 2
+	}
+	opts := gorule.SQLOptions{Column: *column}
+	switch *dialect {
+	case "jsonb":
+		opts.Dialect = gorule.JSONBDialect
+	case "columns":
+		opts.Dialect = gorule.ColumnsDialect
+	default:
+		flags.Usage()
+		return 2
+	}
+	switch *placeholder {
+	case "dollar":
+		opts.Placeholder = gorule.DollarPlaceholder
+	case "question":
+		opts.Placeholder = gorule.QuestionPlaceholder
+	default:
+		flags.Usage()
+		return 2
+	}
+	if flags.NArg() != 1 {
+		flags.Usage()
+		return 2
+	}
+	if *columnsFile != "" {
+		data, err := os.ReadFile(*columnsFile)
+		if err == nil {
+			err = json.Unmarshal(data, &opts.Columns)
+		}
+		if err != nil {
+			fmt.Fprintln(stderr, "gorule:", err)
+			return 1
+		}
+	}
+	rulesFile := flags.Arg(0)
+	src, source, err := readRuleSource(rulesFile)
+	if err != nil {
+		printSourceError(stderr, rulesFile, src, err)
+		return 1
+	}
+	ruleSet := source.rules(strings.TrimSuffix(filepath.Base(rulesFile), filepath.Ext(rulesFile)))
+	exitCode := 0
+	clauses := []ruleSQL{}
+	for _, name := range ruleSet.Names() {
+		rule, _ := ruleSet.Get(name)
+		where, err := gorule.ExportSQL(rule, opts)
+		if err != nil {
+			fmt.Fprintf(stderr, "%s: %s\n", rulesFile, err)
+			exitCode = 1
+			continue
+		}
+		clauses = append(clauses, ruleSQL{Rule: name, SQLWhere: where})
+	}
+	if *jsonOutput {
+		encoder := json.NewEncoder(stdout)
+		encoder.SetIndent("", "  ")
+		encoder.Encode(clauses)
+		return exitCode
+	}
+	printSQL(stdout, clauses)
+	return exitCode
+}
+
+// printSQL prints the clause of every rule between comments
+//
+//	-- large
+//	(data #>> '{amount}')::numeric > $1
+//	-- args: [1000]
+func printSQL(w io.Writer, clauses []ruleSQL) {
+	for _, clause := range clauses {
+		args, _ := json.Marshal(clause.Args)
+		fmt.Fprintf(w, "-- %s\n%s\n-- args: %s\n", clause.Rule, clause.Clause, args)
+	}
+}
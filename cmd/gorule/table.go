@@ -0,0 +1,155 @@
+// File: table.go
+// Implements the table command evaluating CSV decision tables against JSON payloads
+package main
+
+import (
+	"encoding/json"
+	"flag"
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+	"strings"
+
+	"github.com/praks-1529/gorule"
+)
+
+// tableResult represents the outcome of evaluating the table for one document
+type tableResult struct {
+	Document int `json:"document"`
+	*gorule.DecisionResult
+	Error string `json:"error,omitempty"`
+}
+
+func runTable(args []string, stdin io.Reader, stdout io.Writer, stderr io.Writer) int {
+	flags := flag.NewFlagSet("table", flag.ContinueOnError)
+	flags.SetOutput(stderr)
+	hitPolicyName := flags.String("hit", "first", "hit policy: first, unique or collect")
+	jsonOutput := flags.Bool("json", false, "print one JSON result per document (NDJSON)")
+	printRules := flags.Bool("rules", false, "print the rules of the rows instead of evaluating them")
+	flags.Usage = func() {
+		fmt.Fprintln(stderr, "Usage: gorule table [-hit first|unique|collect] [-json] [-rules] table.csv [input]")
+		fmt.Fprintln(stderr, "Evaluates the decision table for every document of the input (JSON or NDJSON, default stdin)")
+		fmt.Fprintln(stderr, "and prints the matching rows with their outputs")
+		flags.PrintDefaults()
+	}
+	if err := flags.Parse(args); err != nil {
+		return 2
+	}
+	hitPolicy, err := gorule.ParseHitPolicy(*hitPolicyName)
+	if err != nil {
+		fmt.Fprintln(stderr, "gorule:", err)
+		return 2
+	}
+	if flags.NArg() < 1 || flags.NArg() > 2 || (*printRules && flags.NArg() != 1) {
+		flags.Usage()
+		return 2
+	}
+	tableFile := flags.Arg(0)
+	file, err := os.Open(tableFile)
+	if err != nil {
+		fmt.Fprintln(stderr, "gorule:", err)
+		return 1
+	}
+	defer file.Close()
+	table, err := gorule.LoadDecisionTable(strings.TrimSuffix(filepath.Base(tableFile), filepath.Ext(tableFile)), file, hitPolicy)
+	if err != nil {
+		fmt.Fprintf(stderr, "%s: %s\n", tableFile, err)
+		return 1
+	}
+	if *printRules {
+		fmt.Fprint(stdout, gorule.FormatRuleSet(table.RuleSet()))
+		return 0
+	}
+	if hitPolicy == gorule.UniqueHitPolicy {
+		// Report the rows which may break the policy before evaluating them
+		for _, relation := range table.Overlaps() {
+			row1, _ := table.Row(relation.Rule1)
+			row2, _ := table.Row(relation.Rule2)
+			if !relation.Verified {
+				// The witness is not a match of both, do not give it as an example
+				fmt.Fprintf(stderr, "%s: warning: rows %d and %d may both match (not all the conditions are analysed)\n", tableFile, row1, row2)
+				continue
+			}
+			witness, _ := json.Marshal(relation.Witness)
+			fmt.Fprintf(stderr, "%s: warning: rows %d and %d may both match, ex. %s\n", tableFile, row1, row2, witness)
+		}
+	}
+	var input []byte
+	if inputFile := flags.Arg(1); inputFile == "" || inputFile == "-" {
+		input, err = io.ReadAll(stdin)
+	} else {
+		input, err = os.ReadFile(inputFile)
+	}
+	if err != nil {
+		fmt.Fprintln(stderr, "gorule:", err)
+		return 1
+	}
+	documents, err := splitDocuments(input)
+	if err != nil {
+		fmt.Fprintln(stderr, "gorule: input", err)
+		return 1
+	}
+	engine := gorule.NewRuleEngine()
+	encoder := json.NewEncoder(stdout)
+	exitCode := 0
+	for i, document := range documents {
+		result := tableResult{Document: i + 1}
+		// A document with fields of unexpected types fails alone
+		if err := gorule.SafeEvaluate(func() (err error) {
+			result.DecisionResult, err = engine.EvaluateDecisionTable(table, document)
+			return err
+		}); err != nil {
+			result.Error = err.Error()
+			exitCode = 1
+		}
+		if *jsonOutput {
+			encoder.Encode(result)
+		} else {
+			printTableResult(stdout, table, result)
+		}
+	}
+	return exitCode
+}
+
+// printTableResult prints the matching rows of a document with their outputs
+// in the order of the columns
+//
+//	[1] row 2: discount=0.1
+//	[1] row 3: discount=0.05 label="medium"
+func printTableResult(w io.Writer, table *gorule.DecisionTable, result tableResult) {
+	if result.Error != "" {
+		fmt.Fprintf(w, "[%d] error: %s\n", result.Document, result.Error)
+		return
+	}
+	if len(result.Rows) == 0 {
+		fmt.Fprintf(w, "[%d] no match\n", result.Document)
+		return
+	}
+	for i, row := range result.Rows {
+		var outputs []string
+		for _, path := range table.Outputs {
+			if value, ok := outputValue(result.Outputs[i], path); ok {
+				text, _ := json.Marshal(value)
+				outputs = append(outputs, fmt.Sprintf("%s=%s", path, text))
+			}
+		}
+		fmt.Fprintf(w, "[%d] row %d: %s\n", result.Document, row, strings.Join(outputs, " "))
+	}
+}
+
+// outputValue returns the value of the output at path from the outputs nested
+// by the dots of the paths
+func outputValue(outputs map[string]interface{}, path string) (interface{}, bool) {
+	var value interface{} = outputs
+	for _, part := range strings.Split(path, ".") {
+		object, ok := value.(map[string]interface{})
+		if !ok {
+			return nil, false
+		}
+		if value, ok = object[part]; !ok {
+			return nil, false
+		}
+	}
+	return value, true
+}
@@ -0,0 +1,146 @@
+// File: test.go
+// Implements the test command running the rule test fixtures
+package main
+
+import (
+	"encoding/json"
+	"flag"
+	"fmt"
+	"io"
+	"io/fs"
+	"os"
+	"path/filepath"
+	"strings"
+
+	"github.com/praks-1529/gorule"
+)
+
+// fixtureSuffixes are the suffixes of the fixtures picked from a directory
+var fixtureSuffixes = []string{"_test.yaml", "_test.yml", "_test.json"}
+
+func runTest(args []string, stdin io.Reader, stdout io.Writer, stderr io.Writer) int {
+	flags := flag.NewFlagSet("test", flag.ContinueOnError)
+	flags.SetOutput(stderr)
+	jsonOutput := flags.Bool("json", false, "print the reports as a JSON array")
+	verbose := flags.Bool("v", false, "list the passing cases too")
+	cover := flags.Bool("cover", false, "report the coverage of the conditions over the inputs of the cases")
+	flags.Usage = func() {
+		fmt.Fprintln(stderr, "Usage: gorule test [-json] [-v] [-cover] [fixtures or directories]")
+		fmt.Fprintf(stderr, "Runs the test fixtures, directories are searched for %s files (default .)\n", strings.Join(fixtureSuffixes, ", "))
+		flags.PrintDefaults()
+	}
+	if err := flags.Parse(args); err != nil {
+		return 2
+	}
+	paths, err := findFixtures(flags.Args())
+	if err != nil {
+		fmt.Fprintln(stderr, "gorule:", err)
+		return 1
+	}
+	if len(paths) == 0 {
+		fmt.Fprintln(stderr, "gorule: no test fixture found")
+		return 1
+	}
+	exitCode := 0
+	reports := []*gorule.TestReport{}
+	passed, failed := 0, 0
+	for _, path := range paths {
+		report, err := runFixture(path, *cover)
+		if err != nil {
+			fmt.Fprintf(stdout, "FAIL  %s\n      %s\n", path, err)
+			exitCode = 1
+			continue
+		}
+		reports = append(reports, report)
+		failed += report.Failed()
+		passed += len(report.Results) - report.Failed()
+		if !report.Passed() {
+			exitCode = 1
+		}
+		if !*jsonOutput {
+			printTestReport(stdout, report, *verbose)
+			if report.Coverage != nil {
+				report.Coverage.WriteAnnotated(stdout)
+			}
+		}
+	}
+	if *jsonOutput {
+		encoder := json.NewEncoder(stdout)
+		encoder.SetIndent("", "  ")
+		encoder.Encode(reports)
+		return exitCode
+	}
+	fmt.Fprintf(stdout, "%d passed, %d failed\n", passed, failed)
+	return exitCode
+}
+
+func runFixture(path string, cover bool) (*gorule.TestReport, error) {
+	suite, err := gorule.LoadTestSuite(path)
+	if err != nil {
+		return nil, err
+	}
+	suite.Cover = cover
+	return suite.Run()
+}
+
+// printTestReport prints the failed cases (and the passed cases if verbose)
+// followed by the summary of the fixture
+//
+//	FAIL  transaction_test.yaml  small debit card
+//	      match: expected false got true (winners [credit_card])
+//	FAIL  transaction_test.yaml  2 of 3 cases passed
+func printTestReport(w io.Writer, report *gorule.TestReport, verbose bool) {
+	for _, result := range report.Results {
+		if result.Passed {
+			if verbose {
+				fmt.Fprintf(w, "PASS  %s  %s\n", report.Suite, result.Name)
+			}
+			continue
+		}
+		fmt.Fprintf(w, "FAIL  %s  %s\n", report.Suite, result.Name)
+		for _, failure := range result.Failures {
+			fmt.Fprintf(w, "      %s\n", failure)
+		}
+	}
+	total := len(report.Results)
+	if report.Passed() {
+		fmt.Fprintf(w, "ok    %s  %d cases\n", report.Suite, total)
+	} else {
+		fmt.Fprintf(w, "FAIL  %s  %d of %d cases passed\n", report.Suite, total-report.Failed(), total)
+	}
+}
+
+// findFixtures returns the fixtures of the arguments. Directories are searched
+// recursively for the files ending with one of fixtureSuffixes
+func findFixtures(args []string) ([]string, error) {
+	if len(args) == 0 {
+		args = []string{"."}
+	}
+	var paths []string
+	for _, arg := range args {
+		info, err := os.Stat(arg)
+		if err != nil {
+			return nil, err
+		}
+		if !info.IsDir() {
+			paths = append(paths, arg)
+			continue
+		}
+		err = filepath.WalkDir(arg, func(path string, entry fs.DirEntry, err error) error {
+			if err != nil || entry.IsDir() {
+				return err
+			}
+			for _, suffix := range fixtureSuffixes {
+				if strings.HasSuffix(path, suffix) {
+					paths = append(paths, path)
+					break
+				}
+			}
+			return nil
+		})
+		if err != nil {
+			return nil, err
+		}
+	}
+	return paths, nil
+}
@@ -0,0 +1,583 @@
+// File: codegen.go
+// Generates Go source from rules operating on a user supplied struct
+package gorule
+
+import (
+	"bytes"
+	"fmt"
+	"go/ast"
+	"go/format"
+	"go/parser"
+	"go/token"
+	"os"
+	"path/filepath"
+	"reflect"
+	"strconv"
+	"strings"
+	"unicode"
+)
+
+// CodegenError raised when a rule cannot be generated as Go code
+type CodegenError struct {
+	Rule    string
+	Message string
+}
+
+func (_rt *CodegenError) Error() string {
+	if _rt.Rule == "" {
+		return fmt.Sprintf("Codegen error : %s", _rt.Message)
+	}
+	return fmt.Sprintf("Codegen error in rule %s : %s", _rt.Rule, _rt.Message)
+}
+
+// GoGenOptions represents the options of the Go code generation
+type GoGenOptions struct {
+	// Dir is the directory of the Go package holding the struct. The code is
+	// generated in the same package
+	Dir string
+	// TypeName is the name of the struct the rules operate on
+	TypeName string
+	// RuleSource is the source of the rules, embedded in the generated tests
+	RuleSource string
+	// Samples holds the JSON payloads the generated tests check the parity with
+	// the interpreter on (zero value of the struct if empty)
+	Samples [][]byte
+}
+
+// GeneratedGo represents the generated Go source
+type GeneratedGo struct {
+	Package  string
+	Code     []byte
+	TestCode []byte
+}
+
+const (
+	goBoolKind   = "bool"
+	goIntKind    = "int"
+	goFloatKind  = "float"
+	goStringKind = "string"
+)
+
+// goType represents the shape of a Go type of the struct
+type goType struct {
+	name   string
+	kind   string
+	fields map[string]*goField
+	elem   *goType
+}
+
+type goField struct {
+	name string
+	typ  *goType
+}
+
+// goExpr represents a generated Go expression
+type goExpr struct {
+	code    string
+	kind    string
+	typ     string
+	literal bool
+}
+
+// goGenerator holds the state of the generation of one rule
+type goGenerator struct {
+	rule      string
+	root      *goType
+	indexVars map[string]string
+	depth     int
+}
+
+// GenerateGo generates a Go function per rule of the rule set operating on the
+// struct opts.TypeName, along with tests asserting the parity with the interpreter
+func GenerateGo(ruleSet *RuleSet, opts GoGenOptions) (*GeneratedGo, error) {
+	pkgName, types, err := loadGoTypes(opts.Dir)
+	if err != nil {
+		return nil, err
+	}
+	root, err := resolveGoType(&ast.Ident{Name: opts.TypeName}, types, map[string]*goType{})
+	if err != nil {
+		return nil, err
+	}
+	if root.kind != "struct" {
+		return nil, &CodegenError{Message: fmt.Sprintf("%s is not a struct", opts.TypeName)}
+	}
+	var code bytes.Buffer
+	fmt.Fprintf(&code, "// Code generated by gorule gen. DO NOT EDIT.\n\npackage %s\n\n", pkgName)
+	funcNames := make(map[string]string)
+	for _, name := range ruleSet.names {
+		funcName := goFuncName(name)
+		if _, ok := funcNames[funcName]; ok {
+			return nil, &CodegenError{Rule: name, Message: fmt.Sprintf("function %s already generated", funcName)}
+		}
+		funcNames[funcName] = name
+		generator := &goGenerator{rule: name, root: root, indexVars: make(map[string]string)}
+		funcCode, err := generator.generateRule(funcName, opts.TypeName, ruleSet.rules[name])
+		if err != nil {
+			return nil, err
+		}
+		code.WriteString(funcCode)
+	}
+	generated := &GeneratedGo{Package: pkgName}
+	if generated.Code, err = format.Source(code.Bytes()); err != nil {
+		return nil, err
+	}
+	if generated.TestCode, err = format.Source(generateGoTest(pkgName, ruleSet, opts)); err != nil {
+		return nil, err
+	}
+	return generated, nil
+}
+
+// goFuncName converts a rule name like high_value_cc to HighValueCc
+func goFuncName(name string) string {
+	var sb strings.Builder
+	for _, part := range strings.FieldsFunc(name, func(r rune) bool {
+		return !(unicode.IsLetter(r) || unicode.IsDigit(r))
+	}) {
+		runes := []rune(part)
+		sb.WriteString(strings.ToUpper(string(runes[0])) + string(runes[1:]))
+	}
+	funcName := sb.String()
+	if funcName == "" || unicode.IsDigit([]rune(funcName)[0]) {
+		funcName = "Rule" + funcName
+	}
+	return funcName
+}
+
+func (_g *goGenerator) errorf(format string, args ...interface{}) error {
+	return &CodegenError{Rule: _g.rule, Message: fmt.Sprintf(format, args...)}
+}
+
+func (_g *goGenerator) generateRule(funcName string, typeName string, rule Rule) (string, error) {
+	var sb strings.Builder
+	metadata := rule.GetMetadata()
+	fmt.Fprintf(&sb, "// %s evaluates the rule %q\n", funcName, _g.rule)
+	if metadata.GetDescription() != "" {
+		fmt.Fprintf(&sb, "// %s\n", metadata.GetDescription())
+	}
+	switch fgRule := rule.(type) {
+	case *ScalarRule:
+		cond, err := _g.generatePredicate(fgRule.If)
+		if err != nil {
+			return "", err
+		}
+		fmt.Fprintf(&sb, "func %s(in *%s) bool {\n\treturn %s\n}\n\n", funcName, typeName, cond)
+	case *VectorRule:
+		scalarRule, ok := fgRule.SRule.(*ScalarRule)
+		if !ok {
+			return "", _g.errorf("malformed vector rule")
+		}
+		indexVar, start, end, err := _g.enterLoop(fmt.Sprintf("%v", fgRule.IndexKey), fgRule.StartIndex, fgRule.EndIndex)
+		if err != nil {
+			return "", err
+		}
+		cond, err := _g.generatePredicate(scalarRule.If)
+		if err != nil {
+			return "", err
+		}
+		_g.exitLoop(fmt.Sprintf("%v", fgRule.IndexKey))
+		fmt.Fprintf(&sb, "func %s(in *%s) []bool {\n\tvar result []bool\n\tfor %s := %s; %s < %s; %s++ {\n\t\tresult = append(result, %s)\n\t}\n\treturn result\n}\n\n",
+			funcName, typeName, indexVar, start, indexVar, end, indexVar, cond)
+	default:
+		return "", _g.errorf("unsupported rule type %T", rule)
+	}
+	return sb.String(), nil
+}
+
+// enterLoop binds the index key of a loop like i=0:a.size() to a Go variable
+func (_g *goGenerator) enterLoop(indexKey string, startIndex interface{}, endIndex interface{}) (string, string, string, error) {
+	start, ok := StringToInterface(fmt.Sprintf("%v", startIndex)).(int)
+	if !ok {
+		return "", "", "", _g.errorf("start index %v is not an integer", startIndex)
+	}
+	endKey := fmt.Sprintf("%v", endIndex)
+	if !strings.HasSuffix(endKey, ".size()") {
+		return "", "", "", _g.errorf("end index %s is not a size()", endKey)
+	}
+	array, err := _g.generatePath(strings.TrimSuffix(endKey, ".size()"))
+	if err != nil {
+		return "", "", "", err
+	}
+	if array.kind != "slice" {
+		return "", "", "", _g.errorf("%s is not an array", strings.TrimSuffix(endKey, ".size()"))
+	}
+	indexVar := fmt.Sprintf("%s%d", indexKey, _g.depth)
+	_g.indexVars[indexKey] = indexVar
+	_g.depth++
+	return indexVar, strconv.Itoa(start), fmt.Sprintf("len(%s)", array.code), nil
+}
+
+func (_g *goGenerator) exitLoop(indexKey string) {
+	delete(_g.indexVars, indexKey)
+	_g.depth--
+}
+
+// generatePredicate generates a Go expression of type bool
+func (_g *goGenerator) generatePredicate(condition Condition) (string, error) {
+	expr, err := _g.generateExpr(condition)
+	if err != nil {
+		return "", err
+	}
+	if expr.kind != goBoolKind {
+		return "", _g.errorf("%s is not a bool", expr.code)
+	}
+	return expr.code, nil
+}
+
+func (_g *goGenerator) generateExpr(condition Condition) (*goExpr, error) {
+	switch cond := condition.(type) {
+	case *VectorCondition:
+		indexVar, start, end, err := _g.enterLoop(cond.IndexKey, cond.StartIndex, cond.EndIndex)
+		if err != nil {
+			return nil, err
+		}
+		inner, err := _g.generatePredicate(cond.SCondition)
+		if err != nil {
+			return nil, err
+		}
+		_g.exitLoop(cond.IndexKey)
+		code := fmt.Sprintf("func() bool {\n\tfor %s := %s; %s < %s; %s++ {\n\t\tif !(%s) {\n\t\t\treturn false\n\t\t}\n\t}\n\treturn true\n}()",
+			indexVar, start, indexVar, end, indexVar, inner)
+		return &goExpr{code: code, kind: goBoolKind}, nil
+	case *ScalarCondition:
+		if cond.GetOperator() == NilOperator {
+			return _g.generateLeaf(cond.GetValue())
+		}
+		expr1, err := _g.generateExpr(cond.GetOperand1())
+		if err != nil {
+			return nil, err
+		}
+		expr2, err := _g.generateExpr(cond.GetOperand2())
+		if err != nil {
+			return nil, err
+		}
+		return _g.generateOperation(expr1, expr2, cond.GetOperator())
+	case *LogicalCondition:
+		expr := &goExpr{code: strconv.FormatBool(cond.GetOperator() == AndOperator), kind: goBoolKind, literal: true}
+		for i, operand := range cond.GetOperands() {
+			operandExpr, err := _g.generateExpr(operand)
+			if err != nil {
+				return nil, err
+			}
+			if i == 0 {
+				expr = operandExpr
+				continue
+			}
+			if expr, err = _g.generateOperation(expr, operandExpr, cond.GetOperator()); err != nil {
+				return nil, err
+			}
+		}
+		return expr, nil
+	}
+	return nil, _g.errorf("unsupported condition %T", condition)
+}
+
+func (_g *goGenerator) generateLeaf(value interface{}) (*goExpr, error) {
+	switch literal := value.(type) {
+	case bool:
+		return &goExpr{code: strconv.FormatBool(literal), kind: goBoolKind, literal: true}, nil
+	case int:
+		return &goExpr{code: strconv.Itoa(literal), kind: goIntKind, literal: true}, nil
+	case float64:
+		code := strconv.FormatFloat(literal, 'g', -1, 64)
+		if !strings.ContainsAny(code, ".e") {
+			code += ".0"
+		}
+		return &goExpr{code: code, kind: goFloatKind, literal: true}, nil
+	case string:
+		if strings.HasPrefix(literal, "\"") {
+			unquoted, err := strconv.Unquote(literal)
+			if err != nil {
+				return nil, _g.errorf("malformed string %s", literal)
+			}
+			return &goExpr{code: strconv.Quote(unquoted), kind: goStringKind, literal: true}, nil
+		}
+		expr, err := _g.generatePath(literal)
+		if err != nil {
+			return nil, err
+		}
+		if expr.kind == "struct" || expr.kind == "slice" {
+			return nil, _g.errorf("%s is not a scalar field", literal)
+		}
+		return expr, nil
+	}
+	return nil, _g.errorf("unsupported value %v", value)
+}
+
+// generatePath resolves a path like a[i].b.0.c to the fields of the struct
+func (_g *goGenerator) generatePath(key string) (*goExpr, error) {
+	code := "in"
+	typ := _g.root
+	for _, part := range strings.Split(key, ".") {
+		index := ""
+		if open := strings.Index(part, "["); open >= 0 && strings.HasSuffix(part, "]") {
+			index = part[open+1 : len(part)-1]
+			part = part[:open]
+		}
+		if _, err := strconv.Atoi(part); err == nil && typ.kind == "slice" {
+			// Array index written as a.0.b
+			code = fmt.Sprintf("%s[%s]", code, part)
+			typ = typ.elem
+			continue
+		}
+		if typ.kind != "struct" {
+			return nil, _g.errorf("unknown field %s in path %s", part, key)
+		}
+		field, ok := typ.fields[part]
+		if !ok {
+			return nil, _g.errorf("unknown field %s in path %s", part, key)
+		}
+		code = fmt.Sprintf("%s.%s", code, field.name)
+		typ = field.typ
+		if index != "" {
+			if typ.kind != "slice" {
+				return nil, _g.errorf("%s is not an array in path %s", part, key)
+			}
+			indexVar, ok := _g.indexVars[index]
+			if !ok {
+				if _, err := strconv.Atoi(index); err != nil {
+					return nil, _g.errorf("unknown index %s in path %s", index, key)
+				}
+				indexVar = index
+			}
+			code = fmt.Sprintf("%s[%s]", code, indexVar)
+			typ = typ.elem
+		}
+	}
+	return &goExpr{code: code, kind: typ.kind, typ: typ.name}, nil
+}
+
+func (_g *goGenerator) generateOperation(expr1 *goExpr, expr2 *goExpr, optor Operator) (*goExpr, error) {
+	isNumber := func(kind string) bool {
+		return kind == goIntKind || kind == goFloatKind
+	}
+	switch {
+	case optor == AndOperator || optor == OrOperator:
+		if expr1.kind != goBoolKind || expr2.kind != goBoolKind {
+			return nil, _g.errorf("%s needs bool operands (%s, %s)", optor, expr1.code, expr2.code)
+		}
+	case expr1.kind == goBoolKind && expr2.kind == goBoolKind:
+		if optor != EqualOperator {
+			return &goExpr{code: "false", kind: goBoolKind}, nil
+		}
+	case expr1.kind == goStringKind && expr2.kind == goStringKind:
+		if optor != EqualOperator {
+			// Only == is supported on strings
+			return &goExpr{code: "false", kind: goBoolKind}, nil
+		}
+		if !(expr1.literal || expr2.literal) && expr1.typ != expr2.typ {
+			return &goExpr{code: fmt.Sprintf("(string(%s) == string(%s))", expr1.code, expr2.code), kind: goBoolKind}, nil
+		}
+	case isNumber(expr1.kind) && isNumber(expr2.kind):
+		if needsFloatConversion(expr1, expr2) {
+			// Mixed types are compared as float64
+			return &goExpr{code: fmt.Sprintf("(float64(%s) %s float64(%s))", expr1.code, optor, expr2.code), kind: goBoolKind}, nil
+		}
+	default:
+		return nil, _g.errorf("incompatible types %s (%s) and %s (%s)", expr1.code, expr1.kind, expr2.code, expr2.kind)
+	}
+	return &goExpr{code: fmt.Sprintf("(%s %s %s)", expr1.code, optor, expr2.code), kind: goBoolKind}, nil
+}
+
+// needsFloatConversion checks if the numbers are of different Go types
+func needsFloatConversion(expr1 *goExpr, expr2 *goExpr) bool {
+	switch {
+	case expr1.literal && expr2.literal:
+		return false
+	case expr1.literal:
+		return expr1.kind == goFloatKind && expr2.kind == goIntKind
+	case expr2.literal:
+		return expr2.kind == goFloatKind && expr1.kind == goIntKind
+	}
+	return expr1.typ != expr2.typ
+}
+
+// loadGoTypes parses the Go package in dir and returns its type declarations
+func loadGoTypes(dir string) (string, map[string]ast.Expr, error) {
+	entries, err := os.ReadDir(dir)
+	if err != nil {
+		return "", nil, err
+	}
+	fset := token.NewFileSet()
+	types := make(map[string]ast.Expr)
+	var pkgName string
+	for _, entry := range entries {
+		name := entry.Name()
+		if entry.IsDir() || !strings.HasSuffix(name, ".go") || strings.HasSuffix(name, "_test.go") {
+			continue
+		}
+		file, err := parser.ParseFile(fset, filepath.Join(dir, name), nil, 0)
+		if err != nil {
+			return "", nil, err
+		}
+		if pkgName != "" && pkgName != file.Name.Name {
+			return "", nil, &CodegenError{Message: fmt.Sprintf("expecting one package in %s found %s and %s", dir, pkgName, file.Name.Name)}
+		}
+		pkgName = file.Name.Name
+		for _, decl := range file.Decls {
+			genDecl, ok := decl.(*ast.GenDecl)
+			if !ok || genDecl.Tok != token.TYPE {
+				continue
+			}
+			for _, spec := range genDecl.Specs {
+				typeSpec := spec.(*ast.TypeSpec)
+				types[typeSpec.Name.Name] = typeSpec.Type
+			}
+		}
+	}
+	if pkgName == "" {
+		return "", nil, &CodegenError{Message: fmt.Sprintf("no Go package found in %s", dir)}
+	}
+	return pkgName, types, nil
+}
+
+// resolveGoType resolves the shape of the Go type expression
+func resolveGoType(expr ast.Expr, types map[string]ast.Expr, resolved map[string]*goType) (*goType, error) {
+	switch typeExpr := expr.(type) {
+	case *ast.Ident:
+		switch typeExpr.Name {
+		case "bool":
+			return &goType{name: typeExpr.Name, kind: goBoolKind}, nil
+		case "string":
+			return &goType{name: typeExpr.Name, kind: goStringKind}, nil
+		case "int", "int8", "int16", "int32", "int64", "uint", "uint8", "uint16", "uint32", "uint64":
+			return &goType{name: typeExpr.Name, kind: goIntKind}, nil
+		case "float32", "float64":
+			return &goType{name: typeExpr.Name, kind: goFloatKind}, nil
+		}
+		if typ, ok := resolved[typeExpr.Name]; ok {
+			return typ, nil
+		}
+		declExpr, ok := types[typeExpr.Name]
+		if !ok {
+			return nil, &CodegenError{Message: fmt.Sprintf("type %s not found", typeExpr.Name)}
+		}
+		if structExpr, ok := declExpr.(*ast.StructType); ok {
+			// Register before resolving the fields to support recursive types
+			typ := &goType{name: typeExpr.Name, kind: "struct"}
+			resolved[typeExpr.Name] = typ
+			return typ, resolveGoFields(typ, structExpr, types, resolved)
+		}
+		typ, err := resolveGoType(declExpr, types, resolved)
+		if err != nil {
+			return nil, err
+		}
+		if typ.kind != "struct" && typ.kind != "slice" {
+			// Named basic type ex. type Currency string
+			typ = &goType{name: typeExpr.Name, kind: typ.kind}
+		}
+		resolved[typeExpr.Name] = typ
+		return typ, nil
+	case *ast.StructType:
+		typ := &goType{kind: "struct"}
+		return typ, resolveGoFields(typ, typeExpr, types, resolved)
+	case *ast.ArrayType:
+		elem, err := resolveGoType(typeExpr.Elt, types, resolved)
+		if err != nil {
+			return nil, err
+		}
+		return &goType{kind: "slice", elem: elem}, nil
+	}
+	return nil, &CodegenError{Message: fmt.Sprintf("unsupported field type %T", expr)}
+}
+
+// resolveGoFields resolves the fields of a struct by their JSON names
+func resolveGoFields(typ *goType, structExpr *ast.StructType, types map[string]ast.Expr, resolved map[string]*goType) error {
+	typ.fields = make(map[string]*goField)
+	for _, field := range structExpr.Fields.List {
+		for _, name := range field.Names {
+			if !name.IsExported() {
+				continue
+			}
+			jsonName := name.Name
+			if field.Tag != nil {
+				tag, _ := strconv.Unquote(field.Tag.Value)
+				jsonTag := strings.Split(reflect.StructTag(tag).Get("json"), ",")[0]
+				if jsonTag == "-" {
+					continue
+				}
+				if jsonTag != "" {
+					jsonName = jsonTag
+				}
+			}
+			fieldType, err := resolveGoType(field.Type, types, resolved)
+			if err != nil {
+				return &CodegenError{Message: fmt.Sprintf("field %s : %s", name.Name, err)}
+			}
+			typ.fields[jsonName] = &goField{name: name.Name, typ: fieldType}
+		}
+	}
+	return nil
+}
+
+// generateGoTest generates the tests asserting the parity of the generated
+// functions with the interpreter
+func generateGoTest(pkgName string, ruleSet *RuleSet, opts GoGenOptions) []byte {
+	var sb strings.Builder
+	fmt.Fprintf(&sb, "// Code generated by gorule gen. DO NOT EDIT.\n\npackage %s\n\n", pkgName)
+	sb.WriteString("import (\n\t\"encoding/json\"\n\t\"reflect\"\n\t\"testing\"\n\n\t\"github.com/praks-1529/gorule\"\n)\n\n")
+	fmt.Fprintf(&sb, "const goruleSource = %s\n\n", strconv.Quote(opts.RuleSource))
+	sb.WriteString("var goruleSamples = []string{\n")
+	for _, sample := range opts.Samples {
+		fmt.Fprintf(&sb, "\t%s,\n", strconv.Quote(string(sample)))
+	}
+	sb.WriteString("}\n\n")
+	fmt.Fprintf(&sb, `func goruleParityInputs(t *testing.T) ([][]byte, []*%s) {
+	samples := goruleSamples
+	if len(samples) == 0 {
+		zero, err := json.Marshal(%s{})
+		if err != nil {
+			t.Fatal(err)
+		}
+		samples = []string{string(zero)}
+	}
+	var inputs []*%s
+	var payloads [][]byte
+	for _, sample := range samples {
+		in := &%s{}
+		if err := json.Unmarshal([]byte(sample), in); err != nil {
+			t.Fatal(err)
+		}
+		inputs = append(inputs, in)
+		payloads = append(payloads, []byte(sample))
+	}
+	return payloads, inputs
+}
+
+func goruleParityRule(t *testing.T, name string) gorule.Rule {
+	ruleSet, err := gorule.NewRuleParser(goruleSource).ParseRuleSet()
+	if err != nil {
+		t.Fatal(err)
+	}
+	rule, ok := ruleSet.Get(name)
+	if !ok {
+		t.Fatalf("rule %%s not found", name)
+	}
+	return rule
+}
+
+`, opts.TypeName, opts.TypeName, opts.TypeName, opts.TypeName)
+	for _, name := range ruleSet.names {
+		funcName := goFuncName(name)
+		generated := fmt.Sprintf("%s(inputs[i])", funcName)
+		if ruleSet.rules[name].GetType() == ScalarRuleType {
+			generated = fmt.Sprintf("[]bool{%s(inputs[i])}", funcName)
+		}
+		fmt.Fprintf(&sb, `func Test%sParity(t *testing.T) {
+	rule := goruleParityRule(t, %q)
+	payloads, inputs := goruleParityInputs(t)
+	re := gorule.NewRuleEngine()
+	for i := range payloads {
+		expected, err := re.Evaluate(rule, payloads[i])
+		if err != nil {
+			t.Fatal(err)
+		}
+		if actual := %s; !reflect.DeepEqual(expected, actual) {
+			t.Errorf("sample %%d: interpreter %%v generated %%v", i, expected, actual)
+		}
+	}
+}
+
+`, funcName, name, generated)
+	}
+	return []byte(sb.String())
+}
@@ -0,0 +1,76 @@
+// File: codegen_test.go
+// Tests for Go code generation
+package gorule
+
+import (
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+const codegenTestModel = `package model
+
+type Currency string
+
+type Order struct {
+	Amount   int64     ` + "`json:\"amount\"`" + `
+	Limit    int       ` + "`json:\"limit\"`" + `
+	Score    float64   ` + "`json:\"score\"`" + `
+	Currency Currency  ` + "`json:\"currency\"`" + `
+	Internal string    ` + "`json:\"-\"`" + `
+	Items    []struct {
+		Qty int ` + "`json:\"qty\"`" + `
+	} ` + "`json:\"items\"`" + `
+}
+`
+
+func generateTestGo(t *testing.T, src string) (*GeneratedGo, error) {
+	dir := t.TempDir()
+	assert.Nil(t, os.WriteFile(filepath.Join(dir, "model.go"), []byte(codegenTestModel), 0o644))
+	ruleSet, err := NewRuleParser(src).ParseRuleSet()
+	assert.Nil(t, err)
+	return GenerateGo(ruleSet, GoGenOptions{Dir: dir, TypeName: "Order", RuleSource: src})
+}
+
+func TestGenerateGo(t *testing.T) {
+	generated, err := generateTestGo(t, `
+	RULE "limit_exceeded": IF: { amount > limit && score >= 0.5 && currency == "USD" }
+	RULE "bulk-items": IF: { FOR: i=0:items.size() { items[i].qty > 2.5 } }
+	RULE "2nd_item": IF: { items.1.qty == 1 }
+	`)
+	assert.Nil(t, err)
+	assert.Equal(t, "model", generated.Package)
+	code := string(generated.Code)
+	assert.Contains(t, code, "func LimitExceeded(in *Order) bool {")
+	assert.Contains(t, code, "(float64(in.Amount) > float64(in.Limit))")
+	assert.Contains(t, code, "(in.Score >= 0.5)")
+	assert.Contains(t, code, "(in.Currency == \"USD\")")
+	assert.Contains(t, code, "func BulkItems(in *Order) bool {")
+	assert.Contains(t, code, "(float64(in.Items[i0].Qty) > float64(2.5))")
+	assert.Contains(t, code, "func Rule2ndItem(in *Order) bool {")
+	assert.Contains(t, code, "(in.Items[1].Qty == 1)")
+	assert.Contains(t, string(generated.TestCode), "func TestLimitExceededParity(t *testing.T) {")
+}
+
+func TestGenerateGoErrors(t *testing.T) {
+	_, err := generateTestGo(t, `RULE "a": IF: { missing == 1 }`)
+	assert.IsType(t, &CodegenError{}, err)
+	_, err = generateTestGo(t, `RULE "a": IF: { internal == "x" }`)
+	assert.IsType(t, &CodegenError{}, err)
+	_, err = generateTestGo(t, `RULE "a": IF: { amount == "USD" }`)
+	assert.IsType(t, &CodegenError{}, err)
+	_, err = generateTestGo(t, `RULE "a": IF: { FOR: i=0:amount.size() { amount > 1 } }`)
+	assert.IsType(t, &CodegenError{}, err)
+	_, err = generateTestGo(t, `RULE "a": IF: { items == 1 }`)
+	assert.IsType(t, &CodegenError{}, err)
+	_, err = generateTestGo(t, `RULE "a_b": IF: { amount > 1 } RULE "a-b": IF: { amount > 2 }`)
+	assert.IsType(t, &CodegenError{}, err)
+}
+
+func TestGoFuncName(t *testing.T) {
+	assert.Equal(t, "HighValueCc", goFuncName("high_value_cc"))
+	assert.Equal(t, "Rule1stTier", goFuncName("1st tier"))
+	assert.Equal(t, "Rule", goFuncName("__"))
+}
@@ -0,0 +1,438 @@
+// File: compile.go
+// Compiles a parsed rule into a tree of closures
+package gorule
+
+import (
+	"fmt"
+	"strconv"
+	"strings"
+
+	"github.com/tidwall/gjson"
+)
+
+// indexPlaceholder marks the part of a compiled path replaced by the loop index
+const indexPlaceholder = "\x00"
+
+// compiledEnv represents the state of one evaluation of a compiled rule
+type compiledEnv struct {
+	doc    *Document
+	memory *WorkingMemory
+	index  int
+}
+
+type valueFunc func(env *compiledEnv) interface{}
+
+type predicateFunc func(env *compiledEnv) bool
+
+// compiledPath represents a field path (ex. a[i].b) split into its parts
+type compiledPath struct {
+	key        string
+	arrayIndex string
+	parts      []string
+	indexPart  int
+	gjsonPath  bool
+}
+
+func newCompiledPath(key string, indexKey string) *compiledPath {
+	path := &compiledPath{key: key, indexPart: -1, gjsonPath: isGJSONPath(key)}
+	if indexKey != "" && hasArrayIndex(key) {
+		path.arrayIndex = fmt.Sprintf("[%s]", indexKey)
+	}
+	parsableKey := key
+	if path.arrayIndex != "" {
+		parsableKey = strings.Replace(key, path.arrayIndex, "."+indexPlaceholder, 1)
+	}
+	path.parts = strings.Split(parsableKey, ".")
+	for i, part := range path.parts {
+		if part == indexPlaceholder {
+			path.indexPart = i
+		}
+	}
+	return path
+}
+
+// resolveKey returns the key with the array index replaced (ex. a[i].b => a.0.b)
+func (_cp *compiledPath) resolveKey(env *compiledEnv) string {
+	if _cp.indexPart < 0 {
+		return _cp.key
+	}
+	return strings.Replace(_cp.key, _cp.arrayIndex, "."+strconv.Itoa(env.index), 1)
+}
+
+func (_cp *compiledPath) result(env *compiledEnv) gjson.Result {
+	if _cp.gjsonPath {
+		return env.doc.Get(_cp.resolveKey(env))
+	}
+	return env.doc.getPath(_cp.parts, _cp.indexPart, strconv.Itoa(env.index))
+}
+
+// value returns the value of the field same as the interpreter does
+func (_cp *compiledPath) value(env *compiledEnv) interface{} {
+	if env.memory != nil {
+		if value, ok := env.memory.Get(_cp.resolveKey(env)); ok {
+			return value
+		}
+	}
+	result := _cp.result(env)
+	switch result.Type {
+	case gjson.String, gjson.Number, gjson.True, gjson.False:
+		return resultToInterface(_cp.key, result)
+	}
+	return resultToInterface(_cp.resolveKey(env), result)
+}
+
+// CompiledRule represents a rule compiled into a tree of pre-typed closures with
+// pre-resolved paths and operators. It evaluates the same as the parsed rule
+type CompiledRule struct {
+	rule     Rule
+	evaluate func(env *compiledEnv) []bool
+}
+
+// CompileRule compiles a parsed rule
+func CompileRule(rule Rule) (*CompiledRule, error) {
+	compiledRule := &CompiledRule{rule: rule}
+	switch fgRule := rule.(type) {
+	case *ScalarRule:
+		predicate, err := compilePredicate(fgRule.If, "")
+		if err != nil {
+			return nil, err
+		}
+		compiledRule.evaluate = func(env *compiledEnv) []bool {
+			return []bool{predicate(env)}
+		}
+	case *VectorRule:
+		scalarRule, ok := fgRule.SRule.(*ScalarRule)
+		if !ok {
+			return nil, &MalformedRuleError{}
+		}
+		indexKey := fmt.Sprintf("%v", fgRule.IndexKey)
+		predicate, err := compilePredicate(scalarRule.If, indexKey)
+		if err != nil {
+			return nil, err
+		}
+		startIndex, endPath, err := compileRange(fgRule.StartIndex, fgRule.EndIndex)
+		if err != nil {
+			return nil, err
+		}
+		compiledRule.evaluate = func(env *compiledEnv) []bool {
+			var result []bool
+			endIndex := resultLength(endPath.result(env))
+			for i := startIndex; i < endIndex; i++ {
+				env.index = i
+				result = append(result, predicate(env))
+			}
+			return result
+		}
+	default:
+		return nil, &MalformedRuleError{}
+	}
+	return compiledRule, nil
+}
+
+// GetType gets the type of the compiled rule
+func (_cr *CompiledRule) GetType() RuleType {
+	return _cr.rule.GetType()
+}
+
+// GetMetadata gets the header information of the compiled rule
+func (_cr *CompiledRule) GetMetadata() *RuleMetadata {
+	return _cr.rule.GetMetadata()
+}
+
+// GetRule returns the parsed rule the compiled rule was created from
+func (_cr *CompiledRule) GetRule() Rule {
+	return _cr.rule
+}
+
+// BuildContext attaches the input data to the context
+func (_cr *CompiledRule) BuildContext(ipData []byte, ctx Context) error {
+	ctx.SetValue(InputDataKey, ParseDocument(ipData))
+	return nil
+}
+
+// Evaluate evaluates the compiled rule for the input document (and working
+// memory) attached to the context
+func (_cr *CompiledRule) Evaluate(ctx Context) (interface{}, error) {
+	doc, ok := ctx.GetValue(InputDataKey).(*Document)
+	if !ok {
+		doc = ParseDocument(nil)
+	}
+	env := &compiledEnv{doc: doc}
+	env.memory, _ = ctx.GetValue(WorkingMemoryKey).(*WorkingMemory)
+	return _cr.evaluate(env), nil
+}
+
+// EvaluateDocument evaluates the compiled rule for an already parsed document
+// without building a context
+func (_cr *CompiledRule) EvaluateDocument(doc *Document) (interface{}, error) {
+	return _cr.evaluate(&compiledEnv{doc: doc}), nil
+}
+
+// compileRange compiles the range of a loop like i=0:a.size()
+func compileRange(startIndex interface{}, endIndex interface{}) (int, *compiledPath, error) {
+	startValue, ok := StringToInterface(fmt.Sprintf("%v", startIndex)).(int)
+	if !ok {
+		return 0, nil, &MalformedRuleError{}
+	}
+	endKey := fmt.Sprintf("%v", endIndex)
+	if !strings.HasSuffix(endKey, ".size()") {
+		return 0, nil, &MalformedRuleError{}
+	}
+	return startValue, newCompiledPath(strings.Replace(endKey, ".size()", "", 1), ""), nil
+}
+
+// isPredicate checks if the condition always evaluates to bool
+func isPredicate(condition Condition) bool {
+	if scalarCondition, ok := condition.(*ScalarCondition); ok {
+		return scalarCondition.GetOperator() != NilOperator
+	}
+	switch condition.(type) {
+	case *VectorCondition, *LogicalCondition:
+		return true
+	}
+	return false
+}
+
+// compilePredicate compiles a condition evaluating to bool
+func compilePredicate(condition Condition, indexKey string) (predicateFunc, error) {
+	switch cond := condition.(type) {
+	case *VectorCondition:
+		return compileVectorCondition(cond)
+	case *LogicalCondition:
+		return compileLogicalPredicate(cond, indexKey)
+	case *ScalarCondition:
+		if isPredicate(cond) {
+			return compileScalarPredicate(cond, indexKey)
+		}
+	}
+	value, err := compileValue(condition, indexKey)
+	if err != nil {
+		return nil, err
+	}
+	return func(env *compiledEnv) bool {
+		result, ok := value(env).(bool)
+		if !ok {
+			panic("Operands type not matching")
+		}
+		return result
+	}, nil
+}
+
+// compileValue compiles a condition into a closure returning its value
+func compileValue(condition Condition, indexKey string) (valueFunc, error) {
+	scalarCondition, ok := condition.(*ScalarCondition)
+	if !ok || isPredicate(condition) {
+		predicate, err := compilePredicate(condition, indexKey)
+		if err != nil {
+			return nil, err
+		}
+		return func(env *compiledEnv) interface{} {
+			return predicate(env)
+		}, nil
+	}
+	value := scalarCondition.GetValue()
+	if key, ok := value.(string); ok && !strings.HasPrefix(key, "\"") {
+		path := newCompiledPath(key, indexKey)
+		return path.value, nil
+	}
+	// Literals are resolved at compile time
+	return func(env *compiledEnv) interface{} {
+		return value
+	}, nil
+}
+
+func compileVectorCondition(cond *VectorCondition) (predicateFunc, error) {
+	predicate, err := compilePredicate(cond.SCondition, cond.IndexKey)
+	if err != nil {
+		return nil, err
+	}
+	startIndex, endPath, err := compileRange(cond.StartIndex, cond.EndIndex)
+	if err != nil {
+		return nil, err
+	}
+	return func(env *compiledEnv) bool {
+		outerIndex := env.index
+		result := true
+		endIndex := resultLength(endPath.result(env))
+		for i := startIndex; i < endIndex && result; i++ {
+			env.index = i
+			result = predicate(env)
+		}
+		env.index = outerIndex
+		return result
+	}, nil
+}
+
+func compileScalarPredicate(cond *ScalarCondition, indexKey string) (predicateFunc, error) {
+	optor := cond.GetOperator()
+	if (optor == AndOperator || optor == OrOperator) && isPredicate(cond.GetOperand1()) && isPredicate(cond.GetOperand2()) {
+		predicate1, err := compilePredicate(cond.GetOperand1(), indexKey)
+		if err != nil {
+			return nil, err
+		}
+		predicate2, err := compilePredicate(cond.GetOperand2(), indexKey)
+		if err != nil {
+			return nil, err
+		}
+		if optor == AndOperator {
+			return func(env *compiledEnv) bool {
+				return predicate1(env) && predicate2(env)
+			}, nil
+		}
+		return func(env *compiledEnv) bool {
+			return predicate1(env) || predicate2(env)
+		}, nil
+	}
+	value1, err := compileValue(cond.GetOperand1(), indexKey)
+	if err != nil {
+		return nil, err
+	}
+	value2, err := compileValue(cond.GetOperand2(), indexKey)
+	if err != nil {
+		return nil, err
+	}
+	generic := func(env *compiledEnv) bool {
+		lvalue := value1(env)
+		if result, ok := shortCircuit(lvalue, optor); ok {
+			return result
+		}
+		return EvaluateOperation(lvalue, value2(env), optor)
+	}
+	// Comparison of a field with a literal is done on the raw JSON value
+	// without converting it to interface
+	if path, constant, swapped, ok := fieldAndLiteral(cond, indexKey); ok {
+		return func(env *compiledEnv) bool {
+			if env.memory == nil {
+				if result, ok := compareTyped(path.result(env), constant, optor, swapped); ok {
+					return result
+				}
+			}
+			return generic(env)
+		}, nil
+	}
+	return generic, nil
+}
+
+func compileLogicalPredicate(cond *LogicalCondition, indexKey string) (predicateFunc, error) {
+	optor := cond.GetOperator()
+	allPredicates := true
+	for _, operand := range cond.GetOperands() {
+		allPredicates = allPredicates && isPredicate(operand)
+	}
+	if allPredicates {
+		predicates := make([]predicateFunc, len(cond.GetOperands()))
+		for i, operand := range cond.GetOperands() {
+			predicate, err := compilePredicate(operand, indexKey)
+			if err != nil {
+				return nil, err
+			}
+			predicates[i] = predicate
+		}
+		return func(env *compiledEnv) bool {
+			// Short circuit on the first operand deciding the result
+			for _, predicate := range predicates {
+				if predicate(env) != (optor == AndOperator) {
+					return optor != AndOperator
+				}
+			}
+			return optor == AndOperator
+		}, nil
+	}
+	values := make([]valueFunc, len(cond.GetOperands()))
+	for i, operand := range cond.GetOperands() {
+		value, err := compileValue(operand, indexKey)
+		if err != nil {
+			return nil, err
+		}
+		values[i] = value
+	}
+	return func(env *compiledEnv) bool {
+		lvalue := values[0](env)
+		for _, value := range values[1:] {
+			if result, ok := shortCircuit(lvalue, optor); ok {
+				return result
+			}
+			lvalue = EvaluateOperation(lvalue, value(env), optor)
+		}
+		result, ok := lvalue.(bool)
+		if !ok {
+			panic("Operands type not matching")
+		}
+		return result
+	}, nil
+}
+
+// fieldAndLiteral returns the field path and the literal of a comparison
+// like a.b == 10 (or 10 == a.b in which case swapped is true)
+func fieldAndLiteral(cond *ScalarCondition, indexKey string) (*compiledPath, interface{}, bool, bool) {
+	if optor := cond.GetOperator(); optor == AndOperator || optor == OrOperator {
+		return nil, nil, false, false
+	}
+	operand1, ok1 := cond.GetOperand1().(*ScalarCondition)
+	operand2, ok2 := cond.GetOperand2().(*ScalarCondition)
+	if !ok1 || !ok2 || isPredicate(operand1) || isPredicate(operand2) {
+		return nil, nil, false, false
+	}
+	isField := func(value interface{}) bool {
+		key, ok := value.(string)
+		return ok && !strings.HasPrefix(key, "\"")
+	}
+	switch {
+	case isField(operand1.GetValue()) && !isField(operand2.GetValue()):
+		return newCompiledPath(operand1.GetValue().(string), indexKey), operand2.GetValue(), false, true
+	case isField(operand2.GetValue()) && !isField(operand1.GetValue()):
+		return newCompiledPath(operand2.GetValue().(string), indexKey), operand1.GetValue(), true, true
+	}
+	return nil, nil, false, false
+}
+
+// compareTyped compares the raw JSON value with the literal. Returns false (ok)
+// if the types are not the same, to fall back on the generic comparison
+func compareTyped(result gjson.Result, constant interface{}, optor Operator, swapped bool) (bool, bool) {
+	switch literal := constant.(type) {
+	case int:
+		if result.Type != gjson.Number {
+			return false, false
+		}
+		value, err := strconv.Atoi(result.Raw)
+		if err != nil {
+			return false, false
+		}
+		if swapped {
+			return evaluateInt(literal, value, optor), true
+		}
+		return evaluateInt(value, literal, optor), true
+	case float64:
+		if result.Type != gjson.Number {
+			return false, false
+		}
+		if _, err := strconv.Atoi(result.Raw); err == nil {
+			return false, false
+		}
+		value, err := strconv.ParseFloat(result.Raw, 64)
+		if err != nil {
+			return false, false
+		}
+		if swapped {
+			return evaluateFloat64(literal, value, optor), true
+		}
+		return evaluateFloat64(value, literal, optor), true
+	case bool:
+		if result.Type != gjson.True && result.Type != gjson.False {
+			return false, false
+		}
+		if swapped {
+			return evaluateBool(literal, result.Bool(), optor), true
+		}
+		return evaluateBool(result.Bool(), literal, optor), true
+	case string:
+		if result.Type != gjson.String {
+			return false, false
+		}
+		if swapped {
+			return evaluateString(literal, result.Raw, optor), true
+		}
+		return evaluateString(result.Raw, literal, optor), true
+	}
+	return false, false
+}
@@ -0,0 +1,121 @@
+// File: compile_test.go
+// Tests for compiled rules
+package gorule
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+var compileTestRules = []string{
+	"IF: { amount >= 10000 && type == \"CREDIT_CARD\" }",
+	"IF: { 10000 <= amount || type == \"CREDIT_CARD\" }",
+	"IF: { threshold > 5.5 && enabled == true }",
+	"IF: { amount == limit }",
+	"IF: { missing == \"missing\" }",
+	"IF: { enabled && amount > 10 }",
+	"IF: { FOR: i=0:items.size() { items[i].qty > 0 && items[i].sku == \"A\" } }",
+	"FOR: i=0:items.size() IF: { items[i].qty >= 2 || items[i].sku == \"B\" }",
+}
+
+var compileTestData = [][]byte{
+	[]byte(`{ "amount": 10000, "limit": 10000, "type": "CREDIT_CARD", "threshold": 5.9, "enabled": true, "items": [ { "qty": 1, "sku": "A" }, { "qty": 2, "sku": "A" } ] }`),
+	[]byte(`{ "amount": 10, "limit": 20, "type": "CASH", "threshold": 1.5, "enabled": false, "items": [ { "qty": 0, "sku": "B" } ] }`),
+	[]byte(`{ "amount": 20000, "limit": 1, "type": "UPI", "threshold": 7.25, "enabled": true, "items": [] }`),
+}
+
+func TestCompiledRuleSameAsInterpreter(t *testing.T) {
+	re := NewRuleEngine()
+	for _, src := range compileTestRules {
+		rule, err := NewRuleParser(src).ParseRule()
+		assert.Nil(t, err)
+		compiledRule, err := CompileRule(rule)
+		assert.Nil(t, err)
+		assert.Equal(t, rule.GetType(), compiledRule.GetType())
+		for _, testdata := range compileTestData {
+			expected, err := re.Evaluate(rule, testdata)
+			assert.Nil(t, err)
+			actual, err := re.Evaluate(compiledRule, testdata)
+			assert.Nil(t, err)
+			assert.Equal(t, expected, actual, src)
+			actual, err = compiledRule.EvaluateDocument(ParseDocument(testdata))
+			assert.Nil(t, err)
+			assert.Equal(t, expected, actual, src)
+		}
+	}
+}
+
+func TestCompiledRuleAddName(t *testing.T) {
+	rule, err := NewRuleParser("IF: { amount > 10 }").ParseRule()
+	assert.Nil(t, err)
+	compiledRule, err := CompileRule(rule)
+	assert.Nil(t, err)
+	ruleSet := NewRuleSet()
+	assert.Nil(t, ruleSet.Add("amount", compiledRule))
+	added, _ := ruleSet.Get("amount")
+	assert.IsType(t, &CompiledRule{}, added)
+	assert.Equal(t, "amount", added.GetMetadata().GetName())
+	assert.Equal(t, "", compiledRule.GetMetadata().GetName())
+	result, err := NewRuleEngine().Evaluate(added, []byte(`{ "amount": 20 }`))
+	assert.Nil(t, err)
+	assert.Equal(t, []bool{true}, result)
+}
+
+func TestCompiledRuleWorkingMemory(t *testing.T) {
+	rule, err := NewRuleParser("RULE \"gold\": IF: { tier == \"GOLD\" && amount > 10 }").ParseRule()
+	assert.Nil(t, err)
+	compiledRule, err := CompileRule(rule)
+	assert.Nil(t, err)
+	assert.Equal(t, "gold", compiledRule.GetMetadata().GetName())
+	memory := NewWorkingMemory()
+	memory.Set("tier", "\"GOLD\"")
+	ctx := NewContext()
+	assert.Nil(t, compiledRule.BuildContext([]byte(`{ "amount": 20, "tier": "SILVER" }`), ctx))
+	ctx.SetValue(WorkingMemoryKey, memory)
+	result, err := compiledRule.Evaluate(ctx)
+	assert.Nil(t, err)
+	assert.Equal(t, []bool{true}, result)
+}
+
+func TestCompiledRuleTypeMismatch(t *testing.T) {
+	rule, err := NewRuleParser("IF: { amount >= 10 }").ParseRule()
+	assert.Nil(t, err)
+	compiledRule, err := CompileRule(rule)
+	assert.Nil(t, err)
+	assert.Panics(t, func() { _, _ = compiledRule.EvaluateDocument(ParseDocument([]byte(`{ "amount": 10.5 }`))) })
+}
+
+func benchmarkCompileRule(b *testing.B) Rule {
+	rule, err := NewRuleParser("IF: { amount >= 10000 && type == \"CREDIT_CARD\" && country == \"US\" }").ParseRule()
+	if err != nil {
+		b.Fatal(err)
+	}
+	return rule
+}
+
+var benchmarkCompileData = []byte(`{ "amount": 20000, "type": "CREDIT_CARD", "country": "US" }`)
+
+// BenchmarkInterpretedRule evaluates the parsed rule for a shared document
+func BenchmarkInterpretedRule(b *testing.B) {
+	rule := benchmarkCompileRule(b)
+	doc := ParseDocument(benchmarkCompileData)
+	re := NewRuleEngine()
+	b.ResetTimer()
+	for n := 0; n < b.N; n++ {
+		_, _ = re.EvaluateDocument(rule, doc)
+	}
+}
+
+// BenchmarkCompiledRule evaluates the compiled rule for a shared document
+func BenchmarkCompiledRule(b *testing.B) {
+	compiledRule, err := CompileRule(benchmarkCompileRule(b))
+	if err != nil {
+		b.Fatal(err)
+	}
+	doc := ParseDocument(benchmarkCompileData)
+	b.ResetTimer()
+	for n := 0; n < b.N; n++ {
+		_, _ = compiledRule.EvaluateDocument(doc)
+	}
+}
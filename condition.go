@@ -2,16 +2,13 @@
 // Represents one condition ex. a == b
 package gorule
 
-import (
-	"fmt"
-	"strings"
-)
-
 const (
 	// ScalarConditionType represents a binary condition which is one dimensional (ex. a == b && c == d)
 	ScalarConditionType ConditionType = 1
 	// VectorConditionType represents iterative condition (ex. for i=0:N (result = result && a[i]))
 	VectorConditionType ConditionType = 2
+	// LogicalConditionType represents operands joined by the same logical operator (ex. a && b && c)
+	LogicalConditionType ConditionType = 3
 )
 
 // Condition represents the interface for condition
@@ -19,7 +16,6 @@ type Condition interface {
 	GetOperator() Operator
 	Evaluate(ctx Context) (interface{}, error)
 	GetValue() interface{}
-	buildContext(ipData []byte, ctx Context)
 }
 
 // ScalarCondition Condition represents one evaluatable binary expression (ex: a == b)
@@ -35,53 +31,62 @@ type ScalarCondition struct {
 
 // Evaluate does the evaluation of the condition and returns the result
 func (_c *ScalarCondition) Evaluate(ctx Context) (interface{}, error) {
+	if tr, ok := getTracer(ctx); ok {
+		step := tr.enter(_c, ctx)
+		result, err := _c.evaluate(ctx)
+		tr.exit(step, result, ctx)
+		return result, err
+	}
+	return _c.evaluate(ctx)
+}
+
+func (_c *ScalarCondition) evaluate(ctx Context) (interface{}, error) {
 	if _c.GetOperator() == NilOperator {
 		if _, ok := _c.GetValue().(string); ok {
 			// If its is a string literal it can be a json field like "a.b"
 			// or it can be a pure string like "FOO". In both the cases we add them
 			// in the context
 			ctxKey := _c.getContextKey(ctx)
-			return ctx.GetValue(ctxKey), nil
+			return resolveContextValue(ctx, ctxKey), nil
 		}
 		return _c.GetValue(), nil
 	}
+	if isArithmetic(_c.GetOperator()) {
+		return _c.evaluateArithmetic(ctx)
+	}
 	lvalue, _ := _c.GetOperand1().Evaluate(ctx)
+	// Short circuit the logical operators so that the fields of the second
+	// operand are never resolved when the result is already known
+	if result, ok := shortCircuit(lvalue, _c.GetOperator()); ok {
+		return result, nil
+	}
 	rvalue, _ := _c.GetOperand2().Evaluate(ctx)
 	result := EvaluateOperation(lvalue, rvalue, _c.GetOperator())
 	return result, nil
 }
 
-func (_c *ScalarCondition) getArrayIndex(ctx Context) string {
-	key := fmt.Sprintf("[%s]", ctx.GetValue(IndexKey))
-	return key
-}
-
-func (_c *ScalarCondition) getParsableArrayIndex(ctx Context) string {
-	key := fmt.Sprintf(".%d", ctx.GetValue(IndexCurrentValue))
-	return key
+// evaluateArithmetic evaluates the operands of an arithmetic operation (ex. the
+// value of score = amount * 0.01) and returns the number
+func (_c *ScalarCondition) evaluateArithmetic(ctx Context) (interface{}, error) {
+	lvalue, err := _c.GetOperand1().Evaluate(ctx)
+	if err != nil {
+		return nil, err
+	}
+	rvalue, err := _c.GetOperand2().Evaluate(ctx)
+	if err != nil {
+		return nil, err
+	}
+	return EvaluateArithmetic(lvalue, rvalue, _c.GetOperator())
 }
 
 func (_c *ScalarCondition) getContextKey(ctx Context) string {
 	key := _c.GetValue().(string)
 	if _c.HasArrayIndex {
-		key = strings.Replace(key, _c.getArrayIndex(ctx), _c.getParsableArrayIndex(ctx), 1)
+		key = resolveContextKey(key, ctx)
 	}
 	return key
 }
 
-func (_c *ScalarCondition) buildContext(ipData []byte, ctx Context) {
-	if _c.GetOperator() == NilOperator {
-		if _, ok := _c.GetValue().(string); ok {
-			ctxKey := _c.getContextKey(ctx)
-			value := resolveValue(ctxKey, ipData)
-			ctx.SetValue(ctxKey, value)
-		}
-		return
-	}
-	_c.GetOperand1().buildContext(ipData, ctx)
-	_c.GetOperand2().buildContext(ipData, ctx)
-}
-
 // GetOperator returns the underlying operator in the condition
 func (_c *ScalarCondition) GetOperator() Operator {
 	return _c.Operator
@@ -121,15 +126,26 @@ func (_c *VectorCondition) GetOperator() Operator {
 
 // Evaluate does the evaluation of the condition and returns the result
 func (_c *VectorCondition) Evaluate(ctx Context) (interface{}, error) {
+	if tr, ok := getTracer(ctx); ok {
+		step := tr.enter(_c, ctx)
+		result, err := _c.evaluate(ctx)
+		tr.exit(step, result, ctx)
+		return result, err
+	}
+	return _c.evaluate(ctx)
+}
+
+func (_c *VectorCondition) evaluate(ctx Context) (interface{}, error) {
 	result := true
+	startIndex := _c.getInitialValue(ctx)
+	endIndex := _c.getFinalValue(ctx)
 	ctx.SetValue(IndexKey, _c.IndexKey)
-	for i := ctx.GetValue(StartIndexValue).(int); i < ctx.GetValue(EndIndexValue).(int); i++ {
+	for i := startIndex; i < endIndex && result; i++ {
 		var res interface{}
 		var err error
-		scalarCondition := _c.SCondition.(*ScalarCondition)
 		ctx.SetValue(IndexCurrentValue, i)
 		// TODO: The default operator is &&
-		if res, err = scalarCondition.Evaluate(ctx); err != nil {
+		if res, err = _c.SCondition.Evaluate(ctx); err != nil {
 			return false, err
 		}
 		// TODO: Move this to Result structure
@@ -145,30 +161,68 @@ func (_c *VectorCondition) GetValue() interface{} {
 }
 
 // Evaluate string like "0"
-func (_c *VectorCondition) getInitialValue(ipData []byte) int {
+func (_c *VectorCondition) getInitialValue(ctx Context) int {
 	value := StringToInterface(_c.StartIndex.(string)).(int)
 	return value
 }
 
 // Evaluate string like a.size() => len(a)
-func (_c *VectorCondition) getFinalValue(ipData []byte) int {
-	value := resolveLength(strings.Replace(_c.EndIndex.(string), ".size()", "", 1), ipData).(int)
-	return value
+func (_c *VectorCondition) getFinalValue(ctx Context) int {
+	return resolveContextLength(ctx, _c.EndIndex.(string))
 }
 
-func (_c *VectorCondition) buildContext(ipData []byte, ctx Context) {
-	startIndex := _c.getInitialValue(ipData)
-	ctx.SetValue(StartIndexValue, startIndex)
-	endIndex := _c.getFinalValue(ipData)
-	ctx.SetValue(EndIndexValue, endIndex)
-	// Set this IndexKey = "i"
-	ctx.SetValue(IndexKey, _c.IndexKey)
-	// For every value of "i", fetch the value
-	for i := startIndex; i < endIndex; i++ {
-		// Set this "[i] = 0"
-		ctx.SetValue(IndexCurrentValue, i)
-		scalarCondition := _c.SCondition.(*ScalarCondition)
-		scalarCondition.buildContext(ipData, ctx)
+// LogicalCondition represents n operands joined by the same logical operator.
+// The parser never builds it, the optimizer flattens a && ( b && c ) into it
+// Format: { a && b && c }
+type LogicalCondition struct {
+	Type     ConditionType `json:"type"`
+	Operator Operator      `json:"optor"`
+	Operands []Condition   `json:"operands"`
+}
+
+// NewLogicalCondition returns the operands joined by the logical operator
+func NewLogicalCondition(optor Operator, operands ...Condition) *LogicalCondition {
+	return &LogicalCondition{Type: LogicalConditionType, Operator: optor, Operands: operands}
+}
+
+// GetOperator returns the underlying operator in the condition
+func (_c *LogicalCondition) GetOperator() Operator {
+	return _c.Operator
+}
+
+// GetOperands returns the operands in the order they are evaluated
+func (_c *LogicalCondition) GetOperands() []Condition {
+	return _c.Operands
+}
+
+// GetValue returns nil, the condition holds no data
+func (_c *LogicalCondition) GetValue() interface{} {
+	return nil
+}
+
+// Evaluate does the evaluation of the condition and returns the result
+func (_c *LogicalCondition) Evaluate(ctx Context) (interface{}, error) {
+	if tr, ok := getTracer(ctx); ok {
+		step := tr.enter(_c, ctx)
+		result, err := _c.evaluate(ctx)
+		tr.exit(step, result, ctx)
+		return result, err
 	}
+	return _c.evaluate(ctx)
+}
 
+func (_c *LogicalCondition) evaluate(ctx Context) (interface{}, error) {
+	if len(_c.Operands) == 0 {
+		// Same as the identity of the operator (ex. true for &&)
+		return _c.GetOperator() == AndOperator, nil
+	}
+	lvalue, _ := _c.Operands[0].Evaluate(ctx)
+	for _, operand := range _c.Operands[1:] {
+		if result, ok := shortCircuit(lvalue, _c.GetOperator()); ok {
+			return result, nil
+		}
+		rvalue, _ := operand.Evaluate(ctx)
+		lvalue = EvaluateOperation(lvalue, rvalue, _c.GetOperator())
+	}
+	return lvalue, nil
 }
@@ -14,4 +14,8 @@ const (
 	StartIndexValue string = "_START_INDEX_VALUE"
 	// EndIndexValue ...
 	EndIndexValue string = "_END_INDEX_VALUE"
+	// InputDataKey holds the input document the fields are lazily resolved from
+	InputDataKey string = "_INPUT_DATA"
+	// WorkingMemoryKey holds the working memory of the facts asserted by actions
+	WorkingMemoryKey string = "_WORKING_MEMORY"
 )
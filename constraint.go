@@ -0,0 +1,345 @@
+// File: constraint.go
+// Implements the reasoning over the comparisons of a field with literals (intervals and equalities)
+package gorule
+
+import (
+	"fmt"
+	"math"
+	"strings"
+)
+
+// notEqualOperator is the negation of == used while reasoning over negated
+// conditions. It is not an operator of the rules
+const notEqualOperator Operator = "!="
+
+// negatedOperators maps the comparison operators to their negation
+var negatedOperators = map[Operator]Operator{
+	EqualOperator: notEqualOperator, notEqualOperator: EqualOperator,
+	GreaterOperator: LesserThanOrEqualOperator, LesserThanOrEqualOperator: GreaterOperator,
+	LesserOperator: GreaterThanOrEqualOperator, GreaterThanOrEqualOperator: LesserOperator,
+}
+
+// swappedOperators maps the comparison operators to the operator with the
+// operands swapped (ex. 5 < a is a > 5)
+var swappedOperators = map[Operator]Operator{
+	EqualOperator: EqualOperator, GreaterOperator: LesserOperator, LesserOperator: GreaterOperator,
+	GreaterThanOrEqualOperator: LesserThanOrEqualOperator, LesserThanOrEqualOperator: GreaterThanOrEqualOperator,
+}
+
+// leafPath returns the path of a field operand
+func leafPath(condition Condition) (string, bool) {
+	cond, ok := condition.(*ScalarCondition)
+	if !ok || cond.GetOperator() != NilOperator {
+		return "", false
+	}
+	path, ok := cond.GetValue().(string)
+	if !ok || strings.HasPrefix(path, "\"") {
+		return "", false
+	}
+	return path, true
+}
+
+// isLiteral checks if the condition is a literal operand
+func isLiteral(condition Condition) bool {
+	cond, ok := condition.(*ScalarCondition)
+	if !ok || cond.GetOperator() != NilOperator {
+		return false
+	}
+	_, isPath := leafPath(cond)
+	return !isPath
+}
+
+// isConstant checks if the condition holds only literals
+func isConstant(condition Condition) bool {
+	cond, ok := condition.(*ScalarCondition)
+	if !ok {
+		return false
+	}
+	if cond.GetOperator() == NilOperator {
+		return isLiteral(cond)
+	}
+	return isConstant(cond.GetOperand1()) && isConstant(cond.GetOperand2())
+}
+
+// evaluateConstant evaluates a condition made only of literals
+func evaluateConstant(condition Condition) interface{} {
+	return evaluateCondition(condition, ParseDocument([]byte("{}")))
+}
+
+// evaluateCondition evaluates the condition for the document. A panic of the
+// engine is returned as a failing result
+func evaluateCondition(condition Condition, doc *Document) (result interface{}) {
+	err := SafeEvaluate(func() error {
+		result, _ = condition.Evaluate(NewRuleEngine().buildContext(doc))
+		return nil
+	})
+	if err != nil {
+		return fmt.Sprintf("failing (%v)", err)
+	}
+	return result
+}
+
+// comparisonAtom returns a comparison of a field with a literal as path optor
+// value (ex. 5 < a is a > 5). gjson paths are not atoms, neither are fields
+// indexed by a FOR (ex. a[i]) unless indexed is set
+func comparisonAtom(condition Condition, indexed bool) (string, Operator, interface{}, bool) {
+	cond, ok := condition.(*ScalarCondition)
+	if !ok {
+		return "", NilOperator, nil, false
+	}
+	optor, ok := swappedOperators[cond.GetOperator()]
+	if !ok {
+		return "", NilOperator, nil, false
+	}
+	for _, operands := range [][2]Condition{{cond.GetOperand1(), cond.GetOperand2()}, {cond.GetOperand2(), cond.GetOperand1()}} {
+		path, ok := leafPath(operands[0])
+		if !ok || !isLiteral(operands[1]) || isGJSONPath(path) || (operands[0].(*ScalarCondition).HasArrayIndex && !indexed) {
+			continue
+		}
+		if operands[0] == cond.GetOperand2() {
+			return path, optor, operands[1].GetValue(), true
+		}
+		return path, cond.GetOperator(), operands[1].GetValue(), true
+	}
+	return "", NilOperator, nil, false
+}
+
+// bound represents a lower or upper bound of a numeric field
+type bound struct {
+	value     float64
+	inclusive bool
+}
+
+// allows checks if the value is on the allowed side of the bound (direction 1
+// for a lower bound, -1 for an upper bound). A missing bound allows any value
+func (_b *bound) allows(value float64, direction float64) bool {
+	if _b == nil {
+		return true
+	}
+	if value == _b.value {
+		return _b.inclusive
+	}
+	return (value-_b.value)*direction > 0
+}
+
+// fieldConstraint represents the values a field can take given the clauses
+// of a conjunction
+type fieldConstraint struct {
+	lower, upper *bound
+	// equal holds the value of the == clauses (nil if none)
+	equal    interface{}
+	excluded []interface{}
+	// conflicting is set when the same value is required to be two different
+	// values (ex. a == 1 && a == 2)
+	conflicting bool
+	// mixed is set when the field is compared with values of different types,
+	// which is a type error rather than a contradiction
+	mixed   bool
+	literal valueType
+	// float is set if the field is compared with a float literal. The engine
+	// fails comparing an int with a float, so the values of a field compared
+	// with integers are integers
+	float bool
+}
+
+// add adds the clause field optor value to the constraint
+func (_fc *fieldConstraint) add(optor Operator, value interface{}) {
+	types := literalType(value)
+	if types&numberType != 0 {
+		types = numberType
+	}
+	if _fc.literal != 0 && _fc.literal != types {
+		_fc.mixed = true
+	}
+	_fc.literal = types
+	if _, ok := value.(float64); ok {
+		_fc.float = true
+	}
+	number, isNumber := toFloat(value)
+	if isNumber {
+		value = number
+	}
+	switch optor {
+	case EqualOperator:
+		if _fc.equal != nil && _fc.equal != value {
+			_fc.conflicting = true
+		}
+		_fc.equal = value
+	case notEqualOperator:
+		_fc.excluded = append(_fc.excluded, value)
+	case GreaterOperator, GreaterThanOrEqualOperator:
+		if !isNumber {
+			// Ordering operators are always false on strings and bools, a type error
+			_fc.mixed = true
+			return
+		}
+		newBound := &bound{value: number, inclusive: optor == GreaterThanOrEqualOperator}
+		if _fc.lower == nil || number > _fc.lower.value || (number == _fc.lower.value && !newBound.inclusive) {
+			_fc.lower = newBound
+		}
+	case LesserOperator, LesserThanOrEqualOperator:
+		if !isNumber {
+			_fc.mixed = true
+			return
+		}
+		newBound := &bound{value: number, inclusive: optor == LesserThanOrEqualOperator}
+		if _fc.upper == nil || number < _fc.upper.value || (number == _fc.upper.value && !newBound.inclusive) {
+			_fc.upper = newBound
+		}
+	}
+}
+
+// satisfiable checks if a value of the field satisfies all the clauses
+func (_fc *fieldConstraint) satisfiable() bool {
+	_, ok := _fc.pick()
+	return ok
+}
+
+func (_fc *fieldConstraint) isExcluded(value interface{}) bool {
+	for _, excluded := range _fc.excluded {
+		if excluded == value {
+			return true
+		}
+	}
+	return false
+}
+
+// pick returns a value of the field satisfying all the clauses, as a JSON
+// value (ex. unquoted strings). Returns false if there is none. A nil value is
+// returned for fields with mixed types
+func (_fc *fieldConstraint) pick() (interface{}, bool) {
+	if _fc.mixed {
+		return nil, true
+	}
+	if _fc.conflicting || (_fc.equal != nil && _fc.isExcluded(_fc.equal)) {
+		return nil, false
+	}
+	if number, ok := _fc.equal.(float64); ok {
+		if !_fc.lower.allows(number, 1) || !_fc.upper.allows(number, -1) {
+			return nil, false
+		}
+		return _fc.numberValue(number), true
+	}
+	if _fc.equal != nil {
+		return literalValue(_fc.equal), true
+	}
+	switch _fc.literal {
+	case booleanType:
+		for _, value := range []bool{true, false} {
+			if !_fc.isExcluded(value) {
+				return value, true
+			}
+		}
+		return nil, false
+	case stringType:
+		for i := 0; ; i++ {
+			candidate := fmt.Sprintf("\"value%d\"", i)
+			if !_fc.isExcluded(candidate) {
+				return literalValue(candidate), true
+			}
+		}
+	case numberType:
+		if _fc.float {
+			return _fc.pickFloat()
+		}
+		return _fc.pickInteger()
+	}
+	return nil, true
+}
+
+// pickInteger returns an integer within the bounds which is not excluded
+func (_fc *fieldConstraint) pickInteger() (interface{}, bool) {
+	low, high := math.Inf(-1), math.Inf(1)
+	if _fc.lower != nil {
+		low = math.Ceil(_fc.lower.value)
+		if low == _fc.lower.value && !_fc.lower.inclusive {
+			low++
+		}
+	}
+	if _fc.upper != nil {
+		high = math.Floor(_fc.upper.value)
+		if high == _fc.upper.value && !_fc.upper.inclusive {
+			high--
+		}
+	}
+	// Walk away from the finite bound (or from 0), at most one step per excluded value
+	start, step := low, 1.0
+	switch {
+	case math.IsInf(low, -1) && math.IsInf(high, 1):
+		start = 0
+	case math.IsInf(low, -1):
+		start, step = high, -1
+	}
+	for i := 0; i <= len(_fc.excluded); i++ {
+		candidate := start + float64(i)*step
+		if candidate >= low && candidate <= high && !_fc.isExcluded(candidate) {
+			return int(candidate), true
+		}
+	}
+	return nil, false
+}
+
+// pickFloat returns a float within the bounds which is not excluded. Values
+// with a fraction are preferred as JSON integers are decoded as int
+func (_fc *fieldConstraint) pickFloat() (interface{}, bool) {
+	if _fc.lower != nil && _fc.upper != nil && _fc.lower.value >= _fc.upper.value {
+		value := _fc.lower.value
+		if value == _fc.upper.value && _fc.lower.inclusive && _fc.upper.inclusive && !_fc.isExcluded(value) {
+			return value, true
+		}
+		return nil, false
+	}
+	var candidates []float64
+	steps := len(_fc.excluded) + 4
+	for i := 1; i < steps; i++ {
+		switch {
+		case _fc.lower != nil && _fc.upper != nil:
+			candidates = append(candidates, _fc.lower.value+(_fc.upper.value-_fc.lower.value)*float64(i)/float64(steps))
+		case _fc.lower != nil:
+			candidates = append(candidates, _fc.lower.value+float64(i)/2)
+		case _fc.upper != nil:
+			candidates = append(candidates, _fc.upper.value-float64(i)/2)
+		default:
+			candidates = append(candidates, float64(i)/2)
+		}
+	}
+	var fallback interface{}
+	for _, candidate := range candidates {
+		if !_fc.lower.allows(candidate, 1) || !_fc.upper.allows(candidate, -1) || _fc.isExcluded(candidate) {
+			continue
+		}
+		if candidate != math.Trunc(candidate) {
+			return candidate, true
+		}
+		if fallback == nil {
+			fallback = candidate
+		}
+	}
+	return fallback, fallback != nil
+}
+
+// numberValue returns the number as int unless the field is compared with floats
+func (_fc *fieldConstraint) numberValue(number float64) interface{} {
+	if !_fc.float && number == math.Trunc(number) {
+		return int(number)
+	}
+	return number
+}
+
+// literalValue returns the JSON value of a literal (strings are unquoted)
+func literalValue(value interface{}) interface{} {
+	if text, ok := value.(string); ok {
+		return unquote(text)
+	}
+	return value
+}
+
+func toFloat(value interface{}) (float64, bool) {
+	switch number := value.(type) {
+	case int:
+		return float64(number), true
+	case float64:
+		return number, true
+	}
+	return 0, false
+}
@@ -2,6 +2,11 @@
 // Represents a Context which is used during rule evaluation
 package gorule
 
+import (
+	"fmt"
+	"strings"
+)
+
 // ContextValue represents the type of the value held inside context
 type ContextValue interface{}
 
@@ -15,6 +20,8 @@ type Context interface {
 // RuleContext represents the core context used during rule evaluation
 type RuleContext struct {
 	ctxMap map[string]ContextValue
+	// tracer records the evaluation steps while explaining the rule
+	tracer *tracer
 }
 
 // NewContext returns a fresh context
@@ -37,3 +44,52 @@ func (_ctx *RuleContext) KeyExists(key string) bool {
 	_, ok := _ctx.ctxMap[key]
 	return ok
 }
+
+// resolveContextValue returns the value of key from the context. Values not
+// yet present are resolved from the input document attached to the context and
+// memoized, so each field is looked up at most once per evaluation
+func resolveContextValue(ctx Context, key string) ContextValue {
+	if ctx.KeyExists(key) {
+		return ctx.GetValue(key)
+	}
+	if memory, ok := ctx.GetValue(WorkingMemoryKey).(*WorkingMemory); ok {
+		// Facts asserted by the actions take precedence over input data
+		if value, ok := memory.Get(key); ok {
+			ctx.SetValue(key, value)
+			return value
+		}
+	}
+	doc, ok := ctx.GetValue(InputDataKey).(*Document)
+	if !ok {
+		return nil
+	}
+	value := doc.resolveValue(key)
+	ctx.SetValue(key, value)
+	return value
+}
+
+// resolveContextLength resolves a size expression like a.size() from the
+// input document attached to the context and memoizes it
+func resolveContextLength(ctx Context, sizeKey string) int {
+	if ctx.KeyExists(sizeKey) {
+		return ctx.GetValue(sizeKey).(int)
+	}
+	doc, ok := ctx.GetValue(InputDataKey).(*Document)
+	if !ok {
+		panic("Expecting input document but not found")
+	}
+	value := doc.resolveLength(strings.Replace(sizeKey, ".size()", "", 1))
+	ctx.SetValue(sizeKey, value)
+	return value
+}
+
+// resolveContextKey replaces the array index of the key with the current value
+// of the index (ex. a[i].b => a.0.b)
+func resolveContextKey(key string, ctx Context) string {
+	if !hasArrayIndex(key) {
+		return key
+	}
+	arrayIndex := fmt.Sprintf("[%s]", ctx.GetValue(IndexKey))
+	parsableArrayIndex := fmt.Sprintf(".%d", ctx.GetValue(IndexCurrentValue))
+	return strings.Replace(key, arrayIndex, parsableArrayIndex, 1)
+}
@@ -0,0 +1,297 @@
+// File: coverage.go
+// Implements the coverage of the rule conditions over a suite of payloads
+package gorule
+
+import (
+	"fmt"
+	"io"
+	"sort"
+	"strings"
+	"text/tabwriter"
+)
+
+// CoverageStatus represents how well a condition is covered by the payloads
+type CoverageStatus string
+
+const (
+	// NotReachedStatus represents a condition never evaluated (ex. due to short circuit)
+	NotReachedStatus CoverageStatus = "not reached"
+	// TrueOnlyStatus represents a condition evaluated only to true
+	TrueOnlyStatus CoverageStatus = "true only"
+	// FalseOnlyStatus represents a condition evaluated only to false
+	FalseOnlyStatus CoverageStatus = "false only"
+	// CoveredStatus represents a condition evaluated to both true and false
+	CoveredStatus CoverageStatus = "covered"
+)
+
+// ConditionCoverage represents the coverage of one condition (a comparison, a
+// logical operator or a FOR) of a rule
+type ConditionCoverage struct {
+	Condition  Condition `json:"-"`
+	Expression string    `json:"expression"`
+	Operator   Operator  `json:"operator"`
+	// Depth is the depth of the condition in the condition tree of the rule
+	Depth  int            `json:"depth"`
+	True   int            `json:"true"`
+	False  int            `json:"false"`
+	Status CoverageStatus `json:"status"`
+}
+
+// Branches returns the number of outcomes (true/false) reached
+func (_cc *ConditionCoverage) Branches() int {
+	branches := 0
+	if _cc.True > 0 {
+		branches++
+	}
+	if _cc.False > 0 {
+		branches++
+	}
+	return branches
+}
+
+// status returns the coverage status of the condition
+func (_cc *ConditionCoverage) status() CoverageStatus {
+	switch {
+	case _cc.True > 0 && _cc.False > 0:
+		return CoveredStatus
+	case _cc.True > 0:
+		return TrueOnlyStatus
+	case _cc.False > 0:
+		return FalseOnlyStatus
+	}
+	return NotReachedStatus
+}
+
+// RuleCoverage represents the coverage of the conditions of a rule, in the
+// order of the condition tree (parent before the operands)
+type RuleCoverage struct {
+	Rule       string               `json:"rule"`
+	Matched    int                  `json:"matched"`
+	Conditions []*ConditionCoverage `json:"conditions"`
+	// header is the RULE header of the rule in the annotated listing
+	header string
+}
+
+// OperatorCoverage represents the coverage of the conditions using an operator
+// across all the rules
+type OperatorCoverage struct {
+	Operator   Operator `json:"operator"`
+	Conditions int      `json:"conditions"`
+	Reached    int      `json:"reached"`
+	Branches   int      `json:"branches"`
+	// TotalBranches is two (true/false) per condition
+	TotalBranches int `json:"total_branches"`
+}
+
+// CoverageReport represents the coverage of a rule set over the payloads
+type CoverageReport struct {
+	Payloads      int                 `json:"payloads"`
+	Conditions    int                 `json:"conditions"`
+	Reached       int                 `json:"reached"`
+	Branches      int                 `json:"branches"`
+	TotalBranches int                 `json:"total_branches"`
+	Rules         []*RuleCoverage     `json:"rules"`
+	Operators     []*OperatorCoverage `json:"operators"`
+}
+
+// Coverage collects the coverage of the rules of a rule set. Record every
+// payload of the suite, then build the report
+type Coverage struct {
+	ruleSet    *RuleSet
+	engine     *RuleEngine
+	payloads   int
+	rules      []*RuleCoverage
+	conditions map[Condition]*ConditionCoverage
+}
+
+// NewCoverage returns a coverage collector of the rules of the rule set
+func NewCoverage(ruleSet *RuleSet) *Coverage {
+	coverage := &Coverage{ruleSet: ruleSet, engine: NewRuleEngine(), conditions: make(map[Condition]*ConditionCoverage)}
+	for _, name := range ruleSet.names {
+		ruleCoverage := &RuleCoverage{Rule: name}
+		rule := ruleSet.rules[name]
+		if compiledRule, ok := rule.(*CompiledRule); ok {
+			rule = compiledRule.GetRule()
+		}
+		if condition := ruleCondition(rule); condition != nil {
+			coverage.addConditions(ruleCoverage, condition, 0)
+		}
+		coverage.rules = append(coverage.rules, ruleCoverage)
+	}
+	return coverage
+}
+
+// ruleCondition returns the IF condition of the rule
+func ruleCondition(rule Rule) Condition {
+	switch fgRule := rule.(type) {
+	case *ScalarRule:
+		return fgRule.If
+	case *VectorRule:
+		return ruleCondition(fgRule.SRule)
+	}
+	return nil
+}
+
+// addConditions adds the conditions of the tree. Fields and literals are not
+// conditions of their own
+func (_c *Coverage) addConditions(ruleCoverage *RuleCoverage, condition Condition, depth int) {
+	switch cond := condition.(type) {
+	case *VectorCondition:
+		_c.addCondition(ruleCoverage, cond, Operator(ForToken), depth)
+		_c.addConditions(ruleCoverage, cond.SCondition, depth+1)
+	case *ScalarCondition:
+		if cond.GetOperator() == NilOperator {
+			return
+		}
+		_c.addCondition(ruleCoverage, cond, cond.GetOperator(), depth)
+		_c.addConditions(ruleCoverage, cond.GetOperand1(), depth+1)
+		_c.addConditions(ruleCoverage, cond.GetOperand2(), depth+1)
+	case *LogicalCondition:
+		_c.addCondition(ruleCoverage, cond, cond.GetOperator(), depth)
+		for _, operand := range cond.GetOperands() {
+			_c.addConditions(ruleCoverage, operand, depth+1)
+		}
+	}
+}
+
+func (_c *Coverage) addCondition(ruleCoverage *RuleCoverage, condition Condition, optor Operator, depth int) {
+	conditionCoverage := &ConditionCoverage{Condition: condition, Expression: FormatCondition(condition), Operator: optor, Depth: depth}
+	ruleCoverage.Conditions = append(ruleCoverage.Conditions, conditionCoverage)
+	_c.conditions[condition] = conditionCoverage
+}
+
+// Record evaluates all the rules for the payload and records the outcome of
+// the evaluated conditions
+func (_c *Coverage) Record(jsonData []byte) error {
+	return _c.RecordDocument(ParseDocument(jsonData))
+}
+
+// RecordDocument records the coverage of an already parsed document
+func (_c *Coverage) RecordDocument(doc *Document) error {
+	_c.payloads++
+	for i, name := range _c.ruleSet.names {
+		explanation, err := _c.engine.ExplainDocument(_c.ruleSet.rules[name], doc)
+		if err != nil {
+			return &RuleEvaluationError{Name: name, Err: err}
+		}
+		if isMatch(explanation.Result) {
+			_c.rules[i].Matched++
+		}
+		for _, step := range explanation.Steps {
+			_c.recordStep(step)
+		}
+	}
+	return nil
+}
+
+func (_c *Coverage) recordStep(step *TraceStep) {
+	if conditionCoverage, ok := _c.conditions[step.Condition]; ok {
+		if result, ok := step.Result.(bool); ok && result {
+			conditionCoverage.True++
+		} else {
+			conditionCoverage.False++
+		}
+	}
+	for _, child := range step.Steps {
+		_c.recordStep(child)
+	}
+}
+
+// Report returns the coverage of the payloads recorded so far
+func (_c *Coverage) Report() *CoverageReport {
+	report := &CoverageReport{Payloads: _c.payloads, Rules: _c.rules}
+	operators := make(map[Operator]*OperatorCoverage)
+	for _, ruleCoverage := range _c.rules {
+		ruleCoverage.header = formatRuleHeader(_c.ruleSet.rules[ruleCoverage.Rule].GetMetadata())
+		for _, conditionCoverage := range ruleCoverage.Conditions {
+			conditionCoverage.Status = conditionCoverage.status()
+			operatorCoverage, ok := operators[conditionCoverage.Operator]
+			if !ok {
+				operatorCoverage = &OperatorCoverage{Operator: conditionCoverage.Operator}
+				operators[conditionCoverage.Operator] = operatorCoverage
+				report.Operators = append(report.Operators, operatorCoverage)
+			}
+			reached := 0
+			if conditionCoverage.Status != NotReachedStatus {
+				reached = 1
+			}
+			operatorCoverage.Conditions++
+			operatorCoverage.Reached += reached
+			operatorCoverage.Branches += conditionCoverage.Branches()
+			operatorCoverage.TotalBranches += 2
+			report.Conditions++
+			report.Reached += reached
+			report.Branches += conditionCoverage.Branches()
+			report.TotalBranches += 2
+		}
+	}
+	sort.Slice(report.Operators, func(i, j int) bool {
+		return report.Operators[i].Operator < report.Operators[j].Operator
+	})
+	return report
+}
+
+func percent(part int, total int) string {
+	if total == 0 {
+		return "-"
+	}
+	return fmt.Sprintf("%.1f%%", float64(part)*100/float64(total))
+}
+
+// WriteText writes the summary of the coverage per rule and per operator
+//
+//	payloads: 3  conditions: 5/6 reached  branches: 8/12 (66.7%)
+//
+//	rule           matched  reached  branches
+//	high_value_cc  1/3      3/3      5/6 (83.3%)
+func (_cr *CoverageReport) WriteText(w io.Writer) {
+	fmt.Fprintf(w, "payloads: %d  conditions: %d/%d reached  branches: %d/%d (%s)\n\n", _cr.Payloads,
+		_cr.Reached, _cr.Conditions, _cr.Branches, _cr.TotalBranches, percent(_cr.Branches, _cr.TotalBranches))
+	tw := tabwriter.NewWriter(w, 0, 0, 2, ' ', 0)
+	fmt.Fprintln(tw, "rule\tmatched\treached\tbranches")
+	for _, ruleCoverage := range _cr.Rules {
+		reached, branches := 0, 0
+		for _, conditionCoverage := range ruleCoverage.Conditions {
+			if conditionCoverage.Status != NotReachedStatus {
+				reached++
+			}
+			branches += conditionCoverage.Branches()
+		}
+		total := 2 * len(ruleCoverage.Conditions)
+		fmt.Fprintf(tw, "%s\t%d/%d\t%d/%d\t%d/%d (%s)\n", ruleCoverage.Rule, ruleCoverage.Matched, _cr.Payloads,
+			reached, len(ruleCoverage.Conditions), branches, total, percent(branches, total))
+	}
+	tw.Flush()
+	fmt.Fprintln(w)
+	tw = tabwriter.NewWriter(w, 0, 0, 2, ' ', 0)
+	fmt.Fprintln(tw, "operator\tconditions\treached\tbranches")
+	for _, operatorCoverage := range _cr.Operators {
+		fmt.Fprintf(tw, "%s\t%d\t%d/%d\t%d/%d (%s)\n", operatorCoverage.Operator, operatorCoverage.Conditions, operatorCoverage.Reached,
+			operatorCoverage.Conditions, operatorCoverage.Branches, operatorCoverage.TotalBranches,
+			percent(operatorCoverage.Branches, operatorCoverage.TotalBranches))
+	}
+	tw.Flush()
+}
+
+// WriteAnnotated writes the conditions of every rule, one per line indented by
+// the depth in the tree, annotated with the outcome counts and the status
+//
+//	RULE "high_value_cc": matched 1/3
+//	  T1 F2  covered      amount >= 10000 && type == "CREDIT_CARD"
+//	  T1 F2  covered        amount >= 10000
+//	  T1 F0  true only      type == "CREDIT_CARD"
+func (_cr *CoverageReport) WriteAnnotated(w io.Writer) {
+	for _, ruleCoverage := range _cr.Rules {
+		header := ruleCoverage.header
+		if header == "" {
+			header = fmt.Sprintf("%s \"%s\":", RuleToken, ruleCoverage.Rule)
+		}
+		fmt.Fprintf(w, "%s matched %d/%d\n", header, ruleCoverage.Matched, _cr.Payloads)
+		tw := tabwriter.NewWriter(w, 0, 0, 2, ' ', 0)
+		for _, conditionCoverage := range ruleCoverage.Conditions {
+			fmt.Fprintf(tw, "  T%d F%d\t%s\t%s%s\n", conditionCoverage.True, conditionCoverage.False, conditionCoverage.Status,
+				strings.Repeat("  ", conditionCoverage.Depth), conditionCoverage.Expression)
+		}
+		tw.Flush()
+	}
+}
@@ -0,0 +1,90 @@
+// File: coverage_test.go
+package gorule
+
+import (
+	"bytes"
+	"encoding/json"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+const coverageRules = `RULE "high_value" PRIORITY 3:
+	IF: { amount >= 10000 && ( type == "CC" || type == "DC" ) }
+RULE "items":
+	FOR: i=0:items.size() IF: { items[i].price > 10 }
+`
+
+func recordCoverage(t *testing.T, payloads ...string) *CoverageReport {
+	ruleSet, err := NewRuleParser(coverageRules).ParseRuleSet()
+	assert.Nil(t, err)
+	coverage := NewCoverage(ruleSet)
+	for _, payload := range payloads {
+		assert.Nil(t, coverage.Record([]byte(payload)))
+	}
+	return coverage.Report()
+}
+
+func TestCoverage(t *testing.T) {
+	report := recordCoverage(t,
+		`{ "amount": 20000, "type": "CC", "items": [ { "price": 30 } ] }`,
+		`{ "amount": 2, "type": "CC", "items": [ { "price": 30 }, { "price": 5 } ] }`)
+	assert.Equal(t, 2, report.Payloads)
+	assert.Equal(t, 6, report.Conditions)
+	assert.Equal(t, 5, report.Reached)
+	assert.Equal(t, 8, report.Branches)
+	assert.Equal(t, 12, report.TotalBranches)
+
+	highValue := report.Rules[0]
+	assert.Equal(t, 1, highValue.Matched)
+	var statuses []CoverageStatus
+	for _, conditionCoverage := range highValue.Conditions {
+		statuses = append(statuses, conditionCoverage.Status)
+	}
+	// type == "DC" is never reached due to the short circuit of ||
+	assert.Equal(t, []CoverageStatus{CoveredStatus, CoveredStatus, TrueOnlyStatus, TrueOnlyStatus, NotReachedStatus}, statuses)
+	assert.Equal(t, ConditionCoverage{Condition: highValue.Conditions[1].Condition, Expression: "amount >= 10000",
+		Operator: GreaterThanOrEqualOperator, Depth: 1, True: 1, False: 1, Status: CoveredStatus}, *highValue.Conditions[1])
+
+	// Every iteration of a vector rule is an evaluation of the condition
+	items := report.Rules[1]
+	assert.Equal(t, 2, items.Matched)
+	assert.Equal(t, 2, items.Conditions[0].True)
+	assert.Equal(t, 1, items.Conditions[0].False)
+
+	assert.Equal(t, &OperatorCoverage{Operator: EqualOperator, Conditions: 2, Reached: 1, Branches: 1, TotalBranches: 4}, report.Operators[1])
+	data, err := json.Marshal(report.Rules[0].Conditions[4])
+	assert.Nil(t, err)
+	assert.JSONEq(t, `{ "expression": "type == \"DC\"", "operator": "==", "depth": 2, "true": 0, "false": 0, "status": "not reached" }`, string(data))
+}
+
+func TestCoverageText(t *testing.T) {
+	report := recordCoverage(t, `{ "amount": 20000, "type": "DC", "items": [] }`)
+	var text bytes.Buffer
+	report.WriteText(&text)
+	assert.Equal(t, `payloads: 1  conditions: 5/6 reached  branches: 5/12 (41.7%)
+
+rule        matched  reached  branches
+high_value  1/1      5/5      5/10 (50.0%)
+items       0/1      0/1      0/2 (0.0%)
+
+operator  conditions  reached  branches
+&&        1           1/1      1/2 (50.0%)
+==        2           2/2      2/4 (50.0%)
+>         1           0/1      0/2 (0.0%)
+>=        1           1/1      1/2 (50.0%)
+||        1           1/1      1/2 (50.0%)
+`, text.String())
+
+	var annotated bytes.Buffer
+	report.WriteAnnotated(&annotated)
+	assert.Equal(t, `RULE "high_value" PRIORITY 3: matched 1/1
+  T1 F0  true only   amount >= 10000 && ( type == "CC" || type == "DC" )
+  T1 F0  true only     amount >= 10000
+  T1 F0  true only     type == "CC" || type == "DC"
+  T0 F1  false only      type == "CC"
+  T1 F0  true only       type == "DC"
+RULE "items": matched 0/1
+  T0 F0  not reached  items[i].price > 10
+`, annotated.String())
+}
@@ -0,0 +1,378 @@
+// File: decision.go
+// Implements the decision tables loaded from CSV and compiled into rules
+package gorule
+
+import (
+	"encoding/csv"
+	"errors"
+	"fmt"
+	"io"
+	"strings"
+)
+
+// HitPolicy represents how the matching rows of a decision table are picked
+type HitPolicy int
+
+const (
+	// FirstHitPolicy returns the first matching row in the order of the table
+	FirstHitPolicy HitPolicy = 1
+	// UniqueHitPolicy expects at most one matching row for any input
+	UniqueHitPolicy HitPolicy = 2
+	// CollectHitPolicy returns all the matching rows in the order of the table
+	CollectHitPolicy HitPolicy = 3
+)
+
+// hitPolicyNames maps the names of the hit policies used in the tools to the
+// hit policies
+var hitPolicyNames = map[string]HitPolicy{
+	"first":   FirstHitPolicy,
+	"unique":  UniqueHitPolicy,
+	"collect": CollectHitPolicy,
+}
+
+// decisionOperators are the comparisons a cell can start with (ex. >= 100)
+var decisionOperators = []Operator{
+	GreaterThanOrEqualOperator, LesserThanOrEqualOperator, EqualOperator, GreaterOperator, LesserOperator,
+}
+
+// decisionOutputPrefix marks the headers of the output columns (ex. THEN tier)
+const decisionOutputPrefix = "THEN "
+
+// UnsupportedHitPolicyError raised when the hit policy is unknown
+type UnsupportedHitPolicyError struct {
+	HitPolicy HitPolicy
+}
+
+func (_rt *UnsupportedHitPolicyError) Error() string {
+	return fmt.Sprintf("Unsupported hit policy : %d", _rt.HitPolicy)
+}
+
+// UnknownHitPolicyError raised when the name of the hit policy is unknown
+type UnknownHitPolicyError struct {
+	Name string
+}
+
+func (_rt *UnknownHitPolicyError) Error() string {
+	return fmt.Sprintf("Unknown hit policy : %s (expecting first, unique or collect)", _rt.Name)
+}
+
+// ParseHitPolicy returns the hit policy of the name: first, unique or collect
+func ParseHitPolicy(name string) (HitPolicy, error) {
+	hitPolicy, ok := hitPolicyNames[name]
+	if !ok {
+		return 0, &UnknownHitPolicyError{Name: name}
+	}
+	return hitPolicy, nil
+}
+
+// DecisionTableError raised when the CSV of a decision table is invalid
+type DecisionTableError struct {
+	// Line is the line of the CSV (1-based)
+	Line int
+	// Column is the header of the offending cell, if any
+	Column  string
+	Message string
+}
+
+func (_rt *DecisionTableError) Error() string {
+	if _rt.Column == "" {
+		return fmt.Sprintf("Decision table error at line %d : %s", _rt.Line, _rt.Message)
+	}
+	return fmt.Sprintf("Decision table error at line %d column %s : %s", _rt.Line, _rt.Column, _rt.Message)
+}
+
+// HitPolicyViolationError raised when more than one row of a unique decision
+// table matches
+type HitPolicyViolationError struct {
+	Table string
+	Rows  []int
+}
+
+func (_rt *HitPolicyViolationError) Error() string {
+	return fmt.Sprintf("Hit policy unique of %s violated : rows %v matched", _rt.Table, _rt.Rows)
+}
+
+// DecisionTable represents rows of conditions on input columns mapping to
+// output values. Every row is compiled into a rule named after the table and
+// the row (ex. discount_2) asserting the outputs in its actions
+type DecisionTable struct {
+	Name      string
+	HitPolicy HitPolicy
+	// Inputs holds the paths of the input columns
+	Inputs []string
+	// Outputs holds the paths of the output columns
+	Outputs []string
+	ruleSet *RuleSet
+	// rows maps the names of the rules to their row (1-based)
+	rows map[string]int
+}
+
+// DecisionResult represents the matching rows of a decision table picked by
+// its hit policy along with their outputs
+type DecisionResult struct {
+	// Rows holds the matching rows (1-based) in the order of the table
+	Rows []int `json:"rows"`
+	// Outputs holds the outputs of every matching row nested by the dots of
+	// their paths (see RuleOutput). Empty output cells are left out
+	Outputs []map[string]interface{} `json:"outputs"`
+}
+
+// LoadDecisionTable reads a decision table from CSV. The header holds the
+// paths of the columns, the outputs prefixed with THEN (ex. amount, country,
+// THEN discount). Every other line is a row whose input cells are:
+//
+//	>= 100       comparison with a literal or a field, joined by && (ex. > 1 && <= 5)
+//	"US","CA"    equal to one of the values
+//	100          equal to the value
+//	-            any value (same as an empty cell)
+//
+// Output cells hold a literal, a field or an arithmetic expression (ex. "GOLD",
+// 0.1 or amount * 0.01)
+func LoadDecisionTable(name string, r io.Reader, hitPolicy HitPolicy) (*DecisionTable, error) {
+	switch hitPolicy {
+	case FirstHitPolicy, UniqueHitPolicy, CollectHitPolicy:
+	default:
+		return nil, &UnsupportedHitPolicyError{HitPolicy: hitPolicy}
+	}
+	reader := csv.NewReader(r)
+	reader.TrimLeadingSpace = true
+	header, err := reader.Read()
+	if err == io.EOF {
+		return nil, &DecisionTableError{Line: 1, Message: "missing header"}
+	}
+	if err != nil {
+		return nil, decisionTableError(err)
+	}
+	table := &DecisionTable{Name: name, HitPolicy: hitPolicy, ruleSet: NewRuleSet(), rows: make(map[string]int)}
+	for _, cell := range header {
+		path, isOutput := strings.CutPrefix(strings.TrimSpace(cell), decisionOutputPrefix)
+		path = strings.TrimSpace(path)
+		if !isDecisionPath(path) {
+			return nil, &DecisionTableError{Line: 1, Column: cell, Message: "expecting the path of a field"}
+		}
+		if isOutput {
+			table.Outputs = append(table.Outputs, path)
+		} else if len(table.Outputs) > 0 {
+			return nil, &DecisionTableError{Line: 1, Column: cell, Message: "input column after the output columns"}
+		} else {
+			table.Inputs = append(table.Inputs, path)
+		}
+	}
+	for row := 1; ; row++ {
+		record, err := reader.Read()
+		if err == io.EOF {
+			break
+		}
+		if err != nil {
+			return nil, decisionTableError(err)
+		}
+		line, _ := reader.FieldPos(0)
+		rule, err := table.rowRule(record, line)
+		if err != nil {
+			return nil, err
+		}
+		ruleName := fmt.Sprintf("%s_%d", name, row)
+		_ = table.ruleSet.Add(ruleName, rule)
+		table.rows[ruleName] = row
+	}
+	return table, nil
+}
+
+// decisionTableError returns the error of the CSV reader with its line
+func decisionTableError(err error) error {
+	var parseErr *csv.ParseError
+	if errors.As(err, &parseErr) {
+		return &DecisionTableError{Line: parseErr.Line, Message: parseErr.Err.Error()}
+	}
+	return err
+}
+
+// rowRule returns the rule of a row: the && of the conditions of its input
+// cells and an action per output cell
+func (_dt *DecisionTable) rowRule(record []string, line int) (Rule, error) {
+	var conditions, actions []string
+	for i, path := range _dt.Inputs {
+		cell := strings.TrimSpace(record[i])
+		if cell == "" || cell == "-" {
+			continue
+		}
+		condition, err := cellCondition(path, cell)
+		if err != nil {
+			return nil, &DecisionTableError{Line: line, Column: path, Message: err.Error()}
+		}
+		conditions = append(conditions, condition)
+	}
+	for i, path := range _dt.Outputs {
+		cell := strings.TrimSpace(record[len(_dt.Inputs)+i])
+		if cell == "" {
+			continue
+		}
+		if strings.ContainsAny(cell, ";{}=") {
+			return nil, &DecisionTableError{Line: line, Column: decisionOutputPrefix + path, Message: fmt.Sprintf("expecting a value, found %s", cell)}
+		}
+		actions = append(actions, fmt.Sprintf("%s = %s", path, cell))
+	}
+	src := "IF: { true }"
+	if len(conditions) > 0 {
+		src = fmt.Sprintf("IF: { %s }", strings.Join(conditions, " && "))
+	}
+	if len(actions) > 0 {
+		src += fmt.Sprintf(" THEN: { %s }", strings.Join(actions, " ; "))
+	}
+	parser := NewRuleParser(src)
+	rule, err := parser.ParseRule()
+	if err == nil {
+		err = parser.ValidateEOF()
+	}
+	if err != nil {
+		return nil, &DecisionTableError{Line: line, Message: err.Error()}
+	}
+	return rule, nil
+}
+
+// cellCondition returns the source of the condition of an input cell on the
+// field at path, grouped if it is a list of values
+func cellCondition(path string, cell string) (string, error) {
+	if !hasDecisionOperator(cell) {
+		values := splitCell(cell)
+		comparisons := make([]string, len(values))
+		for i, value := range values {
+			if !isDecisionValue(value) {
+				return "", fmt.Errorf("expecting a literal or a field, found %s", value)
+			}
+			comparisons[i] = fmt.Sprintf("%s == %s", path, value)
+		}
+		if len(comparisons) == 1 {
+			return comparisons[0], nil
+		}
+		return fmt.Sprintf("( %s )", strings.Join(comparisons, " || ")), nil
+	}
+	var comparisons []string
+	for _, part := range strings.Split(cell, "&&") {
+		part = strings.TrimSpace(part)
+		optor, ok := cellOperator(part)
+		if !ok {
+			return "", fmt.Errorf("expecting a comparison, found %s", part)
+		}
+		value := strings.TrimSpace(strings.TrimPrefix(part, string(optor)))
+		if !isDecisionValue(value) {
+			return "", fmt.Errorf("expecting a literal or a field, found %s", value)
+		}
+		comparisons = append(comparisons, fmt.Sprintf("%s %s %s", path, optor, value))
+	}
+	return strings.Join(comparisons, " && "), nil
+}
+
+// cellOperator returns the comparison the part of a cell starts with
+func cellOperator(part string) (Operator, bool) {
+	for _, optor := range decisionOperators {
+		if strings.HasPrefix(part, string(optor)) {
+			return optor, true
+		}
+	}
+	return "", false
+}
+
+func hasDecisionOperator(cell string) bool {
+	_, ok := cellOperator(cell)
+	return ok || strings.Contains(cell, "&&")
+}
+
+// splitCell splits a list of values on the commas outside of the strings
+func splitCell(cell string) []string {
+	var values []string
+	inString := false
+	start := 0
+	for i, ch := range cell {
+		switch {
+		case ch == '"':
+			inString = !inString
+		case ch == ',' && !inString:
+			values = append(values, strings.TrimSpace(cell[start:i]))
+			start = i + 1
+		}
+	}
+	return append(values, strings.TrimSpace(cell[start:]))
+}
+
+// isDecisionValue checks if the value is a single token of the rule syntax: a
+// string, a number, a bool or a field
+func isDecisionValue(value string) bool {
+	if strings.HasPrefix(value, "\"") {
+		return len(value) > 1 && strings.Count(value, "\"") == 2 && strings.HasSuffix(value, "\"")
+	}
+	return value != "" && !strings.ContainsAny(value, " \t\r\n\"(){};,=<>!&|")
+}
+
+// isDecisionPath checks if the value is the path of a field
+func isDecisionPath(value string) bool {
+	_, isPath := StringToInterface(value).(string)
+	return isPath && isDecisionValue(value) && !strings.HasPrefix(value, "\"")
+}
+
+// RuleSet returns the rules of the rows in the order of the table. The rules
+// evaluate with the conflict strategy of the hit policy, first (FirstMatchStrategy)
+// or all (AllMatchesStrategy)
+func (_dt *DecisionTable) RuleSet() *RuleSet {
+	return _dt.ruleSet
+}
+
+// Strategy returns the conflict strategy evaluating the rules of the table
+func (_dt *DecisionTable) Strategy() ConflictStrategy {
+	if _dt.HitPolicy == FirstHitPolicy {
+		return FirstMatchStrategy
+	}
+	return AllMatchesStrategy
+}
+
+// Overlaps returns the pairs of rows which may match the same input (see
+// AnalyzeRuleSetOverlap), ex. to check a unique table before deploying it
+func (_dt *DecisionTable) Overlaps() []*RuleRelation {
+	return AnalyzeRuleSetOverlap(_dt.ruleSet)
+}
+
+// Row returns the row (1-based) of the rule of the table
+func (_dt *DecisionTable) Row(ruleName string) (int, bool) {
+	row, ok := _dt.rows[ruleName]
+	return row, ok
+}
+
+// EvaluateDecisionTable evaluates the rows of the table for the given jsonData
+// and returns the rows picked by the hit policy along with their outputs
+// args:
+//
+//	table: The decision table to evaluate
+//	jsonData: The data to be used during evaluation
+//
+// Return
+//
+//	DecisionResult: Matching rows and their outputs
+//	error: Any error during evaluation. HitPolicyViolationError if more than
+//	one row of a unique table matches
+func (_re *RuleEngine) EvaluateDecisionTable(table *DecisionTable, jsonData []byte) (*DecisionResult, error) {
+	return _re.EvaluateDecisionTableDocument(table, ParseDocument(jsonData))
+}
+
+// EvaluateDecisionTableDocument evaluates the rows of the table for an already
+// parsed document
+func (_re *RuleEngine) EvaluateDecisionTableDocument(table *DecisionTable, doc *Document) (*DecisionResult, error) {
+	ruleSetResult, err := _re.EvaluateRuleSetDocumentWithStrategy(table.ruleSet, doc, table.Strategy())
+	if err != nil {
+		return nil, err
+	}
+	result := &DecisionResult{Rows: []int{}, Outputs: []map[string]interface{}{}}
+	for _, name := range ruleSetResult.Winners {
+		result.Rows = append(result.Rows, table.rows[name])
+	}
+	if table.HitPolicy == UniqueHitPolicy && len(result.Rows) > 1 {
+		return nil, &HitPolicyViolationError{Table: table.Name, Rows: result.Rows}
+	}
+	for _, name := range ruleSetResult.Winners {
+		outputs := make(map[string]interface{})
+		if output, ok := ruleSetResult.Outputs[name]; ok {
+			outputs = output.Outputs
+		}
+		result.Outputs = append(result.Outputs, outputs)
+	}
+	return result, nil
+}
@@ -0,0 +1,104 @@
+// File: decision_test.go
+// Tests for the decision tables
+package gorule
+
+import (
+	"strings"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+const discountTable = `amount,customer.country,customer.tier,THEN discount,THEN label
+>= 10000,"""US"",""CA""",-,0.15,"""large"""
+>= 1000 && < 10000,-,"""GOLD""",0.1,
+>= 1000 && < 10000,-,-,0.05,"""medium"""
+-,"""IN""",,0.02,label
+< 0,-,-,amount * -0.5,
+`
+
+func TestLoadDecisionTable(t *testing.T) {
+	table, err := LoadDecisionTable("discount", strings.NewReader(discountTable), FirstHitPolicy)
+	assert.Nil(t, err)
+	assert.Equal(t, []string{"amount", "customer.country", "customer.tier"}, table.Inputs)
+	assert.Equal(t, []string{"discount", "label"}, table.Outputs)
+	assert.Equal(t, `RULE "discount_1":
+	IF: { amount >= 10000 && ( customer.country == "US" || customer.country == "CA" ) } THEN: { discount = 0.15; label = "large" }
+RULE "discount_2":
+	IF: { amount >= 1000 && amount < 10000 && customer.tier == "GOLD" } THEN: { discount = 0.1 }
+RULE "discount_3":
+	IF: { amount >= 1000 && amount < 10000 } THEN: { discount = 0.05; label = "medium" }
+RULE "discount_4":
+	IF: { customer.country == "IN" } THEN: { discount = 0.02; label = label }
+RULE "discount_5":
+	IF: { amount < 0 } THEN: { discount = amount * -0.5 }
+`, FormatRuleSet(table.RuleSet()))
+	row, ok := table.Row("discount_3")
+	assert.True(t, ok)
+	assert.Equal(t, 3, row)
+}
+
+func TestEvaluateDecisionTable(t *testing.T) {
+	tests := []struct {
+		hitPolicy HitPolicy
+		payload   string
+		rows      []int
+		outputs   []map[string]interface{}
+	}{
+		{FirstHitPolicy, `{"amount": 20000, "customer": {"country": "CA"}}`, []int{1}, []map[string]interface{}{{"discount": 0.15, "label": "large"}}},
+		{FirstHitPolicy, `{"amount": 2000, "customer": {"country": "US", "tier": "GOLD"}}`, []int{2}, []map[string]interface{}{{"discount": 0.1}}},
+		{FirstHitPolicy, `{"amount": 20000, "customer": {"country": "FR"}}`, []int{}, []map[string]interface{}{}},
+		{CollectHitPolicy, `{"amount": 2000, "customer": {"country": "IN", "tier": "GOLD"}, "label": "vip"}`, []int{2, 3, 4},
+			[]map[string]interface{}{{"discount": 0.1}, {"discount": 0.05, "label": "medium"}, {"discount": 0.02, "label": "vip"}}},
+		{FirstHitPolicy, `{"amount": -10, "customer": {}}`, []int{5}, []map[string]interface{}{{"discount": 5.0}}},
+		{UniqueHitPolicy, `{"amount": 500, "customer": {"country": "IN"}, "label": "small"}`, []int{4}, []map[string]interface{}{{"discount": 0.02, "label": "small"}}},
+	}
+	engine := NewRuleEngine()
+	for _, test := range tests {
+		table, err := LoadDecisionTable("discount", strings.NewReader(discountTable), test.hitPolicy)
+		assert.Nil(t, err)
+		result, err := engine.EvaluateDecisionTable(table, []byte(test.payload))
+		assert.Nil(t, err, test.payload)
+		assert.Equal(t, test.rows, result.Rows, test.payload)
+		assert.Equal(t, test.outputs, result.Outputs, test.payload)
+	}
+
+	table, err := LoadDecisionTable("discount", strings.NewReader(discountTable), UniqueHitPolicy)
+	assert.Nil(t, err)
+	_, err = engine.EvaluateDecisionTable(table, []byte(`{"amount": 2000, "customer": {"tier": "GOLD"}}`))
+	assert.Equal(t, &HitPolicyViolationError{Table: "discount", Rows: []int{2, 3}}, err)
+	overlaps := table.Overlaps()
+	assert.NotEmpty(t, overlaps)
+	assert.Equal(t, "discount_2", overlaps[0].Rule1)
+	assert.Equal(t, "discount_3", overlaps[0].Rule2)
+}
+
+func TestLoadDecisionTableErrors(t *testing.T) {
+	tests := []struct {
+		csv string
+		err string
+	}{
+		{``, "Decision table error at line 1 : missing header"},
+		{"amount,10\n", "Decision table error at line 1 column 10 : expecting the path of a field"},
+		{"THEN discount,amount\n", "Decision table error at line 1 column amount : input column after the output columns"},
+		{"amount,THEN discount\n> 1,0.1\n=> 2,0.2\n", "Decision table error at line 3 column amount : expecting a literal or a field, found => 2"},
+		{"amount,THEN discount\n> 1 && 5,0.1\n", "Decision table error at line 2 column amount : expecting a comparison, found 5"},
+		{"amount,THEN discount\n> 1,0.1\n> 1 || x,0.2\n", "Decision table error at line 3 column amount : expecting a literal or a field, found 1 || x"},
+		{"country,THEN discount\n\"\"\"US\"\", 1 2\",0.1\n", "Decision table error at line 2 column country : expecting a literal or a field, found 1 2"},
+		{"amount,THEN discount\n> 1,0.1 = 1\n", "Decision table error at line 2 column THEN discount : expecting a value, found 0.1 = 1"},
+		{"amount,THEN discount\n> 1,0.1 +\n", "Decision table error at line 2 : Syntax error : Expected value found }"},
+		{"amount,THEN discount\n> 1\n", "Decision table error at line 2 : wrong number of fields"},
+	}
+	for _, test := range tests {
+		_, err := LoadDecisionTable("discount", strings.NewReader(test.csv), FirstHitPolicy)
+		assert.EqualError(t, err, test.err, test.csv)
+	}
+
+	_, err := LoadDecisionTable("discount", strings.NewReader(discountTable), HitPolicy(9))
+	assert.IsType(t, &UnsupportedHitPolicyError{}, err)
+	_, err = ParseHitPolicy("any")
+	assert.EqualError(t, err, "Unknown hit policy : any (expecting first, unique or collect)")
+	hitPolicy, err := ParseHitPolicy("collect")
+	assert.Nil(t, err)
+	assert.Equal(t, CollectHitPolicy, hitPolicy)
+}
@@ -0,0 +1,100 @@
+// File: document.go
+// Represents a JSON input parsed once and shared across rule evaluations
+package gorule
+
+import (
+	"strconv"
+	"strings"
+	"sync"
+
+	"github.com/tidwall/gjson"
+)
+
+// Document represents the input JSON of an evaluation. Every object/array of the
+// document is scanned at most once (on first access) and the children are kept,
+// so a document can be shared across all the rules evaluated for the same input
+// instead of rescanning the raw bytes for every field
+type Document struct {
+	root *documentNode
+	mu   sync.Mutex
+}
+
+type documentNode struct {
+	value    gjson.Result
+	children map[string]*documentNode
+}
+
+// ParseDocument returns a document for the given JSON data
+func ParseDocument(ipData []byte) *Document {
+	return &Document{root: &documentNode{value: gjson.ParseBytes(ipData)}}
+}
+
+// Get returns the value at the given path (ex. a.b.0.c)
+func (_d *Document) Get(key string) gjson.Result {
+	if isGJSONPath(key) {
+		// Path uses gjson syntax which is not a plain walk of the tree
+		return _d.root.value.Get(key)
+	}
+	return _d.getPath(strings.Split(key, "."), -1, "")
+}
+
+// getPath returns the value at the path already split into its parts. The part
+// at indexPart (if any) is replaced by index
+func (_d *Document) getPath(parts []string, indexPart int, index string) gjson.Result {
+	_d.mu.Lock()
+	defer _d.mu.Unlock()
+	node := _d.root
+	for i, part := range parts {
+		if i == indexPart {
+			part = index
+		}
+		if node = node.child(part); node == nil {
+			return gjson.Result{}
+		}
+	}
+	return node.value
+}
+
+func (_n *documentNode) child(key string) *documentNode {
+	if _n.children == nil {
+		_n.expand()
+	}
+	return _n.children[key]
+}
+
+// expand scans the object/array once and indexes all its children
+func (_n *documentNode) expand() {
+	_n.children = make(map[string]*documentNode)
+	if !(_n.value.IsObject() || _n.value.IsArray()) {
+		return
+	}
+	index := 0
+	isArray := _n.value.IsArray()
+	_n.value.ForEach(func(key, value gjson.Result) bool {
+		childKey := key.String()
+		if isArray {
+			childKey = strconv.Itoa(index)
+			index++
+		}
+		if _, ok := _n.children[childKey]; !ok {
+			// Keep the first occurrence of duplicate keys (same as gjson)
+			_n.children[childKey] = &documentNode{value: value}
+		}
+		return true
+	})
+}
+
+// resolveValue takes a key and then gets the coresponding value from document
+func (_d *Document) resolveValue(key string) interface{} {
+	return resultToInterface(key, _d.Get(key))
+}
+
+// resolveLength takes a key of an array and then gets the length
+func (_d *Document) resolveLength(key string) int {
+	return resultLength(_d.Get(key))
+}
+
+// isGJSONPath checks if the key uses gjson path syntax (ex. a.#, a.*)
+func isGJSONPath(key string) bool {
+	return strings.ContainsAny(key, "*?#|@\\!=<>%")
+}
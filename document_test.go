@@ -0,0 +1,142 @@
+// File: document_test.go
+// Tests for document
+package gorule
+
+import (
+	"fmt"
+	"strings"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestDocumentGet(t *testing.T) {
+	doc := ParseDocument([]byte(`
+	{
+		"domino": {
+			"variantId" : 3,
+			"type":"FOO",
+			"moves": [
+				{ "type":100 },
+				{ "type":1 },
+			]
+		}
+	}
+	`))
+	assert.Equal(t, int64(3), doc.Get("domino.variantId").Int())
+	assert.Equal(t, "FOO", doc.Get("domino.type").String())
+	assert.Equal(t, int64(1), doc.Get("domino.moves.1.type").Int())
+	assert.False(t, doc.Get("domino.moves.2.type").Exists())
+	assert.False(t, doc.Get("domino.missing").Exists())
+	assert.False(t, doc.Get("domino.type.missing").Exists())
+	// gjson path syntax falls back to gjson
+	assert.Equal(t, int64(2), doc.Get("domino.moves.#").Int())
+}
+
+func TestDocumentResolve(t *testing.T) {
+	doc := ParseDocument([]byte(`{ "a": { "b": 10, "c": "FOO", "d": [1, 2, 3] } }`))
+	assert.Equal(t, 10, doc.resolveValue("a.b"))
+	assert.Equal(t, "\"FOO\"", doc.resolveValue("a.c"))
+	assert.Equal(t, "a.x", doc.resolveValue("a.x"))
+	assert.Equal(t, 3, doc.resolveLength("a.d"))
+}
+
+func TestEngineEvaluateSharedDocument(t *testing.T) {
+	rule1, err := NewRuleParser("IF: { amount >= 10000 }").ParseRule()
+	assert.Nil(t, err)
+	rule2, err := NewRuleParser("IF: { FOR: i=0:items.size() { items[i].qty > 0 } } THEN: { }").ParseRule()
+	assert.Nil(t, err)
+	doc := ParseDocument([]byte(`{ "amount": 10000, "items": [ { "qty": 1 }, { "qty": 0 } ] }`))
+	re := NewRuleEngine()
+	result, err := re.EvaluateDocument(rule1, doc)
+	assert.Nil(t, err)
+	assert.Equal(t, true, result.([]bool)[0])
+	result, err = re.EvaluateDocument(rule2, doc)
+	assert.Nil(t, err)
+	assert.Equal(t, false, result.([]bool)[0])
+}
+
+// benchmarkInput builds an event with numFields fields and an array of items
+func benchmarkInput(numFields int, numItems int) []byte {
+	var sb strings.Builder
+	sb.WriteString(`{ "type": "CREDIT_CARD", "items": [`)
+	for i := 0; i < numItems; i++ {
+		if i > 0 {
+			sb.WriteString(",")
+		}
+		sb.WriteString(fmt.Sprintf(`{ "id": %d, "qty": %d, "meta": { "sku": "SKU-%d" } }`, i, i+1, i))
+	}
+	sb.WriteString("]")
+	for i := 0; i < numFields; i++ {
+		sb.WriteString(fmt.Sprintf(`, "field_%d": %d`, i, i))
+	}
+	sb.WriteString("}")
+	return []byte(sb.String())
+}
+
+// benchmarkRules builds numRules rules, each reading a different field
+func benchmarkRules(b *testing.B, numRules int, numFields int) []Rule {
+	rules := make([]Rule, 0, numRules)
+	for i := 0; i < numRules; i++ {
+		src := fmt.Sprintf("IF: { field_%d >= 10 && type == \"CREDIT_CARD\" }", i%numFields)
+		if i%10 == 0 {
+			src = "IF: { FOR: i=0:items.size() { items[i].qty > 0 } } THEN: { }"
+		}
+		rule, err := NewRuleParser(src).ParseRule()
+		if err != nil {
+			b.Fatal(err)
+		}
+		rules = append(rules, rule)
+	}
+	return rules
+}
+
+// BenchmarkResolveRawBytes resolves every field by rescanning the raw input
+func BenchmarkResolveRawBytes(b *testing.B) {
+	ipData := benchmarkInput(300, 50)
+	for n := 0; n < b.N; n++ {
+		for i := 0; i < 300; i++ {
+			_ = resolveValue(fmt.Sprintf("field_%d", i), ipData)
+		}
+	}
+}
+
+// BenchmarkResolveDocument resolves every field from a document parsed once
+func BenchmarkResolveDocument(b *testing.B) {
+	ipData := benchmarkInput(300, 50)
+	for n := 0; n < b.N; n++ {
+		doc := ParseDocument(ipData)
+		for i := 0; i < 300; i++ {
+			_ = doc.resolveValue(fmt.Sprintf("field_%d", i))
+		}
+	}
+}
+
+// BenchmarkEvaluateRulesRawInput evaluates 300 rules handing the raw input to
+// every evaluation
+func BenchmarkEvaluateRulesRawInput(b *testing.B) {
+	ipData := benchmarkInput(300, 50)
+	rules := benchmarkRules(b, 300, 300)
+	re := NewRuleEngine()
+	b.ResetTimer()
+	for n := 0; n < b.N; n++ {
+		for _, rule := range rules {
+			_, _ = re.Evaluate(rule, ipData)
+		}
+	}
+}
+
+// BenchmarkEvaluateRulesSharedDocument evaluates 300 rules sharing one
+// document per event
+func BenchmarkEvaluateRulesSharedDocument(b *testing.B) {
+	ipData := benchmarkInput(300, 50)
+	rules := benchmarkRules(b, 300, 300)
+	re := NewRuleEngine()
+	b.ResetTimer()
+	for n := 0; n < b.N; n++ {
+		doc := ParseDocument(ipData)
+		for _, rule := range rules {
+			_, _ = re.EvaluateDocument(rule, doc)
+		}
+	}
+}
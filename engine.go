@@ -2,6 +2,18 @@
 // Rule engine to evaluate the rule for the given data
 package gorule
 
+import "fmt"
+
+// EvaluationPanicError raised when the evaluation panics (ex. operands of
+// different types)
+type EvaluationPanicError struct {
+	Value interface{}
+}
+
+func (_rt *EvaluationPanicError) Error() string {
+	return fmt.Sprintf("%v", _rt.Value)
+}
+
 // RuleEngine represents a service to evaluate the rule
 type RuleEngine struct {
 }
@@ -11,9 +23,21 @@ func NewRuleEngine() *RuleEngine {
 	return &RuleEngine{}
 }
 
-func (_re *RuleEngine) buildContext(fgRule Rule, ipData []byte) Context {
+// SafeEvaluate calls evaluate and returns a panic of the engine as an
+// EvaluationPanicError instead of crashing the caller. The engine panics on
+// operands of different types (ex. amount > 10 with "amount": "x")
+func SafeEvaluate(evaluate func() error) (err error) {
+	defer func() {
+		if recovered := recover(); recovered != nil {
+			err = &EvaluationPanicError{Value: recovered}
+		}
+	}()
+	return evaluate()
+}
+
+func (_re *RuleEngine) buildContext(doc *Document) Context {
 	ctx := NewContext()
-	fgRule.BuildContext(ipData, ctx)
+	ctx.SetValue(InputDataKey, doc)
 	return ctx
 }
 
@@ -28,6 +52,52 @@ func (_re *RuleEngine) buildContext(fgRule Rule, ipData []byte) Context {
 //	bool: Evaluation result (i.e true/false)
 //	error: Any error during evaluation
 func (_re *RuleEngine) Evaluate(fgRule Rule, jsonData []byte) (interface{}, error) {
-	ctx := _re.buildContext(fgRule, jsonData)
+	return _re.EvaluateDocument(fgRule, ParseDocument(jsonData))
+}
+
+// EvaluateDocument evaluates a rule for an already parsed document. Parse the
+// input once with ParseDocument and share it across all the rules evaluated
+// for the same input
+// args:
+//
+//	fgRule: The rule to evaluate
+//	doc: The parsed data to be used during evaluation
+//
+// Return
+//
+//	bool: Evaluation result (i.e true/false)
+//	error: Any error during evaluation
+func (_re *RuleEngine) EvaluateDocument(fgRule Rule, doc *Document) (interface{}, error) {
+	ctx := _re.buildContext(doc)
 	return fgRule.Evaluate(ctx)
 }
+
+// EvaluateRuleSet evaluates all the rules of the rule set for the given jsonData.
+// The input is parsed once and the resolved fields are shared across the rules
+// args:
+//
+//	ruleSet: The rules to evaluate
+//	jsonData: The data to be used during evaluation
+//
+// Return
+//
+//	map[string]interface{}: Evaluation result of every rule keyed by rule name
+//	error: Any error during evaluation
+func (_re *RuleEngine) EvaluateRuleSet(ruleSet *RuleSet, jsonData []byte) (map[string]interface{}, error) {
+	return _re.EvaluateRuleSetDocument(ruleSet, ParseDocument(jsonData))
+}
+
+// EvaluateRuleSetDocument evaluates all the rules of the rule set for an already
+// parsed document
+func (_re *RuleEngine) EvaluateRuleSetDocument(ruleSet *RuleSet, doc *Document) (map[string]interface{}, error) {
+	ctx := _re.buildContext(doc)
+	results := make(map[string]interface{}, ruleSet.Len())
+	for _, name := range ruleSet.names {
+		result, err := ruleSet.rules[name].Evaluate(ctx)
+		if err != nil {
+			return nil, &RuleEvaluationError{Name: name, Err: err}
+		}
+		results[name] = result
+	}
+	return results, nil
+}
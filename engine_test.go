@@ -195,3 +195,63 @@ func TestEngineVectorRuleScalarConditionWithoutDomino(t *testing.T) {
 	assert.Equal(t, false, arrResult[3])
 
 }
+
+func TestEngineShortCircuitSkipsFieldResolution(t *testing.T) {
+	fgParser := NewRuleParser("IF: { a == 1 && b == 2 }")
+	rule, err := fgParser.ParseRule()
+	assert.Nil(t, err)
+	testdata := []byte(`{ "a": 2, "b": 2 }`)
+	ctx := NewContext()
+	assert.Nil(t, rule.BuildContext(testdata, ctx))
+	result, err := rule.Evaluate(ctx)
+	assert.Nil(t, err)
+	assert.Equal(t, false, result.([]bool)[0])
+	assert.True(t, ctx.KeyExists("a"))
+	assert.False(t, ctx.KeyExists("b"))
+}
+
+func TestEngineShortCircuitOr(t *testing.T) {
+	fgParser := NewRuleParser("IF: { a == 1 || b == 2 }")
+	rule, err := fgParser.ParseRule()
+	assert.Nil(t, err)
+	testdata := []byte(`{ "a": 1, "b": 3 }`)
+	ctx := NewContext()
+	assert.Nil(t, rule.BuildContext(testdata, ctx))
+	result, err := rule.Evaluate(ctx)
+	assert.Nil(t, err)
+	assert.Equal(t, true, result.([]bool)[0])
+	assert.False(t, ctx.KeyExists("b"))
+}
+
+func TestEngineVectorConditionStopsAtFirstFalse(t *testing.T) {
+	fgParser := NewRuleParser("IF: { FOR: i=0:b.size() { b[i].type == 10 } } THEN: { }")
+	rule, err := fgParser.ParseRule()
+	assert.Nil(t, err)
+	testdata := []byte(`{ "b": [ { "type": 9 }, { "type": 10 } ] }`)
+	ctx := NewContext()
+	assert.Nil(t, rule.BuildContext(testdata, ctx))
+	result, err := rule.Evaluate(ctx)
+	assert.Nil(t, err)
+	assert.Equal(t, false, result.([]bool)[0])
+	assert.True(t, ctx.KeyExists("b.0.type"))
+	assert.False(t, ctx.KeyExists("b.1.type"))
+}
+
+func TestSafeEvaluate(t *testing.T) {
+	rule, err := NewRuleParser("IF: { amount > 10 }").ParseRule()
+	assert.Nil(t, err)
+	re := NewRuleEngine()
+	var result interface{}
+	err = SafeEvaluate(func() error {
+		result, err = re.Evaluate(rule, []byte(`{"amount": 20}`))
+		return err
+	})
+	assert.Nil(t, err)
+	assert.Equal(t, []bool{true}, result)
+	err = SafeEvaluate(func() error {
+		_, err := re.Evaluate(rule, []byte(`{"amount": "x"}`))
+		return err
+	})
+	assert.IsType(t, &EvaluationPanicError{}, err)
+	assert.Equal(t, "Operands type not matching", err.Error())
+}
@@ -0,0 +1,19 @@
+// Package codegen shows the Go code generated from rules by gorule gen
+package codegen
+
+//go:generate go run github.com/praks-1529/gorule/cmd/gorule gen -rules transaction.rules -type Transaction -samples samples.ndjson
+
+// Transaction represents the payload the rules are evaluated on
+type Transaction struct {
+	Amount     int         `json:"amount"`
+	Type       string      `json:"type"`
+	Risk       float64     `json:"risk"`
+	Verified   bool        `json:"verified"`
+	Attributes []Attribute `json:"attributes"`
+}
+
+// Attribute represents one attribute of the transaction
+type Attribute struct {
+	Key  string `json:"key"`
+	Type string `json:"type"`
+}
@@ -0,0 +1,35 @@
+// Code generated by gorule gen. DO NOT EDIT.
+
+package codegen
+
+// HighValueCc evaluates the rule "high_value_cc"
+// High value credit card transaction
+func HighValueCc(in *Transaction) bool {
+	return ((in.Amount >= 10000) && (in.Type == "CREDIT_CARD"))
+}
+
+// RiskyUnverified evaluates the rule "risky_unverified"
+func RiskyUnverified(in *Transaction) bool {
+	return ((in.Risk > 0.75) || (in.Verified == false))
+}
+
+// AllAttributesValid evaluates the rule "all_attributes_valid"
+func AllAttributesValid(in *Transaction) bool {
+	return func() bool {
+		for i0 := 0; i0 < len(in.Attributes); i0++ {
+			if !(in.Attributes[i0].Type == "VALID") {
+				return false
+			}
+		}
+		return true
+	}()
+}
+
+// AttributeIsValid evaluates the rule "attribute_is_valid"
+func AttributeIsValid(in *Transaction) []bool {
+	var result []bool
+	for i0 := 0; i0 < len(in.Attributes); i0++ {
+		result = append(result, (in.Attributes[i0].Type == "VALID"))
+	}
+	return result
+}
@@ -0,0 +1,113 @@
+// Code generated by gorule gen. DO NOT EDIT.
+
+package codegen
+
+import (
+	"encoding/json"
+	"reflect"
+	"testing"
+
+	"github.com/praks-1529/gorule"
+)
+
+const goruleSource = "RULE \"high_value_cc\" PRIORITY 10 TAGS [fraud, cc] DESCRIPTION \"High value credit card transaction\":\n\tIF: { amount >= 10000 && type == \"CREDIT_CARD\" }\nRULE \"risky_unverified\" TAGS [fraud]:\n\tIF: { risk > 0.75 || verified == false }\nRULE \"all_attributes_valid\":\n\tIF: { FOR: i=0:attributes.size() { attributes[i].type == \"VALID\" } }\nRULE \"attribute_is_valid\":\n\tFOR: i=0:attributes.size() IF: { attributes[i].type == \"VALID\" }\n"
+
+var goruleSamples = []string{
+	"{\"amount\": 10000, \"type\": \"CREDIT_CARD\", \"risk\": 0.5, \"verified\": true, \"attributes\": [{\"key\": \"IP\", \"type\": \"VALID\"}, {\"key\": \"DEVICE\", \"type\": \"VALID\"}]}",
+	"{\"amount\": 9999, \"type\": \"CREDIT_CARD\", \"risk\": 0.9, \"verified\": true, \"attributes\": [{\"key\": \"IP\", \"type\": \"FRAUD\"}, {\"key\": \"DEVICE\", \"type\": \"VALID\"}]}",
+	"{\"amount\": 50000, \"type\": \"UPI\", \"risk\": 0.1, \"verified\": false, \"attributes\": []}",
+}
+
+func goruleParityInputs(t *testing.T) ([][]byte, []*Transaction) {
+	samples := goruleSamples
+	if len(samples) == 0 {
+		zero, err := json.Marshal(Transaction{})
+		if err != nil {
+			t.Fatal(err)
+		}
+		samples = []string{string(zero)}
+	}
+	var inputs []*Transaction
+	var payloads [][]byte
+	for _, sample := range samples {
+		in := &Transaction{}
+		if err := json.Unmarshal([]byte(sample), in); err != nil {
+			t.Fatal(err)
+		}
+		inputs = append(inputs, in)
+		payloads = append(payloads, []byte(sample))
+	}
+	return payloads, inputs
+}
+
+func goruleParityRule(t *testing.T, name string) gorule.Rule {
+	ruleSet, err := gorule.NewRuleParser(goruleSource).ParseRuleSet()
+	if err != nil {
+		t.Fatal(err)
+	}
+	rule, ok := ruleSet.Get(name)
+	if !ok {
+		t.Fatalf("rule %s not found", name)
+	}
+	return rule
+}
+
+func TestHighValueCcParity(t *testing.T) {
+	rule := goruleParityRule(t, "high_value_cc")
+	payloads, inputs := goruleParityInputs(t)
+	re := gorule.NewRuleEngine()
+	for i := range payloads {
+		expected, err := re.Evaluate(rule, payloads[i])
+		if err != nil {
+			t.Fatal(err)
+		}
+		if actual := []bool{HighValueCc(inputs[i])}; !reflect.DeepEqual(expected, actual) {
+			t.Errorf("sample %d: interpreter %v generated %v", i, expected, actual)
+		}
+	}
+}
+
+func TestRiskyUnverifiedParity(t *testing.T) {
+	rule := goruleParityRule(t, "risky_unverified")
+	payloads, inputs := goruleParityInputs(t)
+	re := gorule.NewRuleEngine()
+	for i := range payloads {
+		expected, err := re.Evaluate(rule, payloads[i])
+		if err != nil {
+			t.Fatal(err)
+		}
+		if actual := []bool{RiskyUnverified(inputs[i])}; !reflect.DeepEqual(expected, actual) {
+			t.Errorf("sample %d: interpreter %v generated %v", i, expected, actual)
+		}
+	}
+}
+
+func TestAllAttributesValidParity(t *testing.T) {
+	rule := goruleParityRule(t, "all_attributes_valid")
+	payloads, inputs := goruleParityInputs(t)
+	re := gorule.NewRuleEngine()
+	for i := range payloads {
+		expected, err := re.Evaluate(rule, payloads[i])
+		if err != nil {
+			t.Fatal(err)
+		}
+		if actual := []bool{AllAttributesValid(inputs[i])}; !reflect.DeepEqual(expected, actual) {
+			t.Errorf("sample %d: interpreter %v generated %v", i, expected, actual)
+		}
+	}
+}
+
+func TestAttributeIsValidParity(t *testing.T) {
+	rule := goruleParityRule(t, "attribute_is_valid")
+	payloads, inputs := goruleParityInputs(t)
+	re := gorule.NewRuleEngine()
+	for i := range payloads {
+		expected, err := re.Evaluate(rule, payloads[i])
+		if err != nil {
+			t.Fatal(err)
+		}
+		if actual := AttributeIsValid(inputs[i]); !reflect.DeepEqual(expected, actual) {
+			t.Errorf("sample %d: interpreter %v generated %v", i, expected, actual)
+		}
+	}
+}
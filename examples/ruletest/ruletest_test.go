@@ -0,0 +1,12 @@
+package ruletest
+
+import (
+	"testing"
+
+	"github.com/praks-1529/gorule/ruletest"
+)
+
+// Runs the cases of the fixtures, one sub test per case
+func TestTransactionRules(t *testing.T) {
+	ruletest.Run(t, "*_test.yaml")
+}
@@ -0,0 +1,191 @@
+// File: format.go
+// Implements formatting of the parsed rules back to the rule text
+package gorule
+
+import (
+	"fmt"
+	"strconv"
+	"strings"
+)
+
+// Format returns the canonical text of the rule. The text parses back to an
+// identical rule
+//
+// Format: RULE "name" PRIORITY 10 TAGS [a, b] DESCRIPTION "description":
+//
+//	IF: { CONDITION } THEN: { ACTION; ACTION }
+func Format(rule Rule) string {
+	header := formatRuleHeader(rule.GetMetadata())
+	if header == "" {
+		return formatRuleBody(rule)
+	}
+	return header + "\n\t" + formatRuleBody(rule)
+}
+
+// FormatRuleSet returns the canonical text of all the rules of the rule set in
+// the order they were added
+func FormatRuleSet(ruleSet *RuleSet) string {
+	var sb strings.Builder
+	for _, name := range ruleSet.names {
+		rule := ruleSet.rules[name]
+		metadata := *rule.GetMetadata()
+		metadata.Name = name
+		sb.WriteString(formatRuleHeader(&metadata))
+		sb.WriteString("\n\t")
+		sb.WriteString(formatRuleBody(rule))
+		sb.WriteString("\n")
+	}
+	return sb.String()
+}
+
+// FormatCondition returns the canonical text of the condition
+func FormatCondition(condition Condition) string {
+	return formatCondition(condition, false)
+}
+
+func formatRuleHeader(metadata *RuleMetadata) string {
+	if metadata.GetName() == "" {
+		return ""
+	}
+	header := fmt.Sprintf("%s %s", RuleToken, quoteHeader(metadata.GetName()))
+	if metadata.GetPriority() != 0 {
+		header += fmt.Sprintf(" %s %d", PriorityToken, metadata.GetPriority())
+	}
+	if len(metadata.GetTags()) > 0 {
+		header += fmt.Sprintf(" %s [%s]", TagsToken, strings.Join(metadata.GetTags(), ", "))
+	}
+	if metadata.GetDescription() != "" {
+		header += fmt.Sprintf(" %s %s", DescriptionToken, quoteHeader(metadata.GetDescription()))
+	}
+	return header + string(ColonToken)
+}
+
+// headerEscaper escapes the quotes (and the backslashes) of the strings of a
+// rule header so they parse back as a single token
+var headerEscaper = strings.NewReplacer(`\`, `\\`, `"`, `\"`)
+
+// quoteHeader returns the quoted name or description of a rule header
+func quoteHeader(value string) string {
+	return "\"" + headerEscaper.Replace(value) + "\""
+}
+
+func formatRuleBody(rule Rule) string {
+	switch fgRule := rule.(type) {
+	case *ScalarRule:
+		text := fmt.Sprintf("%s %s", IfToken, formatConditionBlock(fgRule.If))
+		if len(fgRule.Then) > 0 {
+			text += fmt.Sprintf(" %s %s", ThenToken, formatActions(fgRule.Then))
+		}
+		return text
+	case *VectorRule:
+		return fmt.Sprintf("%s %v=%v:%v %s", ForToken, fgRule.IndexKey, fgRule.StartIndex, fgRule.EndIndex, formatRuleBody(fgRule.SRule))
+	case *CompiledRule:
+		return formatRuleBody(fgRule.GetRule())
+	}
+	return ""
+}
+
+// formatConditionBlock returns the condition in the braces ex. { a == b }
+func formatConditionBlock(condition Condition) string {
+	return fmt.Sprintf("%s %s %s", CurlyOpenBraceToken, formatCondition(condition, false), CurlyCloseBraceToken)
+}
+
+func formatActions(actions ActionList) string {
+	var texts []string
+	for _, action := range actions {
+		if assignAction, ok := action.(*AssignAction); ok {
+			texts = append(texts, fmt.Sprintf("%s %s %s", assignAction.Path, AssignToken, formatCondition(assignAction.Value, false)))
+		}
+	}
+	return fmt.Sprintf("%s %s %s", CurlyOpenBraceToken, strings.Join(texts, string(SemicolonToken)+" "), CurlyCloseBraceToken)
+}
+
+// formatCondition returns the text of the condition. Operands are grouped in
+// ( ) unless the parser builds the same tree without the group
+func formatCondition(condition Condition, isGroup bool) string {
+	var text string
+	switch cond := condition.(type) {
+	case *VectorCondition:
+		return fmt.Sprintf("%s %s=%v:%v %s", ForToken, cond.IndexKey, cond.StartIndex, cond.EndIndex, formatConditionBlock(cond.SCondition))
+	case *ScalarCondition:
+		if cond.GetOperator() == NilOperator {
+			return formatValue(cond.GetValue())
+		}
+		optor := cond.GetOperator()
+		operand1 := formatCondition(cond.GetOperand1(), needsGroup(optor, cond.GetOperand1(), false))
+		operand2 := formatCondition(cond.GetOperand2(), needsGroup(optor, cond.GetOperand2(), true))
+		text = fmt.Sprintf("%s %s %s", operand1, optor, operand2)
+	case *LogicalCondition:
+		operands := cond.GetOperands()
+		if len(operands) == 0 {
+			return formatValue(cond.GetOperator() == AndOperator)
+		}
+		texts := make([]string, len(operands))
+		for i, operand := range operands {
+			texts[i] = formatCondition(operand, needsGroup(cond.GetOperator(), operand, i == len(operands)-1))
+		}
+		if len(texts) == 1 {
+			return texts[0]
+		}
+		text = strings.Join(texts, fmt.Sprintf(" %s ", cond.GetOperator()))
+	default:
+		return ""
+	}
+	if isGroup {
+		return fmt.Sprintf("%s %s %s", OpenBraceToken, text, CloseBraceToken)
+	}
+	return text
+}
+
+// needsGroup checks if the operand of optor has to be grouped in ( ). The parser
+// joins the operators from the right, so a && b && c is a && ( b && c )
+func needsGroup(optor Operator, operand Condition, isRight bool) bool {
+	var operandOptor Operator
+	switch cond := operand.(type) {
+	case *ScalarCondition:
+		operandOptor = cond.GetOperator()
+	case *LogicalCondition:
+		switch len(cond.GetOperands()) {
+		case 0:
+			// Formatted as a literal
+			return false
+		case 1:
+			return needsGroup(optor, cond.GetOperands()[0], isRight)
+		}
+		operandOptor = cond.GetOperator()
+	default:
+		return false
+	}
+	if operandOptor == NilOperator {
+		return false
+	}
+	if isArithmetic(optor) {
+		// * and / bind tighter than + and -, the parser joins the operators of
+		// the same precedence from the left
+		precedence, operandPrecedence := arithmeticPrecedence(optor), arithmeticPrecedence(operandOptor)
+		return operandPrecedence < precedence || (isRight && operandPrecedence == precedence)
+	}
+	isLogical := func(optor Operator) bool {
+		return optor == AndOperator || optor == OrOperator
+	}
+	if isLogical(optor) && !isLogical(operandOptor) {
+		// Comparisons bind tighter than the logical operators
+		return false
+	}
+	return !(isRight && optor == operandOptor)
+}
+
+// formatValue returns the text of a literal which parses back to the same type
+func formatValue(value interface{}) string {
+	switch literal := value.(type) {
+	case float64:
+		text := strconv.FormatFloat(literal, 'g', -1, 64)
+		if !strings.ContainsAny(text, ".eIN") {
+			text += ".0"
+		}
+		return text
+	case nil:
+		return ""
+	}
+	return fmt.Sprintf("%v", value)
+}
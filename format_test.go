@@ -0,0 +1,92 @@
+// File: format_test.go
+// Tests for formatting the parsed rules
+package gorule
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestFormat(t *testing.T) {
+	tests := map[string]string{
+		"IF:   {  a  ==\t10 }":                                                      "IF: { a == 10 }",
+		"IF: { a == 10 &&  c == true }":                                             "IF: { a == 10 && c == true }",
+		"IF: { a == 10.0 || b >= 5.90 }":                                            "IF: { a == 10.0 || b >= 5.9 }",
+		"IF: { (a == 1 || b == 2) && c == 3 }":                                      "IF: { ( a == 1 || b == 2 ) && c == 3 }",
+		"IF: { a == 1 && b == 2 || c == 3 }":                                        "IF: { a == 1 && ( b == 2 || c == 3 ) }",
+		"IF: { type == \"CREDIT CARD\" } THEN: { }":                                 "IF: { type == \"CREDIT CARD\" }",
+		"IF: { FOR: i=0:a.size() { a[i].b == 1 } } THEN: { }":                       "IF: { FOR: i=0:a.size() { a[i].b == 1 } }",
+		"FOR: i=0:a.size() IF: { a[i].b == 1 } THEN: { a[i].c = 2;   x = \"Y\"; }":  "FOR: i=0:a.size() IF: { a[i].b == 1 } THEN: { a[i].c = 2; x = \"Y\" }",
+		"RULE \"r\" TAGS [x,y] PRIORITY 2 DESCRIPTION \"Some rule\": IF: { a > 1 }": "RULE \"r\" PRIORITY 2 TAGS [x, y] DESCRIPTION \"Some rule\":\n\tIF: { a > 1 }",
+		"IF: { a > 1 } THEN: { x = (a * 2) + (b * 3) - (c - d); y = a / (b * c) }":  "IF: { a > 1 } THEN: { x = a * 2 + b * 3 - ( c - d ); y = a / ( b * c ) }",
+	}
+	for src, expected := range tests {
+		rule, err := NewRuleParser(src).ParseRule()
+		assert.Nil(t, err, src)
+		assert.Equal(t, expected, Format(rule), src)
+	}
+}
+
+func TestFormatRoundTrip(t *testing.T) {
+	sources := append([]string{
+		"IF: { ( a == 1 || b == 2 ) && ( c == 3 || ( d == 4 && e == 5 ) ) }",
+		"IF: { a == b == true }",
+		"IF: { ( a && b ) == c && d }",
+	}, serializeTestRules...)
+	for _, src := range sources {
+		rule, err := NewRuleParser(src).ParseRule()
+		assert.Nil(t, err, src)
+		reparsedRule, err := NewRuleParser(Format(rule)).ParseRule()
+		assert.Nil(t, err, Format(rule))
+		assert.Equal(t, rule, reparsedRule, src)
+	}
+}
+
+func TestFormatHeaderQuotes(t *testing.T) {
+	rule, err := NewRuleParser(`RULE "r" DESCRIPTION "Blocks \"risky\" cards, see C:\\rules": IF: { a > 1 }`).ParseRule()
+	assert.Nil(t, err)
+	assert.Equal(t, `Blocks "risky" cards, see C:\rules`, rule.GetMetadata().GetDescription())
+	// A header holding quotes formats to a text which parses back to it
+	rule.GetMetadata().Name = `say "hi"`
+	formatted := Format(rule)
+	assert.Equal(t, `RULE "say \"hi\"" DESCRIPTION "Blocks \"risky\" cards, see C:\\rules":`+"\n\tIF: { a > 1 }", formatted)
+	reparsedRule, err := NewRuleParser(formatted).ParseRule()
+	assert.Nil(t, err, formatted)
+	assert.Equal(t, rule, reparsedRule)
+}
+
+func TestFormatLeftJoinedTree(t *testing.T) {
+	// ( a && b ) && c can not be written without a group
+	leaf := func(value interface{}) Condition {
+		return &ScalarCondition{Type: ScalarConditionType, Operator: NilOperator, Value: value}
+	}
+	join := func(optor Operator, operand1 Condition, operand2 Condition) Condition {
+		return &ScalarCondition{Type: ScalarConditionType, Operator: optor, Operand1: operand1, Operand2: operand2}
+	}
+	rule := &ScalarRule{Type: ScalarRuleType, If: join(AndOperator, join(AndOperator, leaf("a"), leaf("b")), leaf("c"))}
+	assert.Equal(t, "IF: { ( a && b ) && c }", Format(rule))
+	reparsedRule, err := NewRuleParser(Format(rule)).ParseRule()
+	assert.Nil(t, err)
+	assert.Equal(t, rule, reparsedRule)
+}
+
+func TestFormatRuleSet(t *testing.T) {
+	ruleSet, err := NewRuleParser(testPricingRules).ParseRuleSet()
+	assert.Nil(t, err)
+	text := FormatRuleSet(ruleSet)
+	assert.Contains(t, text, "RULE \"gold\" PRIORITY 10:\n\tIF: { amount >= 10000 }\n")
+	reparsedRuleSet, err := NewRuleParser(text).ParseRuleSet()
+	assert.Nil(t, err)
+	assert.Equal(t, ruleSet, reparsedRuleSet)
+	assert.Equal(t, text, FormatRuleSet(reparsedRuleSet))
+}
+
+func TestParseGroupErrors(t *testing.T) {
+	_, err := NewRuleParser("IF: { ( a == 1 }").ParseRule()
+	assert.IsType(t, &SyntaxError{}, err)
+	_, err = NewRuleParser("IF: { a == 1 ) }").ParseRule()
+	assert.IsType(t, &SyntaxError{}, err)
+	_, err = NewRuleParser("IF: { }").ParseRule()
+	assert.IsType(t, &MalformedRuleError{}, err)
+}
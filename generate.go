@@ -0,0 +1,252 @@
+// File: generate.go
+// Implements the generation of test payloads from the conditions of the rules
+package gorule
+
+import (
+	"encoding/json"
+	"fmt"
+	"math"
+	"strconv"
+	"strings"
+)
+
+// GeneratedPayload represents a payload generated from a rule along with the
+// outcome of evaluating the rule for it
+type GeneratedPayload struct {
+	// Name describes the payload (ex. match 1, no match 1, amount = 9999)
+	Name    string                 `json:"name"`
+	Payload map[string]interface{} `json:"payload"`
+	Match   bool                   `json:"match"`
+	// values holds the values of the payload by path
+	values map[string]interface{}
+}
+
+// payloadGenerator collects the distinct payloads of a rule
+type payloadGenerator struct {
+	rule Rule
+	// arrays holds the length of the arrays iterated by the FOR loops
+	arrays map[string]int
+	// base holds the values of the first payload, completing the payloads the
+	// engine fails to evaluate (ex. a missing field compared with a number)
+	base     map[string]interface{}
+	payloads []*GeneratedPayload
+	seen     map[string]*GeneratedPayload
+	counts   map[string]int
+}
+
+// GeneratePayloads generates minimal payloads making the rule evaluate true
+// and false, then boundary payloads for each comparison of a field with a
+// literal (ex. 9999, 10000 and 10001 for amount >= 10000). FOR conditions get
+// arrays with a single element iterated. Payloads the engine fails to
+// evaluate (ex. an int compared with a float) are left out
+func GeneratePayloads(rule Rule) []*GeneratedPayload {
+	generator := &payloadGenerator{rule: rule, arrays: make(map[string]int), seen: make(map[string]*GeneratedPayload), counts: make(map[string]int)}
+	trueForm := ruleForm(rule, false, generator.arrays)
+	falseForm := ruleForm(rule, true, generator.arrays)
+	var conjunctions []conjunction
+	var solutions []map[string]interface{}
+	// falseSolutions is the index of the first solution of the false form
+	falseSolutions := 0
+	for i, conj := range append(trueForm, falseForm...) {
+		if i == len(trueForm) {
+			falseSolutions = len(solutions)
+		}
+		if values, ok := solve(conj); ok {
+			conjunctions = append(conjunctions, conj)
+			solutions = append(solutions, values)
+		}
+	}
+	if len(solutions) > 0 {
+		generator.base = solutions[0]
+	}
+	for i := range solutions {
+		if payload := generator.add("", solutions[i]); payload != nil {
+			solutions[i] = payload.values
+		}
+	}
+	// Boundaries of every literal a field is compared with, on top of the
+	// solution of the conjunction of the comparison
+	seenBoundaries := make(map[string]bool)
+	for i, conj := range conjunctions {
+		for _, clause := range conj.atoms {
+			key := fmt.Sprintf("%s %T %v", clause.path, clause.value, clause.value)
+			if seenBoundaries[key] {
+				continue
+			}
+			seenBoundaries[key] = true
+			for _, value := range boundaryValues(clause.value) {
+				data, _ := json.Marshal(value)
+				generator.addBoundary(fmt.Sprintf("%s = %s", clause.path, data), map[string]interface{}{clause.path: value}, solutions, i, falseSolutions)
+			}
+		}
+	}
+	return generator.payloads
+}
+
+// mergeValues returns the values of base overridden by the values
+func mergeValues(base map[string]interface{}, values map[string]interface{}) map[string]interface{} {
+	merged := make(map[string]interface{}, len(base)+len(values))
+	for path, value := range base {
+		merged[path] = value
+	}
+	for path, value := range values {
+		merged[path] = value
+	}
+	return merged
+}
+
+// addBoundary adds the payload of the boundary values on top of the solution
+// of their conjunction. If the engine fails to evaluate it, the solutions of
+// the false form (keeping the other clauses of a disjunction false) then the
+// ones of the true form are tried in turn
+func (_pg *payloadGenerator) addBoundary(name string, values map[string]interface{}, solutions []map[string]interface{}, conjunction int, falseSolutions int) {
+	order := []int{conjunction}
+	for i := falseSolutions; i < len(solutions); i++ {
+		order = append(order, i)
+	}
+	for i := 0; i < falseSolutions; i++ {
+		order = append(order, i)
+	}
+	for n, i := range order {
+		if n > 0 && i == conjunction {
+			continue
+		}
+		if _pg.add(name, mergeValues(solutions[i], values)) != nil {
+			return
+		}
+	}
+}
+
+// add evaluates the rule for the payload of the values and keeps it unless
+// the evaluation fails, even with the values completed by the base. Returns
+// the kept payload, which is the earlier one if the same payload is already
+// kept. Payloads without name are named after the outcome (ex. match 1)
+func (_pg *payloadGenerator) add(name string, values map[string]interface{}) *GeneratedPayload {
+	payload := witnessPayload(values, _pg.arrays)
+	data, _ := json.Marshal(payload)
+	matched, err := evaluatePayload(_pg.rule, data)
+	if err != nil && _pg.base != nil {
+		values = mergeValues(_pg.base, values)
+		payload = witnessPayload(values, _pg.arrays)
+		data, _ = json.Marshal(payload)
+		matched, err = evaluatePayload(_pg.rule, data)
+	}
+	if err != nil {
+		return nil
+	}
+	if kept, ok := _pg.seen[string(data)]; ok {
+		return kept
+	}
+	if name == "" {
+		name = "no match"
+		if matched {
+			name = "match"
+		}
+		_pg.counts[name]++
+		name = fmt.Sprintf("%s %d", name, _pg.counts[name])
+	}
+	generated := &GeneratedPayload{Name: name, Payload: payload, Match: matched, values: values}
+	_pg.seen[string(data)] = generated
+	_pg.payloads = append(_pg.payloads, generated)
+	return generated
+}
+
+// evaluatePayload evaluates the rule for the payload. A panic of the engine is
+// returned as an error
+func evaluatePayload(rule Rule, data []byte) (matched bool, err error) {
+	err = SafeEvaluate(func() error {
+		result, err := NewRuleEngine().Evaluate(rule, data)
+		matched = err == nil && isMatch(result)
+		return err
+	})
+	return matched && err == nil, err
+}
+
+// boundaryValues returns the values around a literal: the previous and next
+// integers (or steps of the last decimal of a float) and the literal itself.
+// Strings and bools get the literal and another value
+func boundaryValues(literal interface{}) []interface{} {
+	switch value := literal.(type) {
+	case int:
+		return []interface{}{value - 1, value, value + 1}
+	case float64:
+		text := strconv.FormatFloat(value, 'f', -1, 64)
+		decimals := 1
+		if dot := strings.IndexByte(text, '.'); dot >= 0 {
+			decimals = len(text) - dot - 1
+		}
+		scale := math.Pow(10, float64(decimals))
+		round := func(number float64) float64 { return math.Round(number*scale) / scale }
+		return []interface{}{round(value - 1/scale), value, round(value + 1/scale)}
+	case bool:
+		return []interface{}{value, !value}
+	case string:
+		return []interface{}{unquote(value), unquote(value) + "_other"}
+	}
+	return nil
+}
+
+// GenerateTestSuite generates a test fixture of the rule set from the payloads
+// of every rule (see GeneratePayloads). rules is the path of the rule file as
+// written in the fixture. The payloads are completed with values for the
+// fields of the other rules so every rule can be evaluated, and the expected
+// winners are the current ones: review them before committing the fixture
+func GenerateTestSuite(ruleSet *RuleSet, rules string) *TestSuite {
+	suite := &TestSuite{Rules: rules, Strategy: "all", Cases: []TestCase{}}
+	arrays := make(map[string]int)
+	background := make(map[string]interface{})
+	generated := make(map[string][]*GeneratedPayload)
+	for _, name := range ruleSet.names {
+		rule := ruleSet.rules[name]
+		generated[name] = GeneratePayloads(rule)
+		for _, payload := range generated[name] {
+			for path, value := range payload.values {
+				if _, ok := background[path]; !ok {
+					background[path] = value
+				}
+			}
+		}
+		ruleForm(rule, false, arrays)
+	}
+	engine := NewRuleEngine()
+	seen := make(map[string]bool)
+	for _, name := range ruleSet.names {
+		for _, payload := range generated[name] {
+			values := mergeValues(background, payload.values)
+			input := witnessPayload(values, arrays)
+			data, _ := json.Marshal(input)
+			if seen[string(data)] {
+				continue
+			}
+			seen[string(data)] = true
+			winners, err := evaluateWinners(engine, ruleSet, data)
+			if err != nil {
+				continue
+			}
+			testCase := TestCase{Name: fmt.Sprintf("%s: %s", name, payload.Name), Input: input}
+			if len(winners) == 0 {
+				noMatch := false
+				testCase.Expect.Match = &noMatch
+			} else {
+				testCase.Expect.Matched = winners
+			}
+			suite.Cases = append(suite.Cases, testCase)
+		}
+	}
+	return suite
+}
+
+// evaluateWinners returns all the matching rules of the rule set for the payload
+func evaluateWinners(engine *RuleEngine, ruleSet *RuleSet, data []byte) (winners []string, err error) {
+	err = SafeEvaluate(func() error {
+		result, err := engine.EvaluateRuleSetWithStrategy(ruleSet, data, AllMatchesStrategy)
+		if err == nil {
+			winners = result.Winners
+		}
+		return err
+	})
+	if err != nil {
+		return nil, err
+	}
+	return winners, nil
+}
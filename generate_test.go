@@ -0,0 +1,68 @@
+// File: generate_test.go
+package gorule
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func generatedPayloads(t *testing.T, src string) map[string]*GeneratedPayload {
+	payloads := make(map[string]*GeneratedPayload)
+	for _, payload := range GeneratePayloads(parseRuleText(t, src)) {
+		payloads[payload.Name] = payload
+	}
+	return payloads
+}
+
+func TestGeneratePayloads(t *testing.T) {
+	payloads := generatedPayloads(t, `IF: { amount >= 10000 && ( type == "CC" || rate < 1.25 ) }`)
+	assert.Equal(t, map[string]interface{}{"amount": 10000, "type": "CC"}, payloads["match 1"].Payload)
+	assert.True(t, payloads["match 1"].Match)
+	assert.Equal(t, map[string]interface{}{"amount": 10000, "rate": 0.75}, payloads["match 2"].Payload)
+	assert.Equal(t, map[string]interface{}{"amount": 9999}, payloads["no match 1"].Payload)
+	assert.False(t, payloads["no match 1"].Match)
+	// Missing fields compared with numbers fail the engine, they are completed
+	assert.Equal(t, map[string]interface{}{"amount": 10000, "rate": 1.75, "type": "value0"}, payloads["no match 2"].Payload)
+
+	assert.False(t, payloads["amount = 9999"].Match)
+	assert.True(t, payloads["amount = 10001"].Match)
+	assert.True(t, payloads["rate = 1.24"].Match)
+	assert.False(t, payloads["rate = 1.25"].Match)
+	assert.False(t, payloads["rate = 1.26"].Match)
+
+	// The boundaries of rate fail the engine without vip, they are completed
+	// by the false form
+	payloads = generatedPayloads(t, `IF: { rate > 1.25 || vip == true }`)
+	assert.Equal(t, map[string]interface{}{"rate": 1.24, "vip": false}, payloads["rate = 1.24"].Payload)
+	assert.False(t, payloads["rate = 1.24"].Match)
+	assert.Equal(t, map[string]interface{}{"rate": 1.25, "vip": false}, payloads["rate = 1.25"].Payload)
+	assert.False(t, payloads["rate = 1.25"].Match)
+	assert.True(t, payloads["rate = 1.26"].Match)
+}
+
+func TestGeneratePayloadsFor(t *testing.T) {
+	payloads := generatedPayloads(t, `FOR: i=1:items.size() IF: { items[i].price > 10 && flag == true }`)
+	// The iteration starts at 1, the arrays hold two elements
+	item := map[string]interface{}{"price": 11}
+	assert.Equal(t, map[string]interface{}{"flag": true, "items": []interface{}{item, item}}, payloads["match 1"].Payload)
+	assert.True(t, payloads["match 1"].Match)
+	assert.False(t, payloads["items[i].price = 10"].Match)
+
+	payloads = generatedPayloads(t, `IF: { FOR: i=0:scores.size() { scores[i] <= 5 } }`)
+	assert.Equal(t, map[string]interface{}{"scores": []interface{}{5}}, payloads["match 1"].Payload)
+	assert.Equal(t, map[string]interface{}{"scores": []interface{}{6}}, payloads["no match 1"].Payload)
+}
+
+func TestGenerateTestSuite(t *testing.T) {
+	ruleSet := parseRuleSetText(t, pricingRules)
+	suite := GenerateTestSuite(ruleSet, "pricing.rules")
+	assert.Equal(t, "pricing.rules", suite.Rules)
+	assert.Equal(t, "bronze: match 1", suite.Cases[0].Name)
+	assert.Equal(t, map[string]interface{}{"amount": 999}, suite.Cases[0].Input)
+	assert.Equal(t, []string{"bronze"}, suite.Cases[0].Expect.Matched)
+	for _, testCase := range suite.Cases {
+		// The tiers cover every amount
+		assert.Equal(t, 1, len(testCase.Expect.Matched), testCase.Name)
+	}
+}
@@ -0,0 +1,186 @@
+// File: inference.go
+// Implements forward chaining inference over a rule set
+package gorule
+
+import "fmt"
+
+// DefaultMaxIterations is the default limit of the inference passes
+const DefaultMaxIterations = 100
+
+// InferenceLimitError raised when the inference does not reach a fixpoint within
+// the iteration limit (ex. rules asserting conflicting facts in a cycle)
+type InferenceLimitError struct {
+	MaxIterations int
+	Firings       []Firing
+}
+
+func (_rt *InferenceLimitError) Error() string {
+	return fmt.Sprintf("Inference did not reach a fixpoint in %d iterations", _rt.MaxIterations)
+}
+
+// Firing represents one rule whose actions changed the working memory
+type Firing struct {
+	// Iteration is the inference pass (starting at 1) in which the rule fired
+	Iteration int `json:"iteration"`
+	// Rule is the name of the rule
+	Rule string `json:"rule"`
+	// Index is the index of a vector rule (-1 for scalar rule)
+	Index int `json:"index"`
+	// Changes holds the facts changed by the actions of the rule
+	Changes map[string]interface{} `json:"changes"`
+}
+
+// InferenceResult represents the outcome of the forward chaining inference
+type InferenceResult struct {
+	// Facts holds the working memory at the fixpoint
+	Facts map[string]interface{} `json:"facts"`
+	// Firings holds the firing sequence of the rules
+	Firings []Firing `json:"firings"`
+	// Iterations is the number of passes taken to reach the fixpoint
+	Iterations int `json:"iterations"`
+}
+
+// Infer runs forward chaining over the rule set for the given jsonData. The
+// actions of the matching rules assert facts in a working memory, which take
+// precedence over the input fields. Rules reading changed facts are re-evaluated
+// until no rule changes the working memory (fixpoint)
+// args:
+//
+//	ruleSet: The rules to evaluate
+//	jsonData: The data to be used during evaluation
+//	maxIterations: Limit of the inference passes (DefaultMaxIterations if <= 0)
+//
+// Return
+//
+//	InferenceResult: Facts at fixpoint and the firing sequence
+//	error: Any error during evaluation or InferenceLimitError
+func (_re *RuleEngine) Infer(ruleSet *RuleSet, jsonData []byte, maxIterations int) (*InferenceResult, error) {
+	return _re.InferDocument(ruleSet, ParseDocument(jsonData), maxIterations)
+}
+
+// InferDocument runs forward chaining over the rule set for an already parsed document
+func (_re *RuleEngine) InferDocument(ruleSet *RuleSet, doc *Document, maxIterations int) (*InferenceResult, error) {
+	if maxIterations <= 0 {
+		maxIterations = DefaultMaxIterations
+	}
+	memory := NewWorkingMemory()
+	result := &InferenceResult{Firings: []Firing{}}
+	order := ruleSet.SalienceOrder()
+	// Fields read by every rule in its last evaluation
+	dependencies := make(map[string]map[string]bool, len(order))
+	agenda := make(map[string]bool, len(order))
+	for _, name := range order {
+		agenda[name] = true
+	}
+	for len(agenda) > 0 {
+		if result.Iterations == maxIterations {
+			return nil, &InferenceLimitError{MaxIterations: maxIterations, Firings: result.Firings}
+		}
+		result.Iterations++
+		changed := make(map[string]bool)
+		for _, name := range order {
+			if !agenda[name] {
+				continue
+			}
+			ctx := _re.buildContext(doc)
+			ctx.SetValue(WorkingMemoryKey, memory)
+			firings, err := fireRule(ruleSet.rules[name], ctx, memory)
+			if err != nil {
+				return nil, &RuleEvaluationError{Name: name, Err: err}
+			}
+			dependencies[name] = readKeys(ctx)
+			for _, firing := range firings {
+				firing.Iteration = result.Iterations
+				firing.Rule = name
+				result.Firings = append(result.Firings, firing)
+				for path := range firing.Changes {
+					changed[path] = true
+				}
+			}
+		}
+		// Only the rules reading the changed facts are evaluated again
+		agenda = make(map[string]bool)
+		for _, name := range order {
+			for path := range changed {
+				if dependencies[name][path] {
+					agenda[name] = true
+					break
+				}
+			}
+		}
+	}
+	result.Facts = memory.Facts()
+	return result, nil
+}
+
+// fireRule evaluates the rule and executes the actions if it matches
+func fireRule(rule Rule, ctx Context, memory *WorkingMemory) ([]Firing, error) {
+	switch fgRule := rule.(type) {
+	case *ScalarRule:
+		firing, err := fireScalarRule(fgRule, ctx, memory)
+		if err != nil || firing == nil {
+			return nil, err
+		}
+		firing.Index = -1
+		return []Firing{*firing}, nil
+	case *VectorRule:
+		var firings []Firing
+		startIndex := fgRule.getInitialValue(ctx)
+		endIndex := fgRule.getFinalValue(ctx)
+		for i := startIndex; i < endIndex; i++ {
+			ctx.SetValue(IndexKey, fgRule.IndexKey)
+			ctx.SetValue(IndexCurrentValue, i)
+			firing, err := fireScalarRule(fgRule.SRule.(*ScalarRule), ctx, memory)
+			if err != nil {
+				return nil, err
+			}
+			if firing != nil {
+				firing.Index = i
+				firings = append(firings, *firing)
+			}
+		}
+		return firings, nil
+	}
+	return nil, nil
+}
+
+// fireScalarRule evaluates the rule and executes the actions if it matches.
+// Returns nil if the working memory was not changed
+func fireScalarRule(rule *ScalarRule, ctx Context, memory *WorkingMemory) (*Firing, error) {
+	result, err := rule.Evaluate(ctx)
+	if err != nil {
+		return nil, err
+	}
+	if !isMatch(result) || len(rule.Then) == 0 {
+		return nil, nil
+	}
+	paths, err := executeActions(rule.Then, ctx)
+	if err != nil || len(paths) == 0 {
+		return nil, err
+	}
+	firing := &Firing{Changes: make(map[string]interface{}, len(paths))}
+	for _, path := range paths {
+		firing.Changes[path], _ = memory.Get(path)
+	}
+	return firing, nil
+}
+
+// engineKeys are the keys of the context set by the engine rather than
+// resolved from the input (fields like _id are kept as dependencies)
+var engineKeys = map[string]bool{
+	IndexKey: true, IndexCurrentValue: true, StartIndexValue: true, EndIndexValue: true,
+	InputDataKey: true, WorkingMemoryKey: true,
+}
+
+// readKeys returns the fields resolved in the context
+func readKeys(ctx Context) map[string]bool {
+	keys := make(map[string]bool)
+	if ruleCtx, ok := ctx.(*RuleContext); ok {
+		for key := range ruleCtx.ctxMap {
+			if !engineKeys[key] {
+				keys[key] = true
+			}
+		}
+	}
+	return keys
+}
@@ -0,0 +1,106 @@
+// File: inference_test.go
+// Tests for forward chaining inference
+package gorule
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestParseActions(t *testing.T) {
+	rule, err := NewRuleParser("IF: { amount >= 10000 } THEN: { tier = \"GOLD\"; discount = 10 ; flagged = true }").ParseRule()
+	assert.Nil(t, err)
+	actions := rule.(*ScalarRule).Then
+	assert.Equal(t, 3, len(actions))
+	assert.Equal(t, "tier", actions[0].(*AssignAction).Path)
+	assert.Equal(t, "\"GOLD\"", actions[0].(*AssignAction).Value.GetValue())
+	assert.Equal(t, 10, actions[1].(*AssignAction).Value.GetValue())
+	assert.Equal(t, true, actions[2].(*AssignAction).Value.GetValue())
+}
+
+func TestParseActionsSyntaxError(t *testing.T) {
+	_, err := NewRuleParser("IF: { a == 1 } THEN: { tier \"GOLD\" }").ParseRule()
+	assert.IsType(t, &SyntaxError{}, err)
+	_, err = NewRuleParser("IF: { a == 1 } THEN: { tier = }").ParseRule()
+	assert.IsType(t, &SyntaxError{}, err)
+	_, err = NewRuleParser("IF: { a == 1 } THEN: { tier = 1").ParseRule()
+	assert.IsType(t, &SyntaxError{}, err)
+}
+
+func TestInferMultiStep(t *testing.T) {
+	// Discount is derived from the tier which is derived from the amount. The
+	// discount rule is defined (and evaluated) first so needs a second pass
+	ruleSet, err := NewRuleParser(`
+	RULE "gold_discount": IF: { tier == "GOLD" } THEN: { discount = 20 }
+	RULE "silver_discount": IF: { tier == "SILVER" } THEN: { discount = 10 }
+	RULE "gold_tier": IF: { amount >= 10000 } THEN: { tier = "GOLD" }
+	RULE "silver_tier": IF: { amount >= 1000 && amount < 10000 } THEN: { tier = "SILVER" }
+	`).ParseRuleSet()
+	assert.Nil(t, err)
+	result, err := NewRuleEngine().Infer(ruleSet, []byte(`{ "amount": 20000 }`), 0)
+	assert.Nil(t, err)
+	assert.Equal(t, map[string]interface{}{"tier": "\"GOLD\"", "discount": 20}, result.Facts)
+	assert.Equal(t, []Firing{
+		{Iteration: 1, Rule: "gold_tier", Index: -1, Changes: map[string]interface{}{"tier": "\"GOLD\""}},
+		{Iteration: 2, Rule: "gold_discount", Index: -1, Changes: map[string]interface{}{"discount": 20}},
+	}, result.Firings)
+	assert.Equal(t, 3, result.Iterations)
+}
+
+func TestInferFactOverridesInput(t *testing.T) {
+	ruleSet, err := NewRuleParser(`
+	RULE "vip" PRIORITY 10: IF: { customer.orders >= 100 } THEN: { customer.tier = "VIP" }
+	RULE "vip_discount": IF: { customer.tier == "VIP" } THEN: { discount = 30 }
+	`).ParseRuleSet()
+	assert.Nil(t, err)
+	result, err := NewRuleEngine().Infer(ruleSet, []byte(`{ "customer": { "orders": 120, "tier": "BASIC" } }`), 0)
+	assert.Nil(t, err)
+	assert.Equal(t, 30, result.Facts["discount"])
+	assert.Equal(t, 2, len(result.Firings))
+}
+
+func TestInferUnderscoreField(t *testing.T) {
+	// Fields starting with _ are dependencies like any other field
+	ruleSet, err := NewRuleParser(`
+	RULE "flag": IF: { _meta.score > 50 } THEN: { flagged = true }
+	RULE "score": IF: { amount > 100 } THEN: { _meta.score = 80 }
+	`).ParseRuleSet()
+	assert.Nil(t, err)
+	result, err := NewRuleEngine().Infer(ruleSet, []byte(`{ "amount": 200, "_meta": { "score": 10 } }`), 0)
+	assert.Nil(t, err)
+	assert.Equal(t, true, result.Facts["flagged"])
+	assert.Equal(t, 2, len(result.Firings))
+}
+
+func TestInferVectorRule(t *testing.T) {
+	ruleSet, err := NewRuleParser(`
+	RULE "flag_items": FOR: i=0:items.size() IF: { items[i].qty > 10 } THEN: { items[i].bulk = true }
+	RULE "has_bulk": IF: { items.1.bulk == true } THEN: { review = true }
+	`).ParseRuleSet()
+	assert.Nil(t, err)
+	result, err := NewRuleEngine().Infer(ruleSet, []byte(`{ "items": [ { "qty": 1 }, { "qty": 20 } ] }`), 0)
+	assert.Nil(t, err)
+	assert.Equal(t, map[string]interface{}{"items.1.bulk": true, "review": true}, result.Facts)
+	assert.Equal(t, 1, result.Firings[0].Index)
+}
+
+func TestInferCycle(t *testing.T) {
+	ruleSet, err := NewRuleParser(`
+	RULE "on": IF: { state == "OFF" } THEN: { state = "ON" }
+	RULE "off": IF: { state == "ON" } THEN: { state = "OFF" }
+	`).ParseRuleSet()
+	assert.Nil(t, err)
+	_, err = NewRuleEngine().Infer(ruleSet, []byte(`{ "state": "OFF" }`), 5)
+	assert.IsType(t, &InferenceLimitError{}, err)
+	assert.Equal(t, 5, err.(*InferenceLimitError).MaxIterations)
+	assert.NotEmpty(t, err.(*InferenceLimitError).Firings)
+}
+
+func TestEvaluateIgnoresActions(t *testing.T) {
+	rule, err := NewRuleParser("IF: { a == 1 } THEN: { b = 2 }").ParseRule()
+	assert.Nil(t, err)
+	result, err := NewRuleEngine().Evaluate(rule, []byte(`{ "a": 1 }`))
+	assert.Nil(t, err)
+	assert.Equal(t, []bool{true}, result)
+}
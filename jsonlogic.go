@@ -0,0 +1,400 @@
+// File: jsonlogic.go
+// Converts the rules from and to JSONLogic (https://jsonlogic.com)
+package gorule
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"strings"
+)
+
+// jsonLogicIndexKey is the index of the FOR conditions imported from none/some
+const jsonLogicIndexKey = "i"
+
+// jsonLogicOperators maps the JSONLogic comparisons and logic to the operators
+var jsonLogicOperators = map[string]Operator{
+	"and": AndOperator, "or": OrOperator, "==": EqualOperator, "===": EqualOperator,
+	">": GreaterOperator, ">=": GreaterThanOrEqualOperator, "<": LesserOperator, "<=": LesserThanOrEqualOperator,
+}
+
+// JSONLogicError raised when a condition can not be converted from or to JSONLogic
+type JSONLogicError struct {
+	// Expression is the JSONLogic being imported or the condition being exported
+	Expression string
+	Message    string
+}
+
+func (_rt *JSONLogicError) Error() string {
+	return fmt.Sprintf("JSONLogic error : %s in %s", _rt.Message, _rt.Expression)
+}
+
+// jsonLogicLoop represents the array of the none/some the condition is in.
+// Inside it var resolves the fields of the element
+type jsonLogicLoop struct {
+	arrayPath string
+	indexKey  string
+}
+
+// ExportJSONLogic returns the condition of the rule as JSONLogic (the actions
+// are left out). Chains of && (||) are and (or), a chain of == of the same
+// field with literals is in and a FOR is none of the elements failing its
+// condition (ex. {"none": [{"var": "a"}, {"!": ...}]}), which is true on an
+// empty array like the FOR. A vector rule is some, as it matches if any
+// element does. The FOR must start at 0 and its condition can only read the
+// fields of the element
+func ExportJSONLogic(rule Rule) ([]byte, error) {
+	var logic interface{}
+	var err error
+	switch fgRule := rule.(type) {
+	case *CompiledRule:
+		return ExportJSONLogic(fgRule.GetRule())
+	case *ScalarRule:
+		logic, err = exportJSONLogic(fgRule.If, nil)
+	case *VectorRule:
+		logic, err = exportJSONLogicLoop("some", false, fmt.Sprint(fgRule.IndexKey), fgRule.StartIndex, fgRule.EndIndex, ruleCondition(fgRule.SRule), Format(fgRule))
+	default:
+		err = &JSONLogicError{Message: fmt.Sprintf("unsupported rule %T", rule)}
+	}
+	if err != nil {
+		return nil, err
+	}
+	return json.Marshal(logic)
+}
+
+func exportJSONLogic(condition Condition, loop *jsonLogicLoop) (interface{}, error) {
+	switch cond := condition.(type) {
+	case *VectorCondition:
+		if loop != nil {
+			return nil, &JSONLogicError{Expression: FormatCondition(cond), Message: "FOR in a FOR"}
+		}
+		return exportJSONLogicLoop("none", true, cond.IndexKey, cond.StartIndex, cond.EndIndex, cond.SCondition, FormatCondition(cond))
+	case *LogicalCondition:
+		return exportJSONLogicChain(cond, cond.GetOperator(), loop)
+	case *ScalarCondition:
+		optor := cond.GetOperator()
+		switch optor {
+		case NilOperator:
+			return exportJSONLogicLeaf(cond, loop)
+		case AndOperator, OrOperator:
+			return exportJSONLogicChain(cond, optor, loop)
+		}
+		operand1, err := exportJSONLogic(cond.GetOperand1(), loop)
+		if err != nil {
+			return nil, err
+		}
+		operand2, err := exportJSONLogic(cond.GetOperand2(), loop)
+		if err != nil {
+			return nil, err
+		}
+		return map[string]interface{}{string(optor): []interface{}{operand1, operand2}}, nil
+	}
+	return nil, &JSONLogicError{Expression: FormatCondition(condition), Message: fmt.Sprintf("unsupported condition %T", condition)}
+}
+
+// exportJSONLogicChain returns the and (or) of the clauses of the chain, or in
+// for a chain of == of the same field with literals
+func exportJSONLogicChain(chain Condition, optor Operator, loop *jsonLogicLoop) (interface{}, error) {
+	clauses := flattenClauses(chain, optor)
+	if optor == OrOperator {
+		if field, values, ok := inClauses(clauses); ok {
+			operand, err := exportJSONLogic(field, loop)
+			if err != nil {
+				return nil, err
+			}
+			return map[string]interface{}{"in": []interface{}{operand, values}}, nil
+		}
+	}
+	operands := make([]interface{}, len(clauses))
+	for i, clause := range clauses {
+		operand, err := exportJSONLogic(clause, loop)
+		if err != nil {
+			return nil, err
+		}
+		operands[i] = operand
+	}
+	return map[string]interface{}{map[Operator]string{AndOperator: "and", OrOperator: "or"}[optor]: operands}, nil
+}
+
+// inClauses returns the field and the literals of clauses like a == 1 || a == 2
+func inClauses(clauses []Condition) (Condition, []interface{}, bool) {
+	var field Condition
+	var values []interface{}
+	for _, clause := range clauses {
+		cond, ok := clause.(*ScalarCondition)
+		if !ok || cond.GetOperator() != EqualOperator {
+			return nil, nil, false
+		}
+		operand, literal := cond.GetOperand1(), cond.GetOperand2()
+		if isLiteral(operand) {
+			operand, literal = literal, operand
+		}
+		path, isField := leafPath(operand)
+		if !isField || !isLiteral(literal) || (field != nil && path != field.GetValue()) {
+			return nil, nil, false
+		}
+		field = operand
+		values = append(values, jsonLogicLiteral(literal.GetValue()))
+	}
+	return field, values, len(values) > 1
+}
+
+func exportJSONLogicLeaf(cond *ScalarCondition, loop *jsonLogicLoop) (interface{}, error) {
+	path, isField := leafPath(cond)
+	if !isField {
+		return jsonLogicLiteral(cond.GetValue()), nil
+	}
+	if loop == nil {
+		if cond.HasArrayIndex {
+			return nil, &JSONLogicError{Expression: path, Message: "index out of a FOR"}
+		}
+		return map[string]interface{}{"var": path}, nil
+	}
+	prefix := fmt.Sprintf("%s[%s]", loop.arrayPath, loop.indexKey)
+	if !strings.HasPrefix(path, prefix) {
+		return nil, &JSONLogicError{Expression: path, Message: fmt.Sprintf("only the fields of %s can be read in none/some", prefix)}
+	}
+	return map[string]interface{}{"var": strings.TrimPrefix(strings.TrimPrefix(path, prefix), ".")}, nil
+}
+
+// exportJSONLogicLoop returns the none (some) of a FOR over an array. The
+// condition is negated for none
+func exportJSONLogicLoop(operation string, negate bool, indexKey string, startIndex interface{}, endIndex interface{}, condition Condition, expression string) (interface{}, error) {
+	arrayPath, isSize := strings.CutSuffix(fmt.Sprint(endIndex), ".size()")
+	if fmt.Sprint(startIndex) != "0" || !isSize {
+		return nil, &JSONLogicError{Expression: expression, Message: "FOR not from 0 to the size of an array"}
+	}
+	inner, err := exportJSONLogic(condition, &jsonLogicLoop{arrayPath: arrayPath, indexKey: indexKey})
+	if err != nil {
+		return nil, err
+	}
+	if negate {
+		inner = map[string]interface{}{"!": inner}
+	}
+	return map[string]interface{}{operation: []interface{}{map[string]interface{}{"var": arrayPath}, inner}}, nil
+}
+
+// jsonLogicLiteral returns the JSON value of a literal. Floats keep their
+// decimal point so they are imported back as floats
+func jsonLogicLiteral(value interface{}) interface{} {
+	if number, ok := value.(float64); ok {
+		return json.Number(formatValue(number))
+	}
+	return literalValue(value)
+}
+
+// ImportJSONLogic returns the rule of a JSONLogic condition. Comparisons, and,
+// or, in with a list of literals, var with a path (without default value) and
+// none with a negated condition (a FOR) are supported. some is supported only
+// as the whole condition, imported as a vector rule. The operand of none (some)
+// can only read the element. all is rejected as it is false on an empty array
+// while a FOR is true. Strings can not hold quotes nor backslashes
+func ImportJSONLogic(data []byte) (Rule, error) {
+	decoder := json.NewDecoder(bytes.NewReader(data))
+	decoder.UseNumber()
+	var logic interface{}
+	if err := decoder.Decode(&logic); err != nil {
+		return nil, &JSONLogicError{Expression: string(data), Message: err.Error()}
+	}
+	if operation, args, ok := jsonLogicOperation(logic); ok && operation == "some" {
+		arrayPath, condition, err := importJSONLogicLoop(logic, args)
+		if err != nil {
+			return nil, err
+		}
+		scalarRule := &ScalarRule{Type: ScalarRuleType, If: condition}
+		return &VectorRule{Type: VectorRuleType, SRule: scalarRule, StartIndex: "0", EndIndex: arrayPath + ".size()", IndexKey: jsonLogicIndexKey}, nil
+	}
+	condition, err := importJSONLogic(logic, nil)
+	if err != nil {
+		return nil, err
+	}
+	return &ScalarRule{Type: ScalarRuleType, If: condition}, nil
+}
+
+// jsonLogicOperation returns the operation of an object like {"op": args}. A
+// single argument is the same as a list of one argument
+func jsonLogicOperation(logic interface{}) (string, []interface{}, bool) {
+	object, ok := logic.(map[string]interface{})
+	if !ok || len(object) != 1 {
+		return "", nil, false
+	}
+	for operation, value := range object {
+		if args, ok := value.([]interface{}); ok {
+			return operation, args, true
+		}
+		return operation, []interface{}{value}, true
+	}
+	return "", nil, false
+}
+
+func importJSONLogic(logic interface{}, loop *jsonLogicLoop) (Condition, error) {
+	switch value := logic.(type) {
+	case bool:
+		return newLiteral(value), nil
+	case json.Number:
+		return newLiteral(StringToInterface(value.String())), nil
+	case string:
+		// The rules have no escape sequences in their strings
+		if strings.ContainsAny(value, "\"\\") {
+			return nil, jsonLogicError(logic, "string with a quote or a backslash")
+		}
+		return newLiteral(fmt.Sprintf("\"%s\"", value)), nil
+	case map[string]interface{}:
+		return importJSONLogicOperation(logic, loop)
+	}
+	return nil, jsonLogicError(logic, "unsupported value")
+}
+
+func importJSONLogicOperation(logic interface{}, loop *jsonLogicLoop) (Condition, error) {
+	operation, args, ok := jsonLogicOperation(logic)
+	if !ok {
+		return nil, jsonLogicError(logic, "expecting an object with a single operation")
+	}
+	switch operation {
+	case "var":
+		return importJSONLogicVar(logic, args, loop)
+	case "and", "or":
+		if len(args) == 0 {
+			return nil, jsonLogicError(logic, operation+" without operands")
+		}
+		return importJSONLogicChain(jsonLogicOperators[operation], args, loop)
+	case "==", "===", ">", ">=", "<", "<=":
+		between := len(args) == 3 && (operation == "<" || operation == "<=")
+		if len(args) != 2 && !between {
+			return nil, jsonLogicError(logic, operation+" needs two operands")
+		}
+		operands := make([]Condition, len(args))
+		for i, arg := range args {
+			operand, err := importJSONLogic(arg, loop)
+			if err != nil {
+				return nil, err
+			}
+			operands[i] = operand
+		}
+		optor := jsonLogicOperators[operation]
+		comparison := newComparison(optor, operands[0], operands[1])
+		if between {
+			// 1 < a < 10 is 1 < a && a < 10
+			return newComparison(AndOperator, comparison, newComparison(optor, operands[1], operands[2])), nil
+		}
+		return comparison, nil
+	case "in":
+		values, ok := args[len(args)-1].([]interface{})
+		if len(args) != 2 || !ok {
+			return nil, jsonLogicError(logic, "in needs an operand and a list of literals")
+		}
+		operand, err := importJSONLogic(args[0], loop)
+		if err != nil {
+			return nil, err
+		}
+		if len(values) == 0 {
+			return newLiteral(false), nil
+		}
+		clauses := make([]interface{}, len(values))
+		for i := range values {
+			if _, isOperation := values[i].(map[string]interface{}); isOperation {
+				return nil, jsonLogicError(logic, "in needs a list of literals")
+			}
+			clauses[i] = values[i]
+		}
+		return importJSONLogicIn(operand, clauses, loop)
+	case "none":
+		if loop != nil {
+			return nil, jsonLogicError(logic, "none in none/some")
+		}
+		// none of the elements failing the condition is a FOR over the condition
+		negation, negated, ok := jsonLogicOperation(args[len(args)-1])
+		if len(args) != 2 || !ok || negation != "!" || len(negated) != 1 {
+			return nil, jsonLogicError(logic, "none needs an array and a negated condition")
+		}
+		arrayPath, condition, err := importJSONLogicLoop(logic, []interface{}{args[0], negated[0]})
+		if err != nil {
+			return nil, err
+		}
+		return &VectorCondition{Type: VectorConditionType, Operator: AndOperator, SCondition: condition,
+			StartIndex: "0", EndIndex: arrayPath + ".size()", IndexKey: jsonLogicIndexKey}, nil
+	case "some":
+		return nil, jsonLogicError(logic, "some is only supported as the whole condition")
+	case "all":
+		return nil, jsonLogicError(logic, "all is false on an empty array, use none with a negated condition")
+	}
+	return nil, jsonLogicError(logic, fmt.Sprintf("unsupported operation %s", operation))
+}
+
+// importJSONLogicChain joins the operands from the right as the parser does
+// (ex. a && ( b && c ))
+func importJSONLogicChain(optor Operator, args []interface{}, loop *jsonLogicLoop) (Condition, error) {
+	operand, err := importJSONLogic(args[0], loop)
+	if err != nil || len(args) == 1 {
+		return operand, err
+	}
+	rest, err := importJSONLogicChain(optor, args[1:], loop)
+	if err != nil {
+		return nil, err
+	}
+	return newComparison(optor, operand, rest), nil
+}
+
+// importJSONLogicIn returns the == of the operand with every literal joined by ||
+func importJSONLogicIn(operand Condition, values []interface{}, loop *jsonLogicLoop) (Condition, error) {
+	literal, err := importJSONLogic(values[0], loop)
+	if err != nil || len(values) == 1 {
+		return newComparison(EqualOperator, operand, literal), err
+	}
+	rest, err := importJSONLogicIn(operand, values[1:], loop)
+	if err != nil {
+		return nil, err
+	}
+	return newComparison(OrOperator, newComparison(EqualOperator, operand, literal), rest), nil
+}
+
+func importJSONLogicVar(logic interface{}, args []interface{}, loop *jsonLogicLoop) (Condition, error) {
+	if len(args) != 1 {
+		return nil, jsonLogicError(logic, "var with a default value")
+	}
+	var path string
+	switch arg := args[0].(type) {
+	case string:
+		path = arg
+	case json.Number:
+		path = arg.String()
+	default:
+		return nil, jsonLogicError(logic, "var needs a path")
+	}
+	if loop != nil {
+		path = strings.TrimSuffix(fmt.Sprintf("%s[%s].%s", loop.arrayPath, loop.indexKey, path), ".")
+	}
+	if _, isPath := StringToInterface(path).(string); !isPath || path == "" || strings.ContainsAny(path, " \"") || (loop == nil && strings.ContainsAny(path, "[]")) {
+		return nil, jsonLogicError(logic, fmt.Sprintf("unsupported path %q", path))
+	}
+	return &ScalarCondition{Type: ScalarConditionType, Operator: NilOperator, Value: path, HasArrayIndex: hasArrayIndex(path)}, nil
+}
+
+// importJSONLogicLoop returns the path of the array and the condition of a none (some)
+func importJSONLogicLoop(logic interface{}, args []interface{}) (string, Condition, error) {
+	if len(args) != 2 {
+		return "", nil, jsonLogicError(logic, "none/some needs an array and a condition")
+	}
+	array, err := importJSONLogic(args[0], nil)
+	if err != nil {
+		return "", nil, err
+	}
+	arrayPath, isField := leafPath(array)
+	if !isField || isGJSONPath(arrayPath) {
+		return "", nil, jsonLogicError(logic, "none/some needs the var of an array")
+	}
+	condition, err := importJSONLogic(args[1], &jsonLogicLoop{arrayPath: arrayPath, indexKey: jsonLogicIndexKey})
+	if err != nil {
+		return "", nil, err
+	}
+	return arrayPath, condition, nil
+}
+
+func newComparison(optor Operator, operand1 Condition, operand2 Condition) *ScalarCondition {
+	return &ScalarCondition{Type: ScalarConditionType, Operator: optor, Operand1: operand1, Operand2: operand2}
+}
+
+func jsonLogicError(logic interface{}, message string) error {
+	expression, _ := json.Marshal(logic)
+	return &JSONLogicError{Expression: string(expression), Message: message}
+}
@@ -0,0 +1,134 @@
+// File: jsonlogic_test.go
+// Tests for the JSONLogic import and export of the rules
+package gorule
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestExportJSONLogic(t *testing.T) {
+	tests := []struct {
+		src   string
+		logic string
+	}{
+		{`IF: { amount >= 10000 && country == "IN" }`, `{"and":[{">=":[{"var":"amount"},10000]},{"==":[{"var":"country"},"IN"]}]}`},
+		{`IF: { a > 1 && b < 2.5 && c == true } THEN: { flag = true }`, `{"and":[{">":[{"var":"a"},1]},{"<":[{"var":"b"},2.5]},{"==":[{"var":"c"},true]}]}`},
+		{`IF: { ( a > 1 || b > 1 ) && c <= 1.0 }`, `{"and":[{"or":[{">":[{"var":"a"},1]},{">":[{"var":"b"},1]}]},{"<=":[{"var":"c"},1.0]}]}`},
+		{`IF: { type == "CC" || type == "DC" || "UPI" == type }`, `{"in":[{"var":"type"},["CC","DC","UPI"]]}`},
+		{`IF: { card.type == "CC" || amount == 1 }`, `{"or":[{"==":[{"var":"card.type"},"CC"]},{"==":[{"var":"amount"},1]}]}`},
+		{`IF: { FOR: i=0:items.size() { items[i].price > 10 && items[i].sku.id == "x" } }`, `{"none":[{"var":"items"},{"!":{"and":[{">":[{"var":"price"},10]},{"==":[{"var":"sku.id"},"x"]}]}}]}`},
+		{`IF: { FOR: j=0:tags.size() { tags[j] == "a" || tags[j] == "b" } }`, `{"none":[{"var":"tags"},{"!":{"in":[{"var":""},["a","b"]]}}]}`},
+		{`FOR: i=0:items.size() IF: { items[i].qty > 5 }`, `{"some":[{"var":"items"},{">":[{"var":"qty"},5]}]}`},
+	}
+	for _, test := range tests {
+		rule, err := NewRuleParser(test.src).ParseRule()
+		assert.Nil(t, err, test.src)
+		logic, err := ExportJSONLogic(rule)
+		assert.Nil(t, err, test.src)
+		assert.JSONEq(t, test.logic, string(logic), test.src)
+	}
+
+	for _, src := range []string{
+		`IF: { FOR: i=1:items.size() { items[i].price > 10 } }`,
+		`IF: { FOR: i=0:items.size() { items[i].price > min } }`,
+		`FOR: i=0:items.size() IF: { items[i].price > min }`,
+	} {
+		rule, err := NewRuleParser(src).ParseRule()
+		assert.Nil(t, err, src)
+		_, err = ExportJSONLogic(rule)
+		assert.IsType(t, &JSONLogicError{}, err, src)
+	}
+}
+
+func TestImportJSONLogic(t *testing.T) {
+	tests := []struct {
+		logic string
+		src   string
+	}{
+		{`{"and":[{">=":[{"var":"amount"},10000]},{"===":[{"var":"country"},"IN"]},{"<":[{"var":"risk"},0.5]}]}`,
+			`IF: { amount >= 10000 && country == "IN" && risk < 0.5 }`},
+		{`{"or":[{">":[{"var":"a.b"},1]},{"and":[{"==":[{"var":"c"},true]},{"<=":[{"var":"d"},{"var":"e"}]}]}]}`,
+			`IF: { a.b > 1 || ( c == true && d <= e ) }`},
+		{`{"in":[{"var":"type"},["CC","DC"]]}`, `IF: { type == "CC" || type == "DC" }`},
+		{`{"<":[1,{"var":"age"},10]}`, `IF: { 1 < age && age < 10 }`},
+		{`{"and":[{"==":[{"var":"a"},1.0]}]}`, `IF: { a == 1.0 }`},
+		{`{"none":[{"var":"items"},{"!":{">":[{"var":"price"},10]}}]}`, `IF: { FOR: i=0:items.size() { items[i].price > 10 } }`},
+		{`{"none":[{"var":"tags"},{"!":[{"in":[{"var":""},["a"]]}]}]}`, `IF: { FOR: i=0:tags.size() { tags[i] == "a" } }`},
+		{`{"==":[{"var":"name"},"a b"]}`, `IF: { name == "a b" }`},
+		{`{"some":[{"var":"items"},{">":[{"var":"qty"},5]}]}`, `FOR: i=0:items.size() IF: { items[i].qty > 5 }`},
+	}
+	for _, test := range tests {
+		rule, err := ImportJSONLogic([]byte(test.logic))
+		assert.Nil(t, err, test.logic)
+		assert.Equal(t, test.src, Format(rule), test.logic)
+	}
+
+	for _, logic := range []string{
+		`{"and":[`,
+		`{"!":[{"var":"a"}]}`,
+		`{"!=":[{"var":"a"},1]}`,
+		`{"+":[{"var":"a"},1]}`,
+		`{"var":["a",1]}`,
+		`{"==":[{"var":"a"}]}`,
+		`{"==":[{"var":"a"},null]}`,
+		`{"in":[{"var":"a"},"abc"]}`,
+		`{"and":[]}`,
+		`{"and":[{"some":[{"var":"items"},{"var":"ok"}]},true]}`,
+		`{"none":[{"var":"items"},{"!":{"none":[{"var":"tags"},{"!":{"==":[{"var":""},1]}}]}}]}`,
+		`{"none":[{"var":"items"},{"!":{"==":[{"var":""},1]}}],"some":[]}`,
+		`{"none":[{"var":"items"},{"==":[{"var":""},1]}]}`,
+		`{"none":[{"var":"items"},{"!":[{"var":"ok"},true]}]}`,
+		`{"all":[{"var":"items"},{">":[{"var":"price"},10]}]}`,
+		`{"==":[{"var":"name"},"a\"b"]}`,
+		`{"==":[{"var":"name"},"a\\"]}`,
+		`{"==":[{"var":"a b"},1]}`,
+		`{"==":[{"var":"1"},1]}`,
+	} {
+		_, err := ImportJSONLogic([]byte(logic))
+		assert.IsType(t, &JSONLogicError{}, err, logic)
+	}
+}
+
+func TestJSONLogicRoundTrip(t *testing.T) {
+	for _, src := range []string{
+		`IF: { amount >= 10000 && ( country == "IN" || country == "US" ) && vip == true }`,
+		`IF: { a > 1.5 || b <= c }`,
+		`IF: { FOR: i=0:items.size() { items[i].price > 10 || items[i].tags == "sale" } }`,
+		`FOR: i=0:items.size() IF: { items[i].qty > 5 && items[i].sku == "x" }`,
+	} {
+		rule, err := NewRuleParser(src).ParseRule()
+		assert.Nil(t, err, src)
+		logic, err := ExportJSONLogic(rule)
+		assert.Nil(t, err, src)
+		imported, err := ImportJSONLogic(logic)
+		assert.Nil(t, err, src)
+		assert.Equal(t, src, Format(imported), string(logic))
+		// The imported rule evaluates as the original one
+		engine := NewRuleEngine()
+		for _, payload := range []string{
+			`{"amount": 20000, "country": "US", "vip": true, "a": 2.0, "b": 1, "c": 0, "items": [{"price": 20, "tags": "x", "qty": 6, "sku": "x"}]}`,
+			`{"amount": 20000, "country": "FR", "vip": true, "a": 1.0, "b": 1, "c": 2, "items": [{"price": 5, "tags": "sale", "qty": 1, "sku": "x"}]}`,
+			`{"amount": 1, "country": "IN", "vip": false, "a": 1.0, "b": 1, "c": 2, "items": []}`,
+		} {
+			expected, err := engine.Evaluate(rule, []byte(payload))
+			assert.Nil(t, err)
+			result, err := engine.Evaluate(imported, []byte(payload))
+			assert.Nil(t, err)
+			assert.Equal(t, expected, result, payload)
+		}
+	}
+
+	for _, logic := range []string{
+		`{"and":[{">=":[{"var":"amount"},10000]},{"in":[{"var":"country"},["IN","US"]]}]}`,
+		`{"none":[{"var":"items"},{"!":{"or":[{"<":[{"var":"price"},1.0]},{"==":[{"var":"sku.id"},"x"]}]}}]}`,
+		`{"some":[{"var":"items"},{"==":[{"var":""},3]}]}`,
+	} {
+		rule, err := ImportJSONLogic([]byte(logic))
+		assert.Nil(t, err, logic)
+		exported, err := ExportJSONLogic(rule)
+		assert.Nil(t, err, logic)
+		assert.JSONEq(t, logic, string(exported))
+	}
+}
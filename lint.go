@@ -0,0 +1,199 @@
+// File: lint.go
+// Implements the lint of the rule conditions (contradictions, tautologies, redundancy)
+package gorule
+
+import (
+	"fmt"
+	"sort"
+)
+
+// LintCheck represents the kind of problem reported by the linter
+type LintCheck string
+
+const (
+	// ContradictionCheck reports a conjunction which is never true (ex. a > 100 && a < 50)
+	ContradictionCheck LintCheck = "contradiction"
+	// TautologyCheck reports a disjunction which is always true (ex. a > 1 || a <= 1)
+	TautologyCheck LintCheck = "tautology"
+	// DuplicateCheck reports a clause repeated in a conjunction or a disjunction
+	DuplicateCheck LintCheck = "duplicate"
+	// SelfComparisonCheck reports a field compared with itself (ex. a == a)
+	SelfComparisonCheck LintCheck = "self-comparison"
+	// ConstantCheck reports a sub expression made only of literals (ex. 1 > 2)
+	ConstantCheck LintCheck = "constant"
+)
+
+// LintWarning represents a problem found by the linter. The rule evaluates
+// fine but does not do what the author meant
+type LintWarning struct {
+	Rule       string    `json:"rule,omitempty"`
+	Check      LintCheck `json:"check"`
+	Expression string    `json:"expression"`
+	Reason     string    `json:"reason"`
+	// Offset is the offset in the source (-1 if unknown, ex. no source map)
+	Offset int `json:"offset"`
+}
+
+func (_rt *LintWarning) Error() string {
+	return fmt.Sprintf("Lint %s : %s in %s", _rt.Check, _rt.Reason, _rt.Expression)
+}
+
+// linter collects the warnings of the rules of a rule set
+type linter struct {
+	sourceMap SourceMap
+	rule      string
+	warnings  []*LintWarning
+}
+
+// Lint checks the conditions of the rule. Pass the source map of the parser
+// (RuleParser.SourceMap) to get the offsets of the warnings
+func Lint(rule Rule, sourceMap SourceMap) []*LintWarning {
+	ruleSet := NewRuleSet()
+	ruleSet.Add(rule.GetMetadata().GetName(), rule)
+	return LintRuleSet(ruleSet, sourceMap)
+}
+
+// LintRuleSet checks the conditions of all the rules of the rule set
+func LintRuleSet(ruleSet *RuleSet, sourceMap SourceMap) []*LintWarning {
+	lint := &linter{sourceMap: sourceMap}
+	for _, name := range ruleSet.names {
+		lint.rule = name
+		rule := ruleSet.rules[name]
+		if compiledRule, ok := rule.(*CompiledRule); ok {
+			rule = compiledRule.GetRule()
+		}
+		if condition := ruleCondition(rule); condition != nil {
+			lint.lintCondition(condition, NilOperator)
+		}
+	}
+	sort.SliceStable(lint.warnings, func(i, j int) bool {
+		return lint.warnings[i].Offset < lint.warnings[j].Offset
+	})
+	return lint.warnings
+}
+
+func (_l *linter) report(node Condition, check LintCheck, reason string, args ...interface{}) {
+	offset, ok := _l.sourceMap[node]
+	if !ok {
+		offset = -1
+	}
+	_l.warnings = append(_l.warnings, &LintWarning{Rule: _l.rule, Check: check, Expression: FormatCondition(node),
+		Reason: fmt.Sprintf(reason, args...), Offset: offset})
+}
+
+// lintCondition checks the condition. parent is the operator of the parent
+// node, a chain of && (or ||) is checked once from its top
+func (_l *linter) lintCondition(condition Condition, parent Operator) {
+	switch cond := condition.(type) {
+	case *VectorCondition:
+		_l.lintCondition(cond.SCondition, NilOperator)
+	case *ScalarCondition:
+		optor := cond.GetOperator()
+		if optor == NilOperator {
+			return
+		}
+		if isConstant(cond) {
+			_l.report(cond, ConstantCheck, "only literals, always %v", evaluateConstant(cond))
+			return
+		}
+		if optor != AndOperator && optor != OrOperator {
+			path1, ok1 := leafPath(cond.GetOperand1())
+			path2, ok2 := leafPath(cond.GetOperand2())
+			if ok1 && ok2 && path1 == path2 {
+				always := optor == EqualOperator || optor == GreaterThanOrEqualOperator || optor == LesserThanOrEqualOperator
+				_l.report(cond, SelfComparisonCheck, "%s is compared with itself, always %t", path1, always)
+			}
+			return
+		}
+		clauses := flattenClauses(cond, optor)
+		if optor != parent {
+			_l.lintClauses(cond, optor, clauses)
+		}
+		_l.lintCondition(cond.GetOperand1(), optor)
+		_l.lintCondition(cond.GetOperand2(), optor)
+	case *LogicalCondition:
+		optor := cond.GetOperator()
+		if optor != parent {
+			_l.lintClauses(cond, optor, flattenClauses(cond, optor))
+		}
+		for _, operand := range cond.GetOperands() {
+			_l.lintCondition(operand, optor)
+		}
+	}
+}
+
+// lintClauses checks the clauses of a chain of && (or ||) for duplicates and
+// contradictions (or tautologies)
+func (_l *linter) lintClauses(chain Condition, optor Operator, clauses []Condition) {
+	seen := make(map[string]Condition)
+	for _, clause := range clauses {
+		key := conditionKey(clause)
+		if first, ok := seen[key]; ok {
+			_l.report(clause, DuplicateCheck, "duplicate of %s", FormatCondition(first))
+			continue
+		}
+		seen[key] = clause
+	}
+	// A disjunction is always true if the conjunction of the negated clauses is
+	// never true
+	negate := optor == OrOperator
+	constraints := make(map[string]*fieldConstraint)
+	var fields []string
+	for _, clause := range clauses {
+		if literal, ok := clause.(*ScalarCondition); ok && literal.GetOperator() == NilOperator {
+			if value, ok := literal.GetValue().(bool); ok && value == negate {
+				// false && ... (or true || ...)
+				_l.reportChain(chain, optor, "")
+				return
+			}
+		}
+		path, atomOptor, value, ok := comparisonAtom(clause, true)
+		if !ok {
+			continue
+		}
+		if negate {
+			atomOptor = negatedOperators[atomOptor]
+		}
+		constraint, ok := constraints[path]
+		if !ok {
+			constraint = &fieldConstraint{}
+			constraints[path] = constraint
+			fields = append(fields, path)
+		}
+		constraint.add(atomOptor, value)
+	}
+	for _, path := range fields {
+		if !constraints[path].satisfiable() {
+			_l.reportChain(chain, optor, path)
+			return
+		}
+	}
+}
+
+func (_l *linter) reportChain(chain Condition, optor Operator, path string) {
+	subject := "the clauses"
+	if path != "" {
+		subject = "the conditions on " + path
+	}
+	if optor == AndOperator {
+		_l.report(chain, ContradictionCheck, "%s are never true together", subject)
+	} else {
+		_l.report(chain, TautologyCheck, "one of %s is always true", subject)
+	}
+}
+
+// flattenClauses returns the operands of a chain of the operator (ex. the
+// clauses of a && b && c)
+func flattenClauses(condition Condition, optor Operator) []Condition {
+	if cond, ok := condition.(*ScalarCondition); ok && cond.GetOperator() == optor {
+		return append(flattenClauses(cond.GetOperand1(), optor), flattenClauses(cond.GetOperand2(), optor)...)
+	}
+	if cond, ok := condition.(*LogicalCondition); ok && cond.GetOperator() == optor {
+		var clauses []Condition
+		for _, operand := range cond.GetOperands() {
+			clauses = append(clauses, flattenClauses(operand, optor)...)
+		}
+		return clauses
+	}
+	return []Condition{condition}
+}
@@ -0,0 +1,87 @@
+// File: lint_test.go
+package gorule
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func lintRule(t *testing.T, src string) ([]*LintWarning, string) {
+	parser := NewRuleParser(src)
+	ruleSet, err := parser.ParseRules("rule")
+	assert.Nil(t, err)
+	return LintRuleSet(ruleSet, parser.SourceMap()), src
+}
+
+func TestLintClean(t *testing.T) {
+	warnings, _ := lintRule(t, `RULE "a":
+	IF: { amount > 100 && amount < 500 && type == "CC" }
+RULE "b":
+	IF: { amount >= 100 && amount <= 100 || type == "CC" || type == "DC" }
+RULE "c":
+	FOR: i=0:items.size() IF: { items[i].price > 10 && items[i].qty < 5 }`)
+	assert.Empty(t, warnings)
+}
+
+func TestLintContradiction(t *testing.T) {
+	for _, src := range []string{
+		`IF: { amount > 100 && amount < 50 }`,
+		`IF: { type == "CC" && ( x > 1 || y > 1 ) && type == "DC" }`,
+		`IF: { amount > 100 && amount <= 100 }`,
+		`IF: { 100 > amount && amount >= 100 }`,
+		`IF: { flag == true && flag == false }`,
+		`IF: { amount == 10 && amount > 10.5 }`,
+	} {
+		warnings, _ := lintRule(t, src)
+		if assert.Equal(t, 1, len(warnings), src) {
+			assert.Equal(t, ContradictionCheck, warnings[0].Check, src)
+			assert.Equal(t, 6, warnings[0].Offset, src)
+		}
+	}
+	warnings, _ := lintRule(t, `IF: { x > 1 && amount > 100 && amount < 50 }`)
+	assert.Equal(t, "Lint contradiction : the conditions on amount are never true together in x > 1 && amount > 100 && amount < 50", warnings[0].Error())
+}
+
+func TestLintTautology(t *testing.T) {
+	for _, src := range []string{
+		`IF: { amount > 100 || amount <= 100 }`,
+		`IF: { amount < 200 || amount > 100 }`,
+		`IF: { flag == true || flag == false }`,
+		`IF: { x > 1 || true }`,
+	} {
+		warnings, _ := lintRule(t, src)
+		if assert.Equal(t, 1, len(warnings), src) {
+			assert.Equal(t, TautologyCheck, warnings[0].Check, src)
+		}
+	}
+	warnings, _ := lintRule(t, `IF: { amount > 100 || amount <= 100 }`)
+	assert.Equal(t, "one of the conditions on amount is always true", warnings[0].Reason)
+	warnings, _ = lintRule(t, `IF: { amount < 100 || amount > 100 }`)
+	assert.Empty(t, warnings)
+}
+
+func TestLintRedundancy(t *testing.T) {
+	warnings, src := lintRule(t, `RULE "a":
+	IF: { type == "CC" && amount > 1 && type == "CC" }
+RULE "b":
+	IF: { amount == amount || limit < limit }
+RULE "c":
+	IF: { x > 1 && ( 1 > 2 || "A" == "A" ) }`)
+	assert.Equal(t, 4, len(warnings))
+	assert.Equal(t, DuplicateCheck, warnings[0].Check)
+	assert.Equal(t, "duplicate of type == \"CC\"", warnings[0].Reason)
+	assert.Equal(t, "type", src[warnings[0].Offset:warnings[0].Offset+4])
+	assert.Equal(t, "a", warnings[0].Rule)
+	assert.Equal(t, SelfComparisonCheck, warnings[1].Check)
+	assert.Equal(t, "amount is compared with itself, always true", warnings[1].Reason)
+	assert.Equal(t, "limit is compared with itself, always false", warnings[2].Reason)
+	assert.Equal(t, ConstantCheck, warnings[3].Check)
+	assert.Equal(t, "1 > 2 || \"A\" == \"A\"", warnings[3].Expression)
+	assert.Equal(t, "only literals, always true", warnings[3].Reason)
+
+	rule, err := NewRuleParser(`IF: { x == x }`).ParseRule()
+	assert.Nil(t, err)
+	warnings = Lint(rule, nil)
+	assert.Equal(t, -1, warnings[0].Offset)
+}
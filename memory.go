@@ -0,0 +1,60 @@
+// File: memory.go
+// Represents the working memory holding the facts asserted by actions
+package gorule
+
+import "reflect"
+
+// MissingWorkingMemoryError raised when actions are executed without a working memory
+type MissingWorkingMemoryError struct {
+}
+
+func (_rt *MissingWorkingMemoryError) Error() string {
+	return "Working memory not found in context"
+}
+
+// WorkingMemory represents the facts asserted during inference. Facts take
+// precedence over the fields of the input data
+type WorkingMemory struct {
+	facts map[string]interface{}
+	order []string
+}
+
+// NewWorkingMemory returns a fresh empty working memory
+func NewWorkingMemory() *WorkingMemory {
+	return &WorkingMemory{facts: make(map[string]interface{})}
+}
+
+// Set asserts (or modifies) a fact and returns true if the fact was changed
+func (_wm *WorkingMemory) Set(path string, value interface{}) bool {
+	oldValue, ok := _wm.facts[path]
+	if ok && reflect.DeepEqual(oldValue, value) {
+		return false
+	}
+	if !ok {
+		_wm.order = append(_wm.order, path)
+	}
+	_wm.facts[path] = value
+	return true
+}
+
+// Get returns the fact at path
+func (_wm *WorkingMemory) Get(path string) (interface{}, bool) {
+	value, ok := _wm.facts[path]
+	return value, ok
+}
+
+// Facts returns a copy of all the facts
+func (_wm *WorkingMemory) Facts() map[string]interface{} {
+	facts := make(map[string]interface{}, len(_wm.facts))
+	for path, value := range _wm.facts {
+		facts[path] = value
+	}
+	return facts
+}
+
+// Paths returns the paths of the facts in the order they were first asserted
+func (_wm *WorkingMemory) Paths() []string {
+	paths := make([]string, len(_wm.order))
+	copy(paths, _wm.order)
+	return paths
+}
@@ -0,0 +1,179 @@
+// File: network.go
+// Implements a discrimination network sharing identical conditions across rules
+package gorule
+
+import (
+	"fmt"
+	"strings"
+)
+
+const (
+	// predicateNodeType represents a node evaluated by the interpreter (ex. a == b)
+	predicateNodeType = 1
+	// joinNodeType represents a node joining other nodes with && / ||
+	joinNodeType = 2
+)
+
+// networkNode represents one distinct condition of the network
+type networkNode struct {
+	nodeType  int
+	condition Condition
+	operator  Operator
+	children  []int
+}
+
+// MatchNetwork represents a compiled rule set in which identical conditions
+// (ex. type == "CREDIT_CARD") are shared by all the rules using them. Every
+// distinct condition is evaluated at most once per input
+type MatchNetwork struct {
+	nodes       []*networkNode
+	nodeIndex   map[string]int
+	names       []string
+	ruleNodes   map[string]int
+	vectorRules map[string]*VectorRule
+}
+
+// NewMatchNetwork compiles the rule set into a match network
+func NewMatchNetwork(ruleSet *RuleSet) *MatchNetwork {
+	network := &MatchNetwork{
+		nodeIndex:   make(map[string]int),
+		ruleNodes:   make(map[string]int),
+		vectorRules: make(map[string]*VectorRule),
+	}
+	for _, name := range ruleSet.names {
+		network.names = append(network.names, name)
+		switch fgRule := ruleSet.rules[name].(type) {
+		case *ScalarRule:
+			network.ruleNodes[name] = network.addCondition(fgRule.If)
+		case *VectorRule:
+			// Conditions of a vector rule depend on the index of the rule
+			network.vectorRules[name] = fgRule
+		}
+	}
+	return network
+}
+
+// addCondition adds the condition (and its sub conditions) and returns the node
+func (_n *MatchNetwork) addCondition(condition Condition) int {
+	key := conditionKey(condition)
+	if index, ok := _n.nodeIndex[key]; ok {
+		return index
+	}
+	node := &networkNode{nodeType: predicateNodeType, condition: condition}
+	if scalarCondition, ok := condition.(*ScalarCondition); ok {
+		if optor := scalarCondition.GetOperator(); optor == AndOperator || optor == OrOperator {
+			node = &networkNode{nodeType: joinNodeType, operator: optor}
+			node.children = append(node.children, _n.addCondition(scalarCondition.GetOperand1()))
+			node.children = append(node.children, _n.addCondition(scalarCondition.GetOperand2()))
+		}
+	}
+	if logicalCondition, ok := condition.(*LogicalCondition); ok {
+		node = &networkNode{nodeType: joinNodeType, operator: logicalCondition.GetOperator()}
+		for _, operand := range logicalCondition.GetOperands() {
+			node.children = append(node.children, _n.addCondition(operand))
+		}
+	}
+	_n.nodes = append(_n.nodes, node)
+	_n.nodeIndex[key] = len(_n.nodes) - 1
+	return len(_n.nodes) - 1
+}
+
+// NodeCount returns the number of distinct conditions of the network
+func (_n *MatchNetwork) NodeCount() int {
+	return len(_n.nodes)
+}
+
+// PredicateCount returns the number of distinct predicates of the network
+func (_n *MatchNetwork) PredicateCount() int {
+	count := 0
+	for _, node := range _n.nodes {
+		if node.nodeType == predicateNodeType {
+			count++
+		}
+	}
+	return count
+}
+
+// Match returns the names of the rules matching the jsonData in the order they
+// were added to the rule set
+func (_n *MatchNetwork) Match(jsonData []byte) ([]string, error) {
+	return _n.MatchDocument(ParseDocument(jsonData))
+}
+
+// MatchDocument returns the names of the rules matching an already parsed document
+func (_n *MatchNetwork) MatchDocument(doc *Document) ([]string, error) {
+	ctx := NewContext()
+	ctx.SetValue(InputDataKey, doc)
+	// Result of every node for this input (nil = not evaluated yet)
+	results := make([]*bool, len(_n.nodes))
+	matches := []string{}
+	for _, name := range _n.names {
+		var matched bool
+		var err error
+		if vectorRule, ok := _n.vectorRules[name]; ok {
+			var result interface{}
+			result, err = vectorRule.Evaluate(ctx)
+			matched = isMatch(result)
+		} else {
+			matched, err = _n.evaluateNode(_n.ruleNodes[name], ctx, results)
+		}
+		if err != nil {
+			return nil, &RuleEvaluationError{Name: name, Err: err}
+		}
+		if matched {
+			matches = append(matches, name)
+		}
+	}
+	return matches, nil
+}
+
+func (_n *MatchNetwork) evaluateNode(index int, ctx Context, results []*bool) (bool, error) {
+	if results[index] != nil {
+		return *results[index], nil
+	}
+	node := _n.nodes[index]
+	var result bool
+	if node.nodeType == joinNodeType {
+		// Short circuit same as the interpreter
+		result = node.operator == AndOperator
+		for _, child := range node.children {
+			childResult, err := _n.evaluateNode(child, ctx, results)
+			if err != nil {
+				return false, err
+			}
+			if childResult != result {
+				result = childResult
+				break
+			}
+		}
+	} else {
+		value, err := node.condition.Evaluate(ctx)
+		if err != nil {
+			return false, err
+		}
+		result, _ = value.(bool)
+	}
+	results[index] = &result
+	return result, nil
+}
+
+// conditionKey returns a canonical text of the condition used to find
+// identical conditions
+func conditionKey(condition Condition) string {
+	switch cond := condition.(type) {
+	case *ScalarCondition:
+		if cond.GetOperator() == NilOperator {
+			return fmt.Sprintf("%T:%v", cond.GetValue(), cond.GetValue())
+		}
+		return fmt.Sprintf("(%s %s %s)", conditionKey(cond.GetOperand1()), cond.GetOperator(), conditionKey(cond.GetOperand2()))
+	case *LogicalCondition:
+		keys := make([]string, len(cond.GetOperands()))
+		for i, operand := range cond.GetOperands() {
+			keys[i] = conditionKey(operand)
+		}
+		return fmt.Sprintf("(%s)", strings.Join(keys, fmt.Sprintf(" %s ", cond.GetOperator())))
+	case *VectorCondition:
+		return fmt.Sprintf("FOR: %s=%v:%v %s %s", cond.IndexKey, cond.StartIndex, cond.EndIndex, cond.GetOperator(), conditionKey(cond.SCondition))
+	}
+	return fmt.Sprintf("%p", condition)
+}
@@ -0,0 +1,95 @@
+// File: network_test.go
+// Tests for match network
+package gorule
+
+import (
+	"fmt"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestMatchNetworkSharesConditions(t *testing.T) {
+	ruleSet, err := NewRuleParser(`
+	RULE "a": IF: { type == "CREDIT_CARD" && amount >= 10000 }
+	RULE "b": IF: { type == "CREDIT_CARD" && amount >= 100 }
+	RULE "c": IF: { amount >= 100 || country == "US" }
+	RULE "d": IF: { type == "CREDIT_CARD" && amount >= 10000 }
+	`).ParseRuleSet()
+	assert.Nil(t, err)
+	network := NewMatchNetwork(ruleSet)
+	// type == CC, amount >= 10000, amount >= 100, country == US
+	assert.Equal(t, 4, network.PredicateCount())
+	// 4 predicates + 3 distinct joins
+	assert.Equal(t, 7, network.NodeCount())
+	matches, err := network.Match([]byte(`{ "type": "CREDIT_CARD", "amount": 500, "country": "IN" }`))
+	assert.Nil(t, err)
+	assert.Equal(t, []string{"b", "c"}, matches)
+}
+
+func TestMatchNetworkVectorRules(t *testing.T) {
+	ruleSet, err := NewRuleParser(`
+	RULE "all_valid": IF: { FOR: i=0:attributes.size() { attributes[i].type == "VALID" } }
+	RULE "any_fraud": FOR: i=0:attributes.size() IF: { attributes[i].type == "FRAUD" }
+	RULE "none": IF: { attributes.0.type == "NONE" }
+	`).ParseRuleSet()
+	assert.Nil(t, err)
+	matches, err := NewMatchNetwork(ruleSet).Match([]byte(`{ "attributes": [ { "type": "VALID" }, { "type": "FRAUD" } ] }`))
+	assert.Nil(t, err)
+	assert.Equal(t, []string{"any_fraud"}, matches)
+}
+
+func TestMatchNetworkSameAsEngine(t *testing.T) {
+	ruleSet, err := NewRuleParser(testPricingRules).ParseRuleSet()
+	assert.Nil(t, err)
+	testdata := []byte(`{ "amount": 5000, "type": "CREDIT_CARD" }`)
+	matches, err := NewMatchNetwork(ruleSet).Match(testdata)
+	assert.Nil(t, err)
+	result, err := NewRuleEngine().EvaluateRuleSetWithStrategy(ruleSet, testdata, AllMatchesStrategy)
+	assert.Nil(t, err)
+	assert.ElementsMatch(t, result.Winners, matches)
+}
+
+// benchmarkRuleSet builds a rule set of numRules rules combining a small pool
+// of predicates, which is typical for large rule sets
+func benchmarkRuleSet(b *testing.B, numRules int) *RuleSet {
+	types := []string{"CREDIT_CARD", "DEBIT_CARD", "UPI", "WALLET"}
+	countries := []string{"US", "IN", "UK", "CA", "DE"}
+	ruleSet := NewRuleSet()
+	for i := 0; i < numRules; i++ {
+		src := fmt.Sprintf("IF: { type == \"%s\" && country == \"%s\" && amount >= %d }",
+			types[i%len(types)], countries[i%len(countries)], (i%10)*1000)
+		rule, err := NewRuleParser(src).ParseRule()
+		if err != nil {
+			b.Fatal(err)
+		}
+		_ = ruleSet.Add(fmt.Sprintf("rule_%d", i), rule)
+	}
+	return ruleSet
+}
+
+// BenchmarkRuleSetNaive evaluates every rule of 2000 rules independently
+// against the input parsed once, as the match network does
+func BenchmarkRuleSetNaive(b *testing.B) {
+	ruleSet := benchmarkRuleSet(b, 2000)
+	testdata := []byte(`{ "type": "UPI", "country": "IN", "amount": 4500 }`)
+	re := NewRuleEngine()
+	b.ResetTimer()
+	for n := 0; n < b.N; n++ {
+		doc := ParseDocument(testdata)
+		for _, name := range ruleSet.Names() {
+			rule, _ := ruleSet.Get(name)
+			_, _ = re.EvaluateDocument(rule, doc)
+		}
+	}
+}
+
+// BenchmarkRuleSetMatchNetwork matches 2000 rules using the match network
+func BenchmarkRuleSetMatchNetwork(b *testing.B) {
+	network := NewMatchNetwork(benchmarkRuleSet(b, 2000))
+	testdata := []byte(`{ "type": "UPI", "country": "IN", "amount": 4500 }`)
+	b.ResetTimer()
+	for n := 0; n < b.N; n++ {
+		_, _ = network.Match(testdata)
+	}
+}
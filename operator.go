@@ -3,6 +3,7 @@
 package gorule
 
 import (
+	"fmt"
 	"reflect"
 )
 
@@ -26,8 +27,43 @@ const (
 	LesserOperator Operator = "<"
 	// NilOperator for representing NIL operator
 	NilOperator Operator = "NIL"
+	// AddOperator for representing arithmetic + in the values of the actions (works for int, float)
+	AddOperator Operator = "+"
+	// SubtractOperator for representing arithmetic - in the values of the actions (works for int, float)
+	SubtractOperator Operator = "-"
+	// MultiplyOperator for representing arithmetic * in the values of the actions (works for int, float)
+	MultiplyOperator Operator = "*"
+	// DivideOperator for representing arithmetic / in the values of the actions (works for int, float)
+	DivideOperator Operator = "/"
 )
 
+// ArithmeticError raised when an arithmetic operation of an action can not be
+// evaluated (ex. a field which is not a number or a division by zero)
+type ArithmeticError struct {
+	Operator Operator
+	Operand1 interface{}
+	Operand2 interface{}
+	Message  string
+}
+
+func (_rt *ArithmeticError) Error() string {
+	return fmt.Sprintf("Arithmetic error : %s in %v %s %v", _rt.Message, _rt.Operand1, _rt.Operator, _rt.Operand2)
+}
+
+// isArithmetic checks if the operator is an arithmetic operator
+func isArithmetic(optor Operator) bool {
+	return optor == AddOperator || optor == SubtractOperator || optor == MultiplyOperator || optor == DivideOperator
+}
+
+// arithmeticPrecedence returns how tight the operator binds (* and / bind
+// tighter than + and -)
+func arithmeticPrecedence(optor Operator) int {
+	if optor == MultiplyOperator || optor == DivideOperator {
+		return 2
+	}
+	return 1
+}
+
 func evaluateInt(op1 int, op2 int, optor Operator) bool {
 	switch optor {
 	case EqualOperator:
@@ -80,6 +116,22 @@ func evaluateBool(op1 bool, op2 bool, optor Operator) bool {
 	return false
 }
 
+// shortCircuit returns the result of a logical operator when it can be decided
+// by the first operand alone (i.e false && x, true || x)
+func shortCircuit(operand1 interface{}, optor Operator) (bool, bool) {
+	op1, ok := operand1.(bool)
+	if !ok {
+		return false, false
+	}
+	switch optor {
+	case AndOperator:
+		return false, !op1
+	case OrOperator:
+		return true, op1
+	}
+	return false, false
+}
+
 // EvaluateOperation is used to evaluate supported operations
 // TODO: To make this more generic based on reflect package
 func EvaluateOperation(operand1 interface{}, operand2 interface{}, optor Operator) bool {
@@ -99,3 +151,41 @@ func EvaluateOperation(operand1 interface{}, operand2 interface{}, optor Operato
 		panic("Unsupported type found")
 	}
 }
+
+// EvaluateArithmetic is used to evaluate the arithmetic operations. Integers
+// stay integers except for / which always returns a float, any float operand
+// makes the result a float
+func EvaluateArithmetic(operand1 interface{}, operand2 interface{}, optor Operator) (interface{}, error) {
+	op1, isFloat1 := toFloat(operand1)
+	op2, isFloat2 := toFloat(operand2)
+	if !isFloat1 || !isFloat2 {
+		return nil, &ArithmeticError{Operator: optor, Operand1: operand1, Operand2: operand2, Message: "operands are not numbers"}
+	}
+	if optor == DivideOperator {
+		if op2 == 0 {
+			return nil, &ArithmeticError{Operator: optor, Operand1: operand1, Operand2: operand2, Message: "division by zero"}
+		}
+		return op1 / op2, nil
+	}
+	int1, isInt1 := operand1.(int)
+	int2, isInt2 := operand2.(int)
+	if isInt1 && isInt2 {
+		switch optor {
+		case AddOperator:
+			return int1 + int2, nil
+		case SubtractOperator:
+			return int1 - int2, nil
+		case MultiplyOperator:
+			return int1 * int2, nil
+		}
+	}
+	switch optor {
+	case AddOperator:
+		return op1 + op2, nil
+	case SubtractOperator:
+		return op1 - op2, nil
+	case MultiplyOperator:
+		return op1 * op2, nil
+	}
+	return nil, &ArithmeticError{Operator: optor, Operand1: operand1, Operand2: operand2, Message: "unsupported operator"}
+}
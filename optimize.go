@@ -0,0 +1,197 @@
+// File: optimize.go
+// Implements the simplification of the condition trees (constant folding, flattening and reordering)
+package gorule
+
+import (
+	"sort"
+)
+
+const (
+	// literalCost is the cost of resolving a literal operand
+	literalCost = 0
+	// fieldCost is the cost of resolving a field of the input
+	fieldCost = 1
+	// functionCost is the cost of resolving a gjson path with queries or
+	// modifiers (ex. items.#(price>10)#.name)
+	functionCost = 5
+	// vectorCost is the cost of a FOR condition on top of its condition
+	vectorCost = 20
+)
+
+// Optimize returns a copy of the rule with its condition simplified (see
+// OptimizeCondition). A compiled rule is compiled again, it is returned as is
+// if the optimized rule fails to compile
+func Optimize(rule Rule) Rule {
+	switch fgRule := rule.(type) {
+	case *ScalarRule:
+		optimized := *fgRule
+		optimized.If = OptimizeCondition(fgRule.If)
+		return &optimized
+	case *VectorRule:
+		optimized := *fgRule
+		optimized.SRule = Optimize(fgRule.SRule)
+		return &optimized
+	case *CompiledRule:
+		compiled, err := CompileRule(Optimize(fgRule.GetRule()))
+		if err != nil {
+			return rule
+		}
+		return compiled
+	}
+	return rule
+}
+
+// OptimizeRuleSet returns a rule set of the optimized rules (see Optimize)
+func OptimizeRuleSet(ruleSet *RuleSet) *RuleSet {
+	optimized := NewRuleSet()
+	for _, name := range ruleSet.names {
+		_ = optimized.Add(name, Optimize(ruleSet.rules[name]))
+	}
+	return optimized
+}
+
+// OptimizeCondition returns a simplified condition evaluating to the same
+// result. The condition is left untouched
+//   - comparisons of literals are folded (ex. 1 < 2 is true)
+//   - identities are removed (ex. true && a == 1 is a == 1)
+//   - a false operand of && (true of ||) makes the whole condition false (true)
+//   - nested chains of the same logical operator are flattened into one
+//     LogicalCondition (ex. a && ( b && c ) is a && b && c)
+//   - the operands of a chain are reordered so that the cheap ones are
+//     evaluated first (ex. a == 1 before a FOR or a gjson query)
+//
+// Reordering changes which operands are evaluated, so a rule which fails on
+// an operand (ex. a field of the wrong type) may not fail once optimized
+func OptimizeCondition(condition Condition) Condition {
+	switch cond := condition.(type) {
+	case *VectorCondition:
+		inner := OptimizeCondition(cond.SCondition)
+		if value, ok := literalBool(inner); ok && value {
+			// Every iteration is true, so is the loop over no element
+			return newLiteral(true)
+		}
+		optimized := *cond
+		optimized.SCondition = inner
+		return &optimized
+	case *LogicalCondition:
+		return optimizeChain(cond.GetOperator(), cond.GetOperands())
+	case *ScalarCondition:
+		optor := cond.GetOperator()
+		if optor == NilOperator {
+			return cond
+		}
+		if optor == AndOperator || optor == OrOperator {
+			return optimizeChain(optor, []Condition{cond.GetOperand1(), cond.GetOperand2()})
+		}
+		optimized := *cond
+		optimized.Operand1 = OptimizeCondition(cond.GetOperand1())
+		optimized.Operand2 = OptimizeCondition(cond.GetOperand2())
+		if isConstant(&optimized) {
+			if value, ok := evaluateConstant(&optimized).(bool); ok {
+				return newLiteral(value)
+			}
+		}
+		return &optimized
+	}
+	return condition
+}
+
+// optimizeChain returns the simplified operands joined by the logical operator
+func optimizeChain(optor Operator, operands []Condition) Condition {
+	// Identity of the operator (ex. true for &&), the opposite short circuits
+	identity := optor == AndOperator
+	var flattened []Condition
+	for _, operand := range operands {
+		operand = OptimizeCondition(operand)
+		flattened = append(flattened, chainOperands(operand, optor)...)
+	}
+	var kept []Condition
+	allPredicates := true
+	for _, operand := range flattened {
+		if value, ok := literalBool(operand); ok {
+			if value != identity && allPredicates {
+				// The operands before are evaluated to bool, the ones after never are
+				return newLiteral(value)
+			}
+			if value == identity {
+				continue
+			}
+		}
+		allPredicates = allPredicates && isPredicate(operand)
+		kept = append(kept, operand)
+	}
+	if allPredicates {
+		sort.SliceStable(kept, func(i, j int) bool {
+			return conditionCost(kept[i]) < conditionCost(kept[j])
+		})
+	}
+	switch {
+	case len(kept) == 0:
+		return newLiteral(identity)
+	case len(kept) == 1 && isPredicate(kept[0]):
+		return kept[0]
+	case len(kept) == 1:
+		// true && a is not the value of a unless it is a bool
+		return &ScalarCondition{Type: ScalarConditionType, Operator: optor, Operand1: newLiteral(identity), Operand2: kept[0]}
+	case len(kept) == 2:
+		return &ScalarCondition{Type: ScalarConditionType, Operator: optor, Operand1: kept[0], Operand2: kept[1]}
+	}
+	return NewLogicalCondition(optor, kept...)
+}
+
+// chainOperands returns the operands of a chain of the operator, or the
+// condition itself if it is not one
+func chainOperands(condition Condition, optor Operator) []Condition {
+	switch cond := condition.(type) {
+	case *ScalarCondition:
+		if cond.GetOperator() == optor {
+			return []Condition{cond.GetOperand1(), cond.GetOperand2()}
+		}
+	case *LogicalCondition:
+		if cond.GetOperator() == optor {
+			return cond.GetOperands()
+		}
+	}
+	return []Condition{condition}
+}
+
+// conditionCost estimates the cost of evaluating the condition
+func conditionCost(condition Condition) int {
+	switch cond := condition.(type) {
+	case *VectorCondition:
+		return vectorCost + conditionCost(cond.SCondition)
+	case *LogicalCondition:
+		cost := 0
+		for _, operand := range cond.GetOperands() {
+			cost += conditionCost(operand)
+		}
+		return cost
+	case *ScalarCondition:
+		if cond.GetOperator() != NilOperator {
+			return 1 + conditionCost(cond.GetOperand1()) + conditionCost(cond.GetOperand2())
+		}
+		path, ok := leafPath(cond)
+		switch {
+		case !ok:
+			return literalCost
+		case isGJSONPath(path):
+			return functionCost
+		}
+		return fieldCost
+	}
+	return fieldCost
+}
+
+// literalBool returns the value of a bool literal
+func literalBool(condition Condition) (bool, bool) {
+	cond, ok := condition.(*ScalarCondition)
+	if !ok || cond.GetOperator() != NilOperator {
+		return false, false
+	}
+	value, ok := cond.GetValue().(bool)
+	return value, ok
+}
+
+func newLiteral(value interface{}) *ScalarCondition {
+	return &ScalarCondition{Type: ScalarConditionType, Operator: NilOperator, Value: value}
+}
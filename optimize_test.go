@@ -0,0 +1,126 @@
+// File: optimize_test.go
+// Tests for the simplification of the condition trees
+package gorule
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestOptimize(t *testing.T) {
+	tests := map[string]string{
+		"IF: { a == 1 && b == 2 }":                                  "IF: { a == 1 && b == 2 }",
+		"IF: { a == 1 && b == 2 && c == 3 }":                        "IF: { a == 1 && b == 2 && c == 3 }",
+		"IF: { ( a == 1 && b == 2 ) && ( c == 3 && d == 4 ) }":      "IF: { a == 1 && b == 2 && c == 3 && d == 4 }",
+		"IF: { true && a == 1 }":                                    "IF: { a == 1 }",
+		"IF: { a == 1 || false }":                                   "IF: { a == 1 }",
+		"IF: { a == 1 && false }":                                   "IF: { false }",
+		"IF: { a == 1 || ( b == 2 || true ) }":                      "IF: { true }",
+		"IF: { 1 < 2 && a == 1 }":                                   "IF: { a == 1 }",
+		"IF: { \"x\" == \"y\" || a == 1 }":                          "IF: { a == 1 }",
+		"IF: { 1 == 1.0 && a == 1 }":                                "IF: { 1 == 1.0 && a == 1 }",
+		"IF: { a == 1 && ( b == 2 || c == 3 ) && d == 4 }":          "IF: { a == 1 && d == 4 && ( b == 2 || c == 3 ) }",
+		"IF: { items.# > 2 && a == 1 }":                             "IF: { a == 1 && items.# > 2 }",
+		"IF: { FOR: i=0:a.size() { 1 < 2 || a[i] > 1 } }":           "IF: { true }",
+		"IF: { true && a }":                                         "IF: { true && a }",
+		"IF: { a && ( true && b ) }":                                "IF: { a && b }",
+		"FOR: i=0:a.size() IF: { a[i] == 1 && ( true && b == 2 ) }": "FOR: i=0:a.size() IF: { a[i] == 1 && b == 2 }",
+	}
+	for src, expected := range tests {
+		rule, err := NewRuleParser(src).ParseRule()
+		assert.Nil(t, err, src)
+		assert.Equal(t, expected, Format(Optimize(rule)), src)
+	}
+}
+
+func TestOptimizeReordersVector(t *testing.T) {
+	// The parser accepts a FOR only as the whole condition, the trees of the
+	// JSON AST can join it with other conditions
+	vectorRule, err := NewRuleParser("IF: { FOR: i=0:a.size() { a[i] > 1 } }").ParseRule()
+	assert.Nil(t, err)
+	scalarRule, err := NewRuleParser("IF: { b == 2 }").ParseRule()
+	assert.Nil(t, err)
+	condition := &ScalarCondition{Type: ScalarConditionType, Operator: AndOperator,
+		Operand1: vectorRule.(*ScalarRule).If, Operand2: scalarRule.(*ScalarRule).If}
+	assert.Equal(t, "b == 2 && FOR: i=0:a.size() { a[i] > 1 }", FormatCondition(OptimizeCondition(condition)))
+}
+
+func TestOptimizeKeepsRule(t *testing.T) {
+	src := "IF: { true && ( a == 1 || b == 2 ) }"
+	rule, err := NewRuleParser(src).ParseRule()
+	assert.Nil(t, err)
+	Optimize(rule)
+	assert.Equal(t, src, Format(rule))
+}
+
+func TestOptimizeEvaluation(t *testing.T) {
+	sources := []string{
+		"IF: { ( a == 1 && b == 2 ) && ( c == 3 && d == 4 ) }",
+		"IF: { a == 1 || ( b == 2 || ( c == 3 && d == 4 ) ) }",
+		"IF: { FOR: i=0:e.size() { e[i] > 1 && ( e[i] < 5 && true ) } }",
+		"IF: { true && a == 1 && 2 > 1 }",
+	}
+	payloads := []string{
+		`{"a": 1, "b": 2, "c": 3, "d": 4, "e": [2, 3]}`,
+		`{"a": 1, "b": 2, "c": 3, "d": 5, "e": [2, 6]}`,
+		`{"a": 2, "b": 3, "c": 3, "d": 4, "e": []}`,
+		`{"a": 2, "b": 3, "c": 4, "d": 4, "e": [1]}`,
+	}
+	engine := NewRuleEngine()
+	for _, src := range sources {
+		rule, err := NewRuleParser(src).ParseRule()
+		assert.Nil(t, err, src)
+		optimized := Optimize(rule)
+		compiled, err := CompileRule(rule)
+		assert.Nil(t, err, src)
+		compiledOptimized := Optimize(compiled)
+		for _, payload := range payloads {
+			expected, err := engine.Evaluate(rule, []byte(payload))
+			assert.Nil(t, err)
+			result, err := engine.Evaluate(optimized, []byte(payload))
+			assert.Nil(t, err)
+			assert.Equal(t, expected, result, "%s on %s", Format(optimized), payload)
+			result, err = engine.Evaluate(compiledOptimized, []byte(payload))
+			assert.Nil(t, err)
+			assert.Equal(t, expected, result, "compiled %s on %s", Format(optimized), payload)
+		}
+	}
+}
+
+func TestOptimizeLogicalCondition(t *testing.T) {
+	ruleSet, err := NewRuleParser(`RULE "r1": IF: { a == 1 && b == 2 && c == 3 } RULE "r2": IF: { a == 1 && ( b == 2 && c == 4 ) }`).ParseRuleSet()
+	assert.Nil(t, err)
+	optimized := OptimizeRuleSet(ruleSet)
+	rule, _ := optimized.Get("r1")
+	assert.IsType(t, &LogicalCondition{}, rule.(*ScalarRule).If)
+
+	data, err := MarshalRuleSet(optimized)
+	assert.Nil(t, err)
+	unmarshaled, err := UnmarshalRuleSet(data)
+	assert.Nil(t, err)
+	assert.Equal(t, optimized, unmarshaled)
+
+	payload := []byte(`{"a": 1, "b": 2, "c": 3}`)
+	matches, err := NewMatchNetwork(optimized).Match(payload)
+	assert.Nil(t, err)
+	assert.Equal(t, []string{"r1"}, matches)
+
+	explanation, err := NewRuleEngine().Explain(rule, payload)
+	assert.Nil(t, err)
+	assert.Len(t, explanation.Steps[0].Steps, 3)
+	assert.False(t, explanation.Steps[0].ShortCircuit)
+	explanation, err = NewRuleEngine().Explain(rule, []byte(`{"a": 2, "b": 2, "c": 3}`))
+	assert.Nil(t, err)
+	assert.Len(t, explanation.Steps[0].Steps, 1)
+	assert.True(t, explanation.Steps[0].ShortCircuit)
+
+	// Checks walk the operands of the chain
+	rule, err = NewRuleParser(`IF: { a == 1 && ( b == 2 && a == 2 ) }`).ParseRule()
+	assert.Nil(t, err)
+	warnings := Lint(Optimize(rule), nil)
+	assert.Len(t, warnings, 1)
+	assert.Equal(t, ContradictionCheck, warnings[0].Check)
+	assert.Equal(t, "a == 1 && b == 2 && a == 2", warnings[0].Expression)
+	assert.False(t, CheckSatisfiable(Optimize(rule)).Satisfiable)
+}
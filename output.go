@@ -0,0 +1,117 @@
+// File: output.go
+// Implements the outputs of the rules computed by their THEN actions
+package gorule
+
+import "strings"
+
+// RuleOutput represents the verdict of a rule along with the values assigned by
+// its actions (ex. score = amount * 0.01)
+type RuleOutput struct {
+	Matched bool `json:"matched"`
+	// Outputs holds the values of the actions of a matching scalar rule as a
+	// JSON object nested by the dots of the paths (ex. route.queue = "manual"
+	// is {"route": {"queue": "manual"}})
+	Outputs map[string]interface{} `json:"outputs,omitempty"`
+	// Iterations holds the outputs of every matching iteration of a vector rule
+	Iterations []*IterationOutput `json:"iterations,omitempty"`
+}
+
+// IterationOutput represents the values assigned by the actions of a vector
+// rule for one matching index
+type IterationOutput struct {
+	Index   int                    `json:"index"`
+	Outputs map[string]interface{} `json:"outputs"`
+}
+
+// EvaluateOutputs evaluates the rule for the given jsonData and returns the
+// values assigned by its actions if it matches. The actions are evaluated in
+// order, so a value can read the outputs assigned before it. Unlike Infer the
+// outputs are not asserted as facts for other rules
+// args:
+//
+//	fgRule: The rule to evaluate
+//	jsonData: The data to be used during evaluation
+//
+// Return
+//
+//	RuleOutput: Verdict and outputs of the rule
+//	error: Any error during evaluation (ex. ArithmeticError)
+func (_re *RuleEngine) EvaluateOutputs(fgRule Rule, jsonData []byte) (*RuleOutput, error) {
+	return _re.EvaluateDocumentOutputs(fgRule, ParseDocument(jsonData))
+}
+
+// EvaluateDocumentOutputs evaluates the rule for an already parsed document and
+// returns the values assigned by its actions. The iterations of a vector rule
+// are evaluated independently, each with its own outputs
+func (_re *RuleEngine) EvaluateDocumentOutputs(fgRule Rule, doc *Document) (*RuleOutput, error) {
+	switch rule := fgRule.(type) {
+	case *CompiledRule:
+		return _re.EvaluateDocumentOutputs(rule.GetRule(), doc)
+	case *ScalarRule:
+		matched, outputs, err := ruleOutputs(rule, _re.buildContext(doc))
+		if err != nil {
+			return nil, err
+		}
+		return &RuleOutput{Matched: matched, Outputs: outputs}, nil
+	case *VectorRule:
+		ruleOutput := &RuleOutput{}
+		ctx := _re.buildContext(doc)
+		startIndex := rule.getInitialValue(ctx)
+		endIndex := rule.getFinalValue(ctx)
+		for i := startIndex; i < endIndex; i++ {
+			// A fresh context per iteration so the outputs of an iteration are
+			// not read by the next ones
+			iterationCtx := _re.buildContext(doc)
+			iterationCtx.SetValue(IndexKey, rule.IndexKey)
+			iterationCtx.SetValue(IndexCurrentValue, i)
+			matched, outputs, err := ruleOutputs(rule.SRule.(*ScalarRule), iterationCtx)
+			if err != nil {
+				return nil, err
+			}
+			if matched {
+				ruleOutput.Matched = true
+				ruleOutput.Iterations = append(ruleOutput.Iterations, &IterationOutput{Index: i, Outputs: outputs})
+			}
+		}
+		return ruleOutput, nil
+	}
+	return nil, &MalformedRuleError{}
+}
+
+// ruleOutputs evaluates the condition of the rule and executes its actions in
+// a working memory of its own if it matches
+func ruleOutputs(rule *ScalarRule, ctx Context) (bool, map[string]interface{}, error) {
+	result, err := rule.If.Evaluate(ctx)
+	if err != nil {
+		return false, nil, err
+	}
+	if matched, _ := result.(bool); !matched {
+		return false, nil, nil
+	}
+	memory := NewWorkingMemory()
+	ctx.SetValue(WorkingMemoryKey, memory)
+	if _, err = executeActions(rule.Then, ctx); err != nil {
+		return false, nil, err
+	}
+	outputs := make(map[string]interface{})
+	for _, path := range memory.Paths() {
+		value, _ := memory.Get(path)
+		setOutput(outputs, strings.Split(path, "."), literalValue(value))
+	}
+	return true, outputs, nil
+}
+
+// setOutput sets the value at the path of the object, creating the nested
+// objects. A value assigned later replaces the one in its way
+func setOutput(object map[string]interface{}, parts []string, value interface{}) {
+	if len(parts) == 1 {
+		object[parts[0]] = value
+		return
+	}
+	child, ok := object[parts[0]].(map[string]interface{})
+	if !ok {
+		child = make(map[string]interface{})
+		object[parts[0]] = child
+	}
+	setOutput(child, parts[1:], value)
+}
@@ -0,0 +1,95 @@
+// File: output_test.go
+// Tests for the outputs of the rules and the arithmetic of the actions
+package gorule
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestEvaluateOutputs(t *testing.T) {
+	tests := []struct {
+		src      string
+		payload  string
+		expected *RuleOutput
+	}{
+		{`IF: { amount > 100 } THEN: { score = amount * 0.01; bucket = "HIGH" }`, `{"amount": 2000}`,
+			&RuleOutput{Matched: true, Outputs: map[string]interface{}{"score": 20.0, "bucket": "HIGH"}}},
+		{`IF: { amount > 100 } THEN: { score = amount * 0.01; bucket = "HIGH" }`, `{"amount": 20}`,
+			&RuleOutput{Matched: false}},
+		// Integers stay integers except for /, precedence and groups as usual
+		{`IF: { a > 0 } THEN: { x = a + b * 2 - 1; y = ( a + b ) * 2; z = a - b - 1; w = a / 2 }`, `{"a": 3, "b": 4}`,
+			&RuleOutput{Matched: true, Outputs: map[string]interface{}{"x": 10, "y": 14, "z": -2, "w": 1.5}}},
+		// Values read the outputs assigned before them and nest by the dots of the paths
+		{`IF: { tier == "GOLD" } THEN: { route.queue = "vip"; route.priority = base + 1; total = route.priority * 10 }`, `{"tier": "GOLD", "base": 2}`,
+			&RuleOutput{Matched: true, Outputs: map[string]interface{}{"route": map[string]interface{}{"queue": "vip", "priority": 3}, "total": 30}}},
+		{`FOR: i=0:items.size() IF: { items[i].price > 10 } THEN: { fee = items[i].price * items[i].qty; label = items[i].sku }`,
+			`{"items": [{"price": 20, "qty": 2, "sku": "a"}, {"price": 5, "qty": 1, "sku": "b"}, {"price": 15, "qty": 0.5, "sku": "c"}]}`,
+			&RuleOutput{Matched: true, Iterations: []*IterationOutput{
+				{Index: 0, Outputs: map[string]interface{}{"fee": 40, "label": "a"}},
+				{Index: 2, Outputs: map[string]interface{}{"fee": 7.5, "label": "c"}},
+			}}},
+		{`FOR: i=0:items.size() IF: { items[i].price > 10 } THEN: { fee = 1 }`, `{"items": []}`, &RuleOutput{Matched: false}},
+	}
+	engine := NewRuleEngine()
+	for _, test := range tests {
+		rule, err := NewRuleParser(test.src).ParseRule()
+		assert.Nil(t, err, test.src)
+		output, err := engine.EvaluateOutputs(rule, []byte(test.payload))
+		assert.Nil(t, err, test.src)
+		assert.Equal(t, test.expected, output, test.src)
+	}
+
+	// Compiled rules have the outputs of the rule they were compiled from
+	rule, err := NewRuleParser(`IF: { amount > 100 } THEN: { score = amount / 4 }`).ParseRule()
+	assert.Nil(t, err)
+	compiled, err := CompileRule(rule)
+	assert.Nil(t, err)
+	output, err := engine.EvaluateOutputs(compiled, []byte(`{"amount": 200}`))
+	assert.Nil(t, err)
+	assert.Equal(t, map[string]interface{}{"score": 50.0}, output.Outputs)
+}
+
+func TestEvaluateOutputsErrors(t *testing.T) {
+	engine := NewRuleEngine()
+	for src, message := range map[string]string{
+		`IF: { a > 0 } THEN: { x = a / b }`:       "Arithmetic error : division by zero in 1 / 0",
+		`IF: { a > 0 } THEN: { x = a * name }`:    "Arithmetic error : operands are not numbers in 1 * \"bob\"",
+		`IF: { a > 0 } THEN: { x = a + missing }`: "Arithmetic error : operands are not numbers in 1 + missing",
+	} {
+		rule, err := NewRuleParser(src).ParseRule()
+		assert.Nil(t, err, src)
+		_, err = engine.EvaluateOutputs(rule, []byte(`{"a": 1, "b": 0, "name": "bob"}`))
+		assert.EqualError(t, err, message, src)
+	}
+
+	ruleSet, err := NewRuleParser(`RULE "bad": IF: { a > 0 } THEN: { x = a / b }`).ParseRuleSet()
+	assert.Nil(t, err)
+	_, err = engine.EvaluateRuleSetWithStrategy(ruleSet, []byte(`{"a": 1, "b": 0}`), AllMatchesStrategy)
+	assert.IsType(t, &RuleEvaluationError{}, err)
+}
+
+func TestParseActionValue(t *testing.T) {
+	rule, err := NewRuleParser(`IF: { a > 0 } THEN: { x = a - b - c ; y = 1 z = 2 }`).ParseRule()
+	assert.Nil(t, err)
+	actions := rule.(*ScalarRule).Then
+	assert.Len(t, actions, 3)
+	// Operators of the same precedence are joined from the left
+	x := actions[0].(*AssignAction).Value.(*ScalarCondition)
+	assert.Equal(t, SubtractOperator, x.GetOperator())
+	assert.Equal(t, "a - b", FormatCondition(x.GetOperand1()))
+	assert.Equal(t, "c", FormatCondition(x.GetOperand2()))
+
+	for _, src := range []string{
+		`IF: { a > 0 } THEN: { x = a + }`,
+		`IF: { a > 0 } THEN: { x = * a }`,
+		`IF: { a > 0 } THEN: { x = ( a + 1 }`,
+		`IF: { a > 0 } THEN: { x = a + 1 ) }`,
+		`IF: { a > 0 } THEN: { x = a > 1 }`,
+		`IF: { a + 1 > 0 }`,
+	} {
+		_, err := NewRuleParser(src).ParseRule()
+		assert.NotNil(t, err, src)
+	}
+}
@@ -0,0 +1,332 @@
+// File: overlap.go
+// Implements the satisfiability and overlap analysis of the rules of a rule set
+package gorule
+
+import (
+	"encoding/json"
+	"fmt"
+	"strings"
+)
+
+// maxDisjunctiveTerms bounds the number of conjunctions of the disjunctive form
+// of a condition. Larger conditions are not analysed
+const maxDisjunctiveTerms = 256
+
+// atom represents a comparison of a field with a literal (ex. amount > 100)
+type atom struct {
+	path  string
+	optor Operator
+	value interface{}
+}
+
+// conjunction represents a conjunction of atoms. Clauses which can not be
+// reasoned about (ex. FOR, a field compared with a field) are left out i.e.
+// assumed to be true for some input
+type conjunction struct {
+	atoms []atom
+}
+
+// disjunctiveForm returns the condition (or its negation) as a disjunction of
+// conjunctions. An empty disjunction is never true. FOR conditions are left
+// out unless arrays is set, in which case they are reasoned about over arrays
+// with a single element iterated (arrays records the length of the arrays)
+func disjunctiveForm(condition Condition, negated bool, arrays map[string]int) []conjunction {
+	if vectorCond, ok := condition.(*VectorCondition); ok && arrays != nil {
+		if recordArray(arrays, vectorCond.StartIndex, vectorCond.EndIndex) {
+			return disjunctiveForm(vectorCond.SCondition, negated, arrays)
+		}
+	}
+	if logicalCond, ok := condition.(*LogicalCondition); ok {
+		// Starting from the identity of the operator (never true for ||)
+		var form []conjunction
+		if (logicalCond.GetOperator() == OrOperator) == negated {
+			form = []conjunction{{}}
+		}
+		for _, operand := range logicalCond.GetOperands() {
+			form = joinForms(form, disjunctiveForm(operand, negated, arrays), logicalCond.GetOperator(), negated)
+		}
+		return form
+	}
+	cond, ok := condition.(*ScalarCondition)
+	if !ok {
+		return []conjunction{{}}
+	}
+	optor := cond.GetOperator()
+	switch {
+	case optor == AndOperator || optor == OrOperator:
+		form1 := disjunctiveForm(cond.GetOperand1(), negated, arrays)
+		form2 := disjunctiveForm(cond.GetOperand2(), negated, arrays)
+		return joinForms(form1, form2, optor, negated)
+	case isConstant(cond):
+		if value, ok := evaluateConstant(cond).(bool); ok {
+			if value != negated {
+				return []conjunction{{}}
+			}
+			return nil
+		}
+	default:
+		if path, atomOptor, value, ok := comparisonAtom(cond, arrays != nil); ok {
+			if negated {
+				atomOptor = negatedOperators[atomOptor]
+			}
+			return []conjunction{{atoms: []atom{{path: path, optor: atomOptor, value: value}}}}
+		}
+	}
+	return []conjunction{{}}
+}
+
+// joinForms returns the disjunctive form of the operands joined by the logical
+// operator (or its negation)
+func joinForms(form1 []conjunction, form2 []conjunction, optor Operator, negated bool) []conjunction {
+	// De Morgan: the negation of a && b is !a || !b
+	if (optor == OrOperator) != negated {
+		return append(form1, form2...)
+	}
+	if len(form1)*len(form2) > maxDisjunctiveTerms {
+		return []conjunction{{}}
+	}
+	var product []conjunction
+	for _, conj1 := range form1 {
+		for _, conj2 := range form2 {
+			atoms := append(append([]atom(nil), conj1.atoms...), conj2.atoms...)
+			product = append(product, conjunction{atoms: atoms})
+		}
+	}
+	return product
+}
+
+// ruleForm returns the disjunctive form of the IF condition of the rule (or
+// its negation). Vector rules are analysed only if arrays is set (see
+// disjunctiveForm)
+func ruleForm(rule Rule, negated bool, arrays map[string]int) []conjunction {
+	switch fgRule := rule.(type) {
+	case *CompiledRule:
+		return ruleForm(fgRule.GetRule(), negated, arrays)
+	case *VectorRule:
+		if arrays != nil && recordArray(arrays, fgRule.StartIndex, fgRule.EndIndex) {
+			return ruleForm(fgRule.SRule, negated, arrays)
+		}
+	case *ScalarRule:
+		return disjunctiveForm(fgRule.If, negated, arrays)
+	}
+	return []conjunction{{}}
+}
+
+// recordArray records the length of the array iterated by a FOR so that the
+// iteration sees a single element (ex. 2 for i=1:a.size()). Returns false if
+// the range is not a start index up to the size of an array
+func recordArray(arrays map[string]int, startIndex interface{}, endIndex interface{}) bool {
+	start, ok := StringToInterface(fmt.Sprint(startIndex)).(int)
+	path, isSize := strings.CutSuffix(fmt.Sprint(endIndex), ".size()")
+	if !ok || !isSize || start < 0 {
+		return false
+	}
+	if start+1 > arrays[path] {
+		arrays[path] = start + 1
+	}
+	return true
+}
+
+// solve returns the values of the fields satisfying all the conjunctions.
+// Returns false if no input satisfies them
+func solve(conjunctions ...conjunction) (map[string]interface{}, bool) {
+	constraints := make(map[string]*fieldConstraint)
+	var paths []string
+	for _, conj := range conjunctions {
+		for _, clause := range conj.atoms {
+			constraint, ok := constraints[clause.path]
+			if !ok {
+				constraint = &fieldConstraint{}
+				constraints[clause.path] = constraint
+				paths = append(paths, clause.path)
+			}
+			constraint.add(clause.optor, clause.value)
+		}
+	}
+	values := make(map[string]interface{})
+	for _, path := range paths {
+		value, ok := constraints[path].pick()
+		if !ok {
+			return nil, false
+		}
+		if value != nil {
+			values[path] = value
+		}
+	}
+	return values, true
+}
+
+// witnessPayload returns the payload holding the values at their paths
+// (ex. card.type is {"card": {"type": ...}}). Indexed paths (ex. a[i].b) set
+// all the elements of an array of the length recorded in arrays (default 1)
+func witnessPayload(values map[string]interface{}, arrays map[string]int) map[string]interface{} {
+	payload := make(map[string]interface{})
+	for _, path := range sortedKeys(values) {
+		parts := strings.Split(path, ".")
+		node := payload
+		for i, part := range parts {
+			last := i == len(parts)-1
+			bracket := strings.Index(part, "[")
+			if bracket < 0 {
+				if last {
+					node[part] = values[path]
+					break
+				}
+				child, ok := node[part].(map[string]interface{})
+				if !ok {
+					child = make(map[string]interface{})
+					node[part] = child
+				}
+				node = child
+				continue
+			}
+			// The elements share the same object, setting a field of one sets all
+			name := part[:bracket]
+			elements, ok := node[name].([]interface{})
+			if !ok {
+				length := arrays[strings.Join(append(append([]string(nil), parts[:i]...), name), ".")]
+				if length < 1 {
+					length = 1
+				}
+				elements = make([]interface{}, length)
+				element := make(map[string]interface{})
+				for j := range elements {
+					elements[j] = element
+				}
+				node[name] = elements
+			}
+			if last {
+				for j := range elements {
+					elements[j] = values[path]
+				}
+				break
+			}
+			element, ok := elements[0].(map[string]interface{})
+			if !ok {
+				break
+			}
+			node = element
+		}
+	}
+	return payload
+}
+
+// matches checks if the rule matches the payload
+func matches(rule Rule, payload map[string]interface{}) bool {
+	data, err := json.Marshal(payload)
+	if err != nil {
+		return false
+	}
+	matched, err := evaluatePayload(rule, data)
+	return err == nil && matched
+}
+
+// Satisfiability represents whether a rule can match any input
+type Satisfiability struct {
+	// Satisfiable is set unless the rule is proven to never match
+	Satisfiable bool `json:"satisfiable"`
+	// Witness is a payload matching the rule
+	Witness map[string]interface{} `json:"witness,omitempty"`
+	// Verified is set if evaluating the rule for the witness matched
+	Verified bool `json:"verified"`
+}
+
+// CheckSatisfiable checks if some input matches the rule and returns such an
+// input. The analysis reasons over the comparisons of the fields with
+// literals, the other conditions (ex. FOR) are assumed to be true for some
+// input so a rule is reported never matching only if it is proven
+func CheckSatisfiable(rule Rule) *Satisfiability {
+	satisfiability := &Satisfiability{}
+	for _, conj := range ruleForm(rule, false, nil) {
+		values, ok := solve(conj)
+		if !ok {
+			continue
+		}
+		payload := witnessPayload(values, nil)
+		verified := matches(rule, payload)
+		if !satisfiability.Satisfiable || verified {
+			satisfiability.Satisfiable, satisfiability.Witness, satisfiability.Verified = true, payload, verified
+		}
+		if verified {
+			break
+		}
+	}
+	return satisfiability
+}
+
+// RuleRelation represents how the inputs matched by two rules relate
+type RuleRelation struct {
+	Rule1 string `json:"rule1"`
+	Rule2 string `json:"rule2"`
+	// Overlap is set unless the rules are proven to never match the same input
+	Overlap bool `json:"overlap"`
+	// Witness is a payload matching both the rules if Verified. Otherwise it is
+	// only a candidate from the analysed conditions, which may fail to evaluate
+	Witness map[string]interface{} `json:"witness,omitempty"`
+	// Verified is set if evaluating the rules for the witness matched both
+	Verified bool `json:"verified"`
+	// Subsumes is set if Rule1 is proven to match every input Rule2 matches
+	Subsumes bool `json:"subsumes"`
+	// SubsumedBy is set if Rule2 is proven to match every input Rule1 matches
+	SubsumedBy bool `json:"subsumed_by"`
+}
+
+// AnalyzeOverlap checks if the rules can match the same input and if one
+// subsumes the other. Like CheckSatisfiable, only the proofs are exact: rules
+// reported disjoint never match the same input
+func AnalyzeOverlap(name1 string, rule1 Rule, name2 string, rule2 Rule) *RuleRelation {
+	relation := &RuleRelation{Rule1: name1, Rule2: name2}
+	form1, form2 := ruleForm(rule1, false, nil), ruleForm(rule2, false, nil)
+	for _, conj1 := range form1 {
+		for _, conj2 := range form2 {
+			values, ok := solve(conj1, conj2)
+			if !ok || relation.Verified {
+				continue
+			}
+			payload := witnessPayload(values, nil)
+			verified := matches(rule1, payload) && matches(rule2, payload)
+			if !relation.Overlap || verified {
+				relation.Overlap, relation.Witness, relation.Verified = true, payload, verified
+			}
+		}
+	}
+	relation.Subsumes = subsumes(rule1, form2)
+	relation.SubsumedBy = subsumes(rule2, form1)
+	return relation
+}
+
+// subsumes checks if the rule matches every input of the form i.e. the form
+// and the negation of the rule are never true together. The clauses left out
+// only narrow a conjunction, the proof holds without them
+func subsumes(rule Rule, form []conjunction) bool {
+	if len(form) == 0 {
+		// A rule which never matches is not subsumed by anything in practice
+		return false
+	}
+	negation := ruleForm(rule, true, nil)
+	for _, conj := range form {
+		for _, negated := range negation {
+			if _, ok := solve(conj, negated); ok {
+				return false
+			}
+		}
+	}
+	return true
+}
+
+// AnalyzeRuleSetOverlap returns the relations of the pairs of rules of the
+// rule set which may match the same input, in the order of the rule set. An
+// empty result proves the rules are mutually exclusive, only the verified
+// witnesses prove an overlap
+func AnalyzeRuleSetOverlap(ruleSet *RuleSet) []*RuleRelation {
+	relations := []*RuleRelation{}
+	for i, name1 := range ruleSet.names {
+		for _, name2 := range ruleSet.names[i+1:] {
+			relation := AnalyzeOverlap(name1, ruleSet.rules[name1], name2, ruleSet.rules[name2])
+			if relation.Overlap {
+				relations = append(relations, relation)
+			}
+		}
+	}
+	return relations
+}
@@ -0,0 +1,89 @@
+// File: overlap_test.go
+package gorule
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+const pricingRules = `RULE "bronze":
+	IF: { amount < 1000 }
+RULE "silver":
+	IF: { amount >= 1000 && amount < 5000 }
+RULE "gold":
+	IF: { amount >= 5000 }
+`
+
+func parseRuleSetText(t *testing.T, src string) *RuleSet {
+	ruleSet, err := NewRuleParser(src).ParseRuleSet()
+	assert.Nil(t, err)
+	return ruleSet
+}
+
+func parseRuleText(t *testing.T, src string) Rule {
+	rule, err := NewRuleParser(src).ParseRule()
+	assert.Nil(t, err)
+	return rule
+}
+
+func TestOverlapExclusive(t *testing.T) {
+	assert.Empty(t, AnalyzeRuleSetOverlap(parseRuleSetText(t, pricingRules)))
+}
+
+func TestOverlapWitness(t *testing.T) {
+	ruleSet := parseRuleSetText(t, `RULE "silver":
+	IF: { amount >= 1000 && amount <= 5000 && type == "CC" }
+RULE "gold":
+	IF: { amount >= 5000 && ( type == "CC" || type == "DC" ) }
+RULE "debit":
+	IF: { type == "DC" && 6000 <= amount }
+`)
+	relations := AnalyzeRuleSetOverlap(ruleSet)
+	assert.Equal(t, 2, len(relations))
+	assert.Equal(t, "silver", relations[0].Rule1)
+	assert.Equal(t, "gold", relations[0].Rule2)
+	assert.True(t, relations[0].Verified)
+	assert.Equal(t, map[string]interface{}{"amount": 5000, "type": "CC"}, relations[0].Witness)
+	assert.False(t, relations[0].Subsumes || relations[0].SubsumedBy)
+	assert.Equal(t, "gold", relations[1].Rule1)
+	assert.Equal(t, "debit", relations[1].Rule2)
+	assert.True(t, relations[1].Verified)
+	assert.True(t, relations[1].Subsumes)
+	assert.False(t, relations[1].SubsumedBy)
+}
+
+func TestOverlapSubsumption(t *testing.T) {
+	rule1 := parseRuleText(t, `IF: { card.limit > 100 || card.limit <= 100 && card.type == "GOLD" }`)
+	rule2 := parseRuleText(t, `IF: { card.limit > 200.5 }`)
+	relation := AnalyzeOverlap("any", rule1, "high", rule2)
+	assert.True(t, relation.Overlap)
+	assert.True(t, relation.Subsumes)
+	assert.False(t, relation.SubsumedBy)
+	assert.Equal(t, map[string]interface{}{"card": map[string]interface{}{"limit": 201.5}}, relation.Witness)
+
+	rule3 := parseRuleText(t, `IF: { amount > 1 && amount < 2 }`)
+	assert.False(t, CheckSatisfiable(rule3).Satisfiable)
+	satisfiability := CheckSatisfiable(rule2)
+	assert.True(t, satisfiability.Satisfiable)
+	assert.True(t, satisfiability.Verified)
+
+	// Comparisons of fields are not analysed, the witness is verified by
+	// evaluating the rules
+	same := parseRuleText(t, `IF: { x == x }`)
+	relation = AnalyzeOverlap("high", rule2, "same", same)
+	assert.True(t, relation.Verified)
+	assert.True(t, matches(rule2, relation.Witness) && matches(same, relation.Witness))
+	fields := parseRuleText(t, `IF: { card.limit == x }`)
+	relation = AnalyzeOverlap("high", rule2, "fields", fields)
+	assert.True(t, relation.Overlap)
+	assert.False(t, relation.Verified)
+	assert.False(t, matches(fields, relation.Witness))
+
+	// FOR conditions are not analysed, the overlap is only possible
+	rule4 := parseRuleText(t, `FOR: i=0:items.size() IF: { items[i].price > 10 }`)
+	relation = AnalyzeOverlap("high", rule2, "items", rule4)
+	assert.True(t, relation.Overlap)
+	assert.False(t, relation.Verified)
+	assert.False(t, relation.Subsumes || relation.SubsumedBy)
+}
@@ -29,6 +29,18 @@ const (
 	ColonToken Token = ":"
 	// ForToken represents start if For rule
 	ForToken Token = "FOR:"
+	// AssignToken represents assignment of a value in an action
+	AssignToken Token = "="
+	// SemicolonToken represents delimiter of actions
+	SemicolonToken Token = ";"
+	// RuleToken represents start of the header of a named rule
+	RuleToken Token = "RULE"
+	// PriorityToken represents the priority in the rule header
+	PriorityToken Token = "PRIORITY"
+	// TagsToken represents the tags in the rule header
+	TagsToken Token = "TAGS"
+	// DescriptionToken represents the description in the rule header
+	DescriptionToken Token = "DESCRIPTION"
 )
 
 // EOFError rerpresents end of file error
@@ -66,8 +78,14 @@ type RuleParser struct {
 	optorStack   *stack.Stack
 	oprndStack   *stack.Stack
 	input        string
+	sourceMap    SourceMap
 }
 
+// SourceMap maps the nodes of the parsed rules to their offset in the source.
+// Conditions map to the start of their first token, actions to their path and
+// FOR loops (vector rules and conditions) to their end index (ex. a.size())
+type SourceMap map[interface{}]int
+
 // NewRuleParser returns the fresh instance of RuleParser
 func NewRuleParser(ip string) *RuleParser {
 	parserInstance := &RuleParser{}
@@ -80,6 +98,17 @@ func (_p *RuleParser) init(ip string) {
 	_p.oprndStack = stack.New()
 	_p.currentIndex = 0
 	_p.input = ip
+	_p.sourceMap = make(SourceMap)
+}
+
+// SourceMap returns the offsets of the nodes parsed so far
+func (_p *RuleParser) SourceMap() SourceMap {
+	return _p.sourceMap
+}
+
+// tokenOffset returns the offset of the start of the current token
+func (_p *RuleParser) tokenOffset() int {
+	return _p.currentIndex - len(_p.currentToken)
 }
 
 func (_p *RuleParser) isTokenValid(token Token) bool {
@@ -95,7 +124,7 @@ func (_p *RuleParser) getNextToken() (Token, error) {
 	var token Token
 	token = ""
 	// Trim all white spaces/new lines at head
-	for _p.currentIndex < len(_p.input) && (_p.input[_p.currentIndex] == ' ' || _p.input[_p.currentIndex] == '\n') {
+	for _p.currentIndex < len(_p.input) && isWhiteSpace(_p.input[_p.currentIndex]) {
 		_p.currentIndex++
 	}
 	// Check if we have reached end of file
@@ -104,7 +133,27 @@ func (_p *RuleParser) getNextToken() (Token, error) {
 		return "", &eofError{}
 	}
 	// Exit if we run out of string OR we find space OR token so far is valid
-	for !(_p.currentIndex >= len(_p.input) || _p.input[_p.currentIndex] == ' ' || _p.isTokenValid(token)) {
+	for !(_p.currentIndex >= len(_p.input) || isWhiteSpace(_p.input[_p.currentIndex]) || _p.isTokenValid(token)) {
+		if curChar := _p.input[_p.currentIndex]; curChar == '(' && token == "" {
+			// ( opens a group (a.size() is a part of the token)
+			token = OpenBraceToken
+			_p.currentIndex++
+			break
+		} else if curChar == ')' && !strings.HasSuffix(string(token), "(") {
+			// ) closes a group, it is a token on its own
+			if token == "" {
+				token = CloseBraceToken
+				_p.currentIndex++
+			}
+			break
+		}
+		if _p.input[_p.currentIndex] == '"' {
+			// Quoted strings can hold white spaces, take them as a whole
+			endIndex := quotedStringEnd(_p.input, _p.currentIndex)
+			token += Token(_p.input[_p.currentIndex:endIndex])
+			_p.currentIndex = endIndex
+			continue
+		}
 		token += Token(_p.input[_p.currentIndex])
 		_p.currentIndex++
 	}
@@ -160,6 +209,9 @@ func (_p *RuleParser) formExpression() Condition {
 		op2, _ := _p.oprndStack.Pop().(Condition)
 		op1, _ := _p.oprndStack.Pop().(Condition)
 		curCond := &ScalarCondition{Type: ScalarConditionType, Operator: topOptor, Value: nil, Operand1: op1, Operand2: op2}
+		if offset, ok := _p.sourceMap[op1]; ok {
+			_p.sourceMap[curCond] = offset
+		}
 		_p.oprndStack.Push(curCond)
 		_p.optorStack.Pop()
 		return curCond
@@ -177,14 +229,22 @@ func (_p *RuleParser) createLeafCond(token interface{}) Condition {
 }
 
 // Parse the token such as i=0:b.size() and return index key, start_index(string), end_index(string)
-func (_p *RuleParser) parseForVectorDefinitions() (string, interface{}, interface{}, error) {
+func (_p *RuleParser) parseForVectorDefinitions(node interface{}) (string, interface{}, interface{}, error) {
 	token, err := _p.getNextToken()
 	if err != nil {
 		return "nil", nil, nil, err
 	}
+	// Format: i=start:end
 	subTokens := strings.Split(string(token), "=")
+	if len(subTokens) != 2 || subTokens[0] == "" {
+		return "nil", nil, nil, &SyntaxError{Expected: "index=start:end", Found: token, Index: _p.currentIndex}
+	}
 	indexKey := subTokens[0]
 	subTokens = strings.Split(subTokens[1], ":")
+	if len(subTokens) != 2 || subTokens[0] == "" || subTokens[1] == "" {
+		return "nil", nil, nil, &SyntaxError{Expected: "index=start:end", Found: token, Index: _p.currentIndex}
+	}
+	_p.sourceMap[node] = _p.tokenOffset() + strings.LastIndex(string(token), subTokens[1])
 	return indexKey, subTokens[0], subTokens[1], nil
 }
 
@@ -209,12 +269,27 @@ func (_p *RuleParser) parseScalarCondition() (Condition, error) {
 	}
 	var curToken Token
 	var err error
+	_p.optorStack = stack.New()
+	_p.oprndStack = stack.New()
 	curToken, err = _p.getNextToken()
 	for curToken != CurlyCloseBraceToken && err == nil {
-		if !_p.isOperator(curToken) {
+		if curToken == OpenBraceToken {
+			// Start of a group ex. ( a || b ) && c
+			_p.optorStack.Push(curToken)
+		} else if curToken == CloseBraceToken {
+			// Form the expression of the group
+			for _p.optorStack.Len() > 0 && _p.optorStack.Peek() != OpenBraceToken {
+				_ = _p.formExpression()
+			}
+			if _p.optorStack.Len() == 0 {
+				return nil, &SyntaxError{Expected: OpenBraceToken, Found: curToken, Index: _p.currentIndex}
+			}
+			_p.optorStack.Pop()
+		} else if !_p.isOperator(curToken) {
 			// Leaf level node in the decision tree
 			parsedValue := StringToInterface(string(curToken))
 			leafCond := _p.createLeafCond(parsedValue)
+			_p.sourceMap[leafCond] = _p.tokenOffset()
 			_p.oprndStack.Push(leafCond)
 		} else {
 			curOptor := Operator(curToken)
@@ -235,17 +310,40 @@ func (_p *RuleParser) parseScalarCondition() (Condition, error) {
 					// Push the opperator
 					_p.optorStack.Push(curOptor)
 				}
+			} else {
+				// Operator inside a group
+				_p.optorStack.Push(curOptor)
 			}
 		}
 		curToken, err = _p.getNextToken()
 	}
 	for _p.optorStack.Len() > 0 {
-		_ = _p.formExpression()
+		if _p.formExpression() == nil {
+			// Group is not closed
+			return nil, &SyntaxError{Expected: CloseBraceToken, Found: curToken, Index: _p.currentIndex}
+		}
+	}
+	if _p.oprndStack.Len() != 1 {
+		return nil, &MalformedRuleError{}
 	}
-	if _p.oprndStack.Len() > 1 {
+	condition := _p.oprndStack.Pop().(Condition)
+	if hasMissingOperand(condition) {
+		// Operator without operand ex. { a == 1 && }
 		return nil, &MalformedRuleError{}
 	}
-	return _p.oprndStack.Pop().(Condition), nil
+	return condition, nil
+}
+
+// hasMissingOperand checks if any operator of the condition tree lacks an operand
+func hasMissingOperand(condition Condition) bool {
+	scalarCondition, ok := condition.(*ScalarCondition)
+	if !ok || scalarCondition.GetOperator() == NilOperator {
+		return false
+	}
+	if scalarCondition.GetOperand1() == nil || scalarCondition.GetOperand2() == nil {
+		return true
+	}
+	return hasMissingOperand(scalarCondition.GetOperand1()) || hasMissingOperand(scalarCondition.GetOperand2())
 }
 
 // Parse vector condition and create a conditions tree
@@ -265,12 +363,16 @@ func (_p *RuleParser) parseVectorCondition() (Condition, error) {
 	if curToken, err = _p.getNextToken(); curToken != ForToken || err != nil {
 		return nil, &SyntaxError{Expected: ForToken, Found: curToken, Index: _p.currentIndex}
 	}
-	if vectorCondition.IndexKey, vectorCondition.StartIndex, vectorCondition.EndIndex, err = _p.parseForVectorDefinitions(); err != nil {
+	if vectorCondition.IndexKey, vectorCondition.StartIndex, vectorCondition.EndIndex, err = _p.parseForVectorDefinitions(vectorCondition); err != nil {
 		return nil, err
 	}
 	if vectorCondition.SCondition, err = _p.parseCondition(); err != nil {
 		return nil, err
 	}
+	// Vector condition is closed by }
+	if curToken, err = _p.getNextToken(); curToken != CurlyCloseBraceToken || err != nil {
+		return nil, &SyntaxError{Expected: CurlyCloseBraceToken, Found: curToken, Index: _p.currentIndex}
+	}
 	return vectorCondition, nil
 }
 
@@ -338,7 +440,7 @@ func (_p *RuleParser) parseVectorRule() (Rule, error) {
 		return nil, err
 	}
 	vectorRule := &VectorRule{Type: VectorRuleType}
-	if vectorRule.IndexKey, vectorRule.StartIndex, vectorRule.EndIndex, err = _p.parseForVectorDefinitions(); err != nil {
+	if vectorRule.IndexKey, vectorRule.StartIndex, vectorRule.EndIndex, err = _p.parseForVectorDefinitions(vectorRule); err != nil {
 		return nil, err
 	}
 	if vectorRule.SRule, err = _p.parseScalarRule(); err != nil {
@@ -347,8 +449,148 @@ func (_p *RuleParser) parseVectorRule() (Rule, error) {
 	return vectorRule, nil
 }
 
-func (_p *RuleParser) parseActions() (Rule, error) {
-	return nil, nil
+// Parse the (optional) action block of a rule
+//
+// Format: THEN: { ACTION ; ACTION ; ... }
+func (_p *RuleParser) parseActions() (ActionList, error) {
+	rewindIndex := _p.currentIndex
+	if curToken, err := _p.getNextToken(); curToken != ThenToken || err != nil {
+		// Rule has no actions
+		_p.rewind(rewindIndex)
+		return nil, nil
+	}
+	if err := _p.validateConditionStart(); err != nil {
+		return nil, err
+	}
+	var actions ActionList
+	for {
+		action, isLast, err := _p.parseAction()
+		if err != nil {
+			return nil, err
+		}
+		if action != nil {
+			actions = append(actions, action)
+		}
+		if isLast {
+			return actions, nil
+		}
+	}
+}
+
+// Parse one action of the action block. Returns true if the action block is closed
+//
+// Format: path = value ;
+func (_p *RuleParser) parseAction() (Action, bool, error) {
+	curToken, err := _p.getNextToken()
+	if err != nil {
+		return nil, true, &SyntaxError{Expected: CurlyCloseBraceToken, Found: curToken, Index: _p.currentIndex}
+	}
+	switch curToken {
+	case CurlyCloseBraceToken:
+		return nil, true, nil
+	case SemicolonToken:
+		return nil, false, nil
+	}
+	if _p.isOperator(curToken) || curToken == AssignToken {
+		return nil, true, &SyntaxError{Expected: "path", Found: curToken, Index: _p.currentIndex}
+	}
+	action := &AssignAction{Type: AssignActionType, Path: string(curToken)}
+	_p.sourceMap[action] = _p.tokenOffset()
+	if curToken, err = _p.getNextToken(); curToken != AssignToken || err != nil {
+		return nil, true, &SyntaxError{Expected: AssignToken, Found: curToken, Index: _p.currentIndex}
+	}
+	value, isLast, err := _p.parseActionValue()
+	if err != nil {
+		return nil, true, err
+	}
+	action.Value = value
+	return action, isLast, nil
+}
+
+// Parse the value of an action, an operand or an arithmetic expression. * and /
+// bind tighter than + and -, operators of the same precedence are joined from
+// the left (ex. a - b - c is ( a - b ) - c). Returns true if the action block
+// is closed
+//
+// Format: amount * ( rate + 1 ) ;
+func (_p *RuleParser) parseActionValue() (Condition, bool, error) {
+	optorStack := stack.New()
+	oprndStack := stack.New()
+	expectOperand := true
+	isLast := false
+	for {
+		rewindIndex := _p.currentIndex
+		curToken, err := _p.getNextToken()
+		if err != nil {
+			return nil, true, &SyntaxError{Expected: CurlyCloseBraceToken, Found: curToken, Index: _p.currentIndex}
+		}
+		// Value can be terminated by ; (ex. tier = "GOLD";)
+		isTerminated := curToken != SemicolonToken && strings.HasSuffix(string(curToken), string(SemicolonToken))
+		curToken = Token(strings.TrimSuffix(string(curToken), string(SemicolonToken)))
+		if curToken == "" || curToken == CurlyCloseBraceToken {
+			isLast = curToken == CurlyCloseBraceToken
+			if expectOperand {
+				return nil, true, &SyntaxError{Expected: "value", Found: curToken, Index: _p.currentIndex}
+			}
+			break
+		}
+		curOptor := Operator(curToken)
+		switch {
+		case curToken == OpenBraceToken && expectOperand:
+			optorStack.Push(curToken)
+		case curToken == CloseBraceToken && !expectOperand:
+			for optorStack.Len() > 0 && optorStack.Peek() != OpenBraceToken {
+				_p.formArithmetic(optorStack, oprndStack)
+			}
+			if optorStack.Len() == 0 {
+				return nil, true, &SyntaxError{Expected: OpenBraceToken, Found: curToken, Index: _p.currentIndex}
+			}
+			optorStack.Pop()
+		case isArithmetic(curOptor) && !expectOperand:
+			for optorStack.Len() > 0 && optorStack.Peek() != OpenBraceToken &&
+				arithmeticPrecedence(optorStack.Peek().(Operator)) >= arithmeticPrecedence(curOptor) {
+				_p.formArithmetic(optorStack, oprndStack)
+			}
+			optorStack.Push(curOptor)
+			expectOperand = true
+		case expectOperand:
+			if curToken == CloseBraceToken || curToken == AssignToken || isArithmetic(curOptor) || _p.isOperator(curToken) {
+				return nil, true, &SyntaxError{Expected: "value", Found: curToken, Index: _p.currentIndex}
+			}
+			leafCond := _p.createLeafCond(StringToInterface(string(curToken)))
+			_p.sourceMap[leafCond] = _p.tokenOffset()
+			oprndStack.Push(leafCond)
+			expectOperand = false
+		default:
+			// The value is complete, the token starts the next action
+			_p.rewind(rewindIndex)
+			isTerminated = true
+		}
+		if isTerminated {
+			if expectOperand {
+				return nil, true, &SyntaxError{Expected: "value", Found: curToken, Index: _p.currentIndex}
+			}
+			break
+		}
+	}
+	for optorStack.Len() > 0 {
+		if optorStack.Peek() == OpenBraceToken {
+			return nil, true, &SyntaxError{Expected: CloseBraceToken, Found: "", Index: _p.currentIndex}
+		}
+		_p.formArithmetic(optorStack, oprndStack)
+	}
+	return oprndStack.Pop().(Condition), isLast, nil
+}
+
+// formArithmetic joins the top two operands with the operator on top of the stack
+func (_p *RuleParser) formArithmetic(optorStack *stack.Stack, oprndStack *stack.Stack) {
+	op2, _ := oprndStack.Pop().(Condition)
+	op1, _ := oprndStack.Pop().(Condition)
+	curCond := &ScalarCondition{Type: ScalarConditionType, Operator: optorStack.Pop().(Operator), Operand1: op1, Operand2: op2}
+	if offset, ok := _p.sourceMap[op1]; ok {
+		_p.sourceMap[curCond] = offset
+	}
+	oprndStack.Push(curCond)
 }
 
 // ParseRule main entry to parse the rule
@@ -362,18 +604,176 @@ func (_p *RuleParser) parseActions() (Rule, error) {
 // TODO: Handle all cases - For now simplest case a && b && c
 func (_p *RuleParser) ParseRule() (Rule, error) {
 	rewindIndex := _p.currentIndex
-	// Outer layer has to be one of IF: / FOR:
+	// Outer layer has to be one of RULE / IF: / FOR:
 	ruleToken, e := _p.getNextToken()
 	if e != nil {
 		return nil, &MalformedRuleError{}
 	}
 	_p.rewind(rewindIndex)
+	var metadata RuleMetadata
+	if ruleToken == RuleToken {
+		if metadata, e = _p.parseRuleHeader(); e != nil {
+			return nil, e
+		}
+		rewindIndex = _p.currentIndex
+		ruleToken, _ = _p.getNextToken()
+		_p.rewind(rewindIndex)
+	}
+	var rule Rule
 	switch ruleToken {
 	case IfToken:
-		return _p.parseScalarRule()
+		rule, e = _p.parseScalarRule()
 	case ForToken:
-		return _p.parseVectorRule()
+		rule, e = _p.parseVectorRule()
 	default:
 		return nil, &MalformedRuleError{}
 	}
+	if e != nil {
+		return nil, e
+	}
+	*rule.GetMetadata() = metadata
+	return rule, nil
+}
+
+// Parse the header of a named rule. Everything except the name is optional
+//
+// Format: RULE "name" PRIORITY 10 TAGS [tag1, tag2] DESCRIPTION "description":
+func (_p *RuleParser) parseRuleHeader() (RuleMetadata, error) {
+	metadata := RuleMetadata{}
+	curToken, err := _p.getNextToken()
+	if curToken != RuleToken || err != nil {
+		return metadata, &SyntaxError{Expected: RuleToken, Found: curToken, Index: _p.currentIndex}
+	}
+	// Header is terminated by the token ending with :
+	var headerTokens []string
+	for {
+		if curToken, err = _p.getNextToken(); err != nil || curToken == IfToken || curToken == ForToken {
+			return metadata, &SyntaxError{Expected: ColonToken, Found: curToken, Index: _p.currentIndex}
+		}
+		if strings.HasSuffix(string(curToken), string(ColonToken)) {
+			if lastToken := strings.TrimSuffix(string(curToken), string(ColonToken)); lastToken != "" {
+				headerTokens = append(headerTokens, lastToken)
+			}
+			break
+		}
+		headerTokens = append(headerTokens, string(curToken))
+	}
+	if len(headerTokens) == 0 {
+		return metadata, &MalformedRuleError{}
+	}
+	metadata.Name = unquoteHeader(headerTokens[0])
+	if metadata.Name == "" {
+		return metadata, &MalformedRuleError{}
+	}
+	for i := 1; i < len(headerTokens); i++ {
+		switch Token(headerTokens[i]) {
+		case PriorityToken:
+			if i+1 >= len(headerTokens) {
+				return metadata, &MalformedRuleError{}
+			}
+			i++
+			priority, ok := StringToInterface(headerTokens[i]).(int)
+			if !ok {
+				return metadata, &MalformedRuleError{}
+			}
+			metadata.Priority = priority
+		case DescriptionToken:
+			if i+1 >= len(headerTokens) {
+				return metadata, &MalformedRuleError{}
+			}
+			i++
+			metadata.Description = unquoteHeader(headerTokens[i])
+		case TagsToken:
+			// Tags are a list like [a, b, c] which can span across tokens
+			if i+1 >= len(headerTokens) || !strings.HasPrefix(headerTokens[i+1], "[") {
+				return metadata, &MalformedRuleError{}
+			}
+			tagList := ""
+			for i++; i < len(headerTokens); i++ {
+				tagList += headerTokens[i] + " "
+				if strings.HasSuffix(headerTokens[i], "]") {
+					break
+				}
+			}
+			if !strings.HasSuffix(strings.TrimSpace(tagList), "]") {
+				return metadata, &MalformedRuleError{}
+			}
+			metadata.Tags = parseTagList(tagList)
+		default:
+			return metadata, &MalformedRuleError{}
+		}
+	}
+	return metadata, nil
+}
+
+// Position returns the offset of the input the parser has reached. After a
+// failed parse it points at the end of the offending token
+func (_p *RuleParser) Position() int {
+	return _p.currentIndex
+}
+
+// ValidateEOF validates that the whole input is parsed
+func (_p *RuleParser) ValidateEOF() error {
+	if curToken, err := _p.getNextToken(); err == nil {
+		return &SyntaxError{Expected: "end of input", Found: curToken, Index: _p.currentIndex}
+	}
+	return nil
+}
+
+// ParseRules parses a source holding either a single rule without header or
+// rules with RULE headers. The rule without header is named defaultName
+//
+// returns
+// RuleSet: Parsed rules in the order of definition
+// error: Error any found while parsing
+func (_p *RuleParser) ParseRules(defaultName string) (*RuleSet, error) {
+	rewindIndex := _p.currentIndex
+	curToken, err := _p.getNextToken()
+	_p.rewind(rewindIndex)
+	if err == nil && curToken == RuleToken {
+		return _p.ParseRuleSet()
+	}
+	rule, err := _p.ParseRule()
+	if err != nil {
+		return nil, err
+	}
+	if err = _p.ValidateEOF(); err != nil {
+		return nil, err
+	}
+	ruleSet := NewRuleSet()
+	if err = ruleSet.Add(defaultName, rule); err != nil {
+		return nil, err
+	}
+	return ruleSet, nil
+}
+
+// ParseRuleSet parses a source holding multiple named rules. Every rule must
+// start with a RULE header
+//
+// Format: RULE "name": RULE RULE "name": RULE ...
+//
+// returns
+// RuleSet: Parsed rules in the order of definition
+// error: Error any found while parsing
+func (_p *RuleParser) ParseRuleSet() (*RuleSet, error) {
+	ruleSet := NewRuleSet()
+	for {
+		rewindIndex := _p.currentIndex
+		curToken, err := _p.getNextToken()
+		if err != nil {
+			// End of file reached
+			return ruleSet, nil
+		}
+		if curToken != RuleToken {
+			return nil, &SyntaxError{Expected: RuleToken, Found: curToken, Index: _p.currentIndex}
+		}
+		_p.rewind(rewindIndex)
+		rule, err := _p.ParseRule()
+		if err != nil {
+			return nil, err
+		}
+		if err = ruleSet.AddRule(rule); err != nil {
+			return nil, err
+		}
+	}
 }
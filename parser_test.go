@@ -97,6 +97,13 @@ func TestParserSyntaxNoOpeningBracket(t *testing.T) {
 	assert.IsType(t, &SyntaxError{}, err)
 }
 
+func TestParserSyntaxMalformedForDefinition(t *testing.T) {
+	for _, text := range []string{"IF: { FOR: i { a == 1 } }", "IF: { FOR: i=0 { a == 1 } }", "FOR: =0:n IF: { a[i] == 1 }"} {
+		_, err := NewRuleParser(text).ParseRule()
+		assert.IsType(t, &SyntaxError{}, err, text)
+	}
+}
+
 func TestParserSyntaxUnsupportedRuleType(t *testing.T) {
 	fgParser := NewRuleParser("JUNK: ( )")
 	_, err := fgParser.ParseRule()
@@ -183,3 +190,10 @@ func TestVectorRuleScalarCondition(t *testing.T) {
 	assert.Nil(t, jsonErr)
 	t.Log(string(actJSONData))
 }
+
+func TestParseMissingOperand(t *testing.T) {
+	for _, src := range []string{"IF: { a == 1 && }", "IF: { && a == 1 }", "IF: { a == }", "IF: { ( a == 1 || ) && b == 2 }"} {
+		_, err := NewRuleParser(src).ParseRule()
+		assert.IsType(t, &MalformedRuleError{}, err, src)
+	}
+}
@@ -31,7 +31,12 @@ func StringToInterface(value string) interface{} {
 // Example if key = a.b and ipData =  { a : { b: 10 }}.
 // Then return value is int(10)
 func resolveValue(key string, ipData []byte) interface{} {
-	value := gjson.Get(string(ipData), key)
+	return resultToInterface(key, gjson.GetBytes(ipData, key))
+}
+
+// resultToInterface converts the value found for key to the actual type
+// wrapped in interface
+func resultToInterface(key string, value gjson.Result) interface{} {
 	if value.IsObject() {
 		// Must be only called for literals
 		panic("Expecting object but did not find")
@@ -56,7 +61,11 @@ func resolveValue(key string, ipData []byte) interface{} {
 // Example if key = a.size() and ipData is a : [{}, {}, {}]
 // Then return value is int(3)
 func resolveLength(key string, ipData []byte) interface{} {
-	value := gjson.Get(string(ipData), key)
+	return resultLength(gjson.GetBytes(ipData, key))
+}
+
+// resultLength returns the length of the array value
+func resultLength(value gjson.Result) int {
 	if !(value.IsArray()) {
 		panic("Expecting array but not found")
 	}
@@ -73,3 +82,51 @@ func hasArrayIndex(key string) bool {
 func getStringFromToken(token Token) string {
 	return string(token)
 }
+
+func isWhiteSpace(c byte) bool {
+	return c == ' ' || c == '\n' || c == '\t' || c == '\r'
+}
+
+// quotedStringEnd returns the index after the closing quote of the quoted string
+// starting at startIndex (or end of input if the quote is never closed)
+func quotedStringEnd(input string, startIndex int) int {
+	for i := startIndex + 1; i < len(input); i++ {
+		switch input[i] {
+		case '\\':
+			i++
+		case '"':
+			return i + 1
+		}
+	}
+	return len(input)
+}
+
+// unquote removes the surrounding quotes of a string literal
+func unquote(value string) string {
+	if len(value) >= 2 && strings.HasPrefix(value, "\"") && strings.HasSuffix(value, "\"") {
+		return value[1 : len(value)-1]
+	}
+	return value
+}
+
+// headerUnescaper resolves the escapes of the quoted strings of a rule header
+var headerUnescaper = strings.NewReplacer(`\\`, `\`, `\"`, `"`)
+
+// unquoteHeader returns the text of a quoted name or description of a rule
+// header (ex. "Say \"hi\"" is Say "hi")
+func unquoteHeader(value string) string {
+	return headerUnescaper.Replace(unquote(value))
+}
+
+// parseTagList parses a list like [a, b, c]
+func parseTagList(value string) []string {
+	var tags []string
+	value = strings.TrimSpace(value)
+	value = strings.TrimSuffix(strings.TrimPrefix(value, "["), "]")
+	for _, tag := range strings.Split(value, ",") {
+		if tag = unquote(strings.TrimSpace(tag)); tag != "" {
+			tags = append(tags, tag)
+		}
+	}
+	return tags
+}
@@ -0,0 +1,264 @@
+// File: partial.go
+// Implements the partial evaluation of the rules with only some of the fields known
+package gorule
+
+import (
+	"encoding/json"
+	"fmt"
+	"sort"
+	"strings"
+
+	"github.com/tidwall/gjson"
+)
+
+// InvalidKnownDataError is returned when the known data is not a JSON document
+type InvalidKnownDataError struct{}
+
+func (_rt *InvalidKnownDataError) Error() string {
+	return "Known data is not valid JSON"
+}
+
+// PartialEvaluation represents the outcome of evaluating a rule with only some
+// of its fields known (ex. the tenant before the event arrives)
+type PartialEvaluation struct {
+	// Decided is set when the verdict does not depend on the unknown fields
+	Decided bool `json:"decided"`
+	// Matched holds the verdict of a decided rule
+	Matched bool `json:"matched"`
+	// Residual is the rule with the known fields substituted and simplified. It
+	// evaluates as the rule for any event holding the known fields
+	Residual Rule `json:"-"`
+	// Unknown holds the paths the residual depends on (arrays of FOR by the
+	// path of the array, ex. items for items[i].price)
+	Unknown []string `json:"unknown"`
+}
+
+// partialEvaluator substitutes the known fields of the conditions
+type partialEvaluator struct {
+	doc  *Document
+	data []byte
+}
+
+// PartialEvaluate substitutes the fields of the rule found in knownData with
+// their value and simplifies the condition (see OptimizeCondition). Fields
+// which are null in knownData are unknown. A FOR is evaluated only if the
+// array and all the fields of its condition are known
+func PartialEvaluate(rule Rule, knownData []byte) (*PartialEvaluation, error) {
+	if !json.Valid(knownData) {
+		return nil, &InvalidKnownDataError{}
+	}
+	evaluator := &partialEvaluator{doc: ParseDocument(knownData), data: knownData}
+	evaluation := &PartialEvaluation{Residual: evaluator.rule(rule)}
+	evaluation.Decided, evaluation.Matched = evaluator.decide(evaluation.Residual)
+	unknown := make(map[string]bool)
+	evaluator.collectUnknown(evaluation.Residual, unknown)
+	evaluation.Unknown = []string{}
+	for path := range unknown {
+		evaluation.Unknown = append(evaluation.Unknown, path)
+	}
+	sort.Strings(evaluation.Unknown)
+	return evaluation, nil
+}
+
+// PartialEvaluateRuleSet returns the residual rules (see PartialEvaluate) of
+// the rules which may still match, ex. to pre-filter the rule set of a tenant.
+// Rules which always match are kept with a true condition
+func PartialEvaluateRuleSet(ruleSet *RuleSet, knownData []byte) (*RuleSet, error) {
+	residuals := NewRuleSet()
+	for _, name := range ruleSet.names {
+		evaluation, err := PartialEvaluate(ruleSet.rules[name], knownData)
+		if err != nil {
+			return nil, err
+		}
+		if evaluation.Decided && !evaluation.Matched {
+			continue
+		}
+		_ = residuals.Add(name, evaluation.Residual)
+	}
+	return residuals, nil
+}
+
+// decide returns the verdict of the residual rule if it does not depend on
+// the unknown fields. A vector rule is decided if its array and the fields of
+// its condition are known, or if its condition is false
+func (_pe *partialEvaluator) decide(residual Rule) (bool, bool) {
+	switch fgRule := residual.(type) {
+	case *CompiledRule:
+		return _pe.decide(fgRule.GetRule())
+	case *ScalarRule:
+		value, ok := literalBool(fgRule.If)
+		return ok, value
+	case *VectorRule:
+		condition := ruleCondition(fgRule.SRule)
+		if value, ok := literalBool(condition); ok && !value {
+			return true, false
+		}
+		if _pe.isArrayKnown(fgRule.EndIndex) && _pe.isKnown(condition) {
+			matched, err := evaluatePayload(fgRule, _pe.data)
+			return err == nil, matched
+		}
+	}
+	return false, false
+}
+
+func (_pe *partialEvaluator) rule(rule Rule) Rule {
+	switch fgRule := rule.(type) {
+	case *ScalarRule:
+		residual := *fgRule
+		residual.If = OptimizeCondition(_pe.condition(fgRule.If))
+		residual.Then = nil
+		for _, action := range fgRule.Then {
+			if assignAction, ok := action.(*AssignAction); ok {
+				residualAction := *assignAction
+				residualAction.Value = _pe.condition(assignAction.Value)
+				action = &residualAction
+			}
+			residual.Then = append(residual.Then, action)
+		}
+		return &residual
+	case *VectorRule:
+		residual := *fgRule
+		residual.SRule = _pe.rule(fgRule.SRule)
+		return &residual
+	case *CompiledRule:
+		compiled, err := CompileRule(_pe.rule(fgRule.GetRule()))
+		if err != nil {
+			return _pe.rule(fgRule.GetRule())
+		}
+		return compiled
+	}
+	return rule
+}
+
+// condition returns the condition with the known fields replaced by literals.
+// Fields indexed by a FOR (ex. a[i]) are left as they are
+func (_pe *partialEvaluator) condition(condition Condition) Condition {
+	switch cond := condition.(type) {
+	case *VectorCondition:
+		if _pe.isArrayKnown(cond.EndIndex) && _pe.isKnown(cond.SCondition) {
+			if value, ok := evaluateCondition(cond, _pe.doc).(bool); ok {
+				return newLiteral(value)
+			}
+		}
+		residual := *cond
+		residual.SCondition = _pe.condition(cond.SCondition)
+		return &residual
+	case *LogicalCondition:
+		operands := make([]Condition, len(cond.GetOperands()))
+		for i, operand := range cond.GetOperands() {
+			operands[i] = _pe.condition(operand)
+		}
+		return NewLogicalCondition(cond.GetOperator(), operands...)
+	case *ScalarCondition:
+		if cond.GetOperator() != NilOperator {
+			residual := *cond
+			residual.Operand1 = _pe.condition(cond.GetOperand1())
+			residual.Operand2 = _pe.condition(cond.GetOperand2())
+			return &residual
+		}
+		if path, ok := leafPath(cond); ok && !cond.HasArrayIndex {
+			if value, ok := _pe.value(path); ok {
+				return newLiteral(value)
+			}
+		}
+	}
+	return condition
+}
+
+// value returns the known value of the field as the engine resolves it
+func (_pe *partialEvaluator) value(path string) (interface{}, bool) {
+	result := _pe.doc.Get(path)
+	if !result.Exists() || result.Type == gjson.Null || result.IsObject() || result.IsArray() {
+		return nil, false
+	}
+	return _pe.doc.resolveValue(path), true
+}
+
+// isArrayKnown checks if the array of a FOR range (ex. a.size()) is known
+func (_pe *partialEvaluator) isArrayKnown(endIndex interface{}) bool {
+	path, ok := strings.CutSuffix(fmt.Sprint(endIndex), ".size()")
+	return ok && _pe.doc.Get(path).IsArray()
+}
+
+// isKnown checks if all the fields of the condition are known. A field indexed
+// by a FOR is known if its array is
+func (_pe *partialEvaluator) isKnown(condition Condition) bool {
+	switch cond := condition.(type) {
+	case *VectorCondition:
+		return _pe.isArrayKnown(cond.EndIndex) && _pe.isKnown(cond.SCondition)
+	case *LogicalCondition:
+		for _, operand := range cond.GetOperands() {
+			if !_pe.isKnown(operand) {
+				return false
+			}
+		}
+		return true
+	case *ScalarCondition:
+		if cond.GetOperator() != NilOperator {
+			return _pe.isKnown(cond.GetOperand1()) && _pe.isKnown(cond.GetOperand2())
+		}
+		path, ok := leafPath(cond)
+		if !ok {
+			return true
+		}
+		if cond.HasArrayIndex {
+			return _pe.doc.Get(arrayPath(path)).IsArray()
+		}
+		_, known := _pe.value(path)
+		return known
+	}
+	return false
+}
+
+// collectUnknown adds the unknown paths of the residual rule
+func (_pe *partialEvaluator) collectUnknown(rule Rule, unknown map[string]bool) {
+	switch fgRule := rule.(type) {
+	case *ScalarRule:
+		_pe.collectUnknownFields(fgRule.If, unknown)
+		for _, action := range fgRule.Then {
+			if assignAction, ok := action.(*AssignAction); ok {
+				_pe.collectUnknownFields(assignAction.Value, unknown)
+			}
+		}
+	case *VectorRule:
+		if !_pe.isArrayKnown(fgRule.EndIndex) {
+			unknown[strings.TrimSuffix(fmt.Sprint(fgRule.EndIndex), ".size()")] = true
+		}
+		_pe.collectUnknown(fgRule.SRule, unknown)
+	case *CompiledRule:
+		_pe.collectUnknown(fgRule.GetRule(), unknown)
+	}
+}
+
+func (_pe *partialEvaluator) collectUnknownFields(condition Condition, unknown map[string]bool) {
+	switch cond := condition.(type) {
+	case *VectorCondition:
+		if !_pe.isArrayKnown(cond.EndIndex) {
+			unknown[strings.TrimSuffix(fmt.Sprint(cond.EndIndex), ".size()")] = true
+		}
+		_pe.collectUnknownFields(cond.SCondition, unknown)
+	case *LogicalCondition:
+		for _, operand := range cond.GetOperands() {
+			_pe.collectUnknownFields(operand, unknown)
+		}
+	case *ScalarCondition:
+		if cond.GetOperator() != NilOperator {
+			_pe.collectUnknownFields(cond.GetOperand1(), unknown)
+			_pe.collectUnknownFields(cond.GetOperand2(), unknown)
+			return
+		}
+		// Fields indexed by a FOR are unknown through their array
+		if path, ok := leafPath(cond); ok && !cond.HasArrayIndex {
+			unknown[path] = true
+		}
+	}
+}
+
+// arrayPath returns the path of the array of a field indexed by a FOR (ex. a
+// for a[i].b)
+func arrayPath(path string) string {
+	if open := strings.Index(path, "["); open >= 0 {
+		return path[:open]
+	}
+	return path
+}
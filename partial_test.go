@@ -0,0 +1,84 @@
+// File: partial_test.go
+// Tests for the partial evaluation of the rules
+package gorule
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestPartialEvaluate(t *testing.T) {
+	tests := []struct {
+		src      string
+		known    string
+		decided  bool
+		matched  bool
+		residual string
+		unknown  []string
+	}{
+		{`IF: { tenant == "acme" && amount > 100 }`, `{"tenant": "acme"}`, false, false, "IF: { amount > 100 }", []string{"amount"}},
+		{`IF: { tenant == "acme" && amount > 100 }`, `{"tenant": "other"}`, true, false, "IF: { false }", []string{}},
+		{`IF: { amount > 100 && tenant == "acme" }`, `{"tenant": "other"}`, true, false, "IF: { false }", []string{}},
+		{`IF: { tenant == "acme" || amount > 100 }`, `{"tenant": "acme", "amount": 5}`, true, true, "IF: { true }", []string{}},
+		{`IF: { ( country == "IN" || country == "US" ) && ( amount > limit || vip == true ) }`, `{"country": "IN", "limit": 500}`, false, false, "IF: { amount > 500 || vip == true }", []string{"amount", "vip"}},
+		{`IF: { tenant == "acme" && amount > 100 }`, `{"tenant": null}`, false, false, `IF: { tenant == "acme" && amount > 100 }`, []string{"amount", "tenant"}},
+		{`IF: { tenant == "acme" } THEN: { limit = base }`, `{"tenant": "acme"}`, true, true, "IF: { true } THEN: { limit = base }", []string{"base"}},
+		{`IF: { FOR: i=0:items.size() { items[i].price > min } }`, `{"min": 10}`, false, false, "IF: { FOR: i=0:items.size() { items[i].price > 10 } }", []string{"items"}},
+		{`IF: { FOR: i=0:items.size() { items[i].price > min } }`, `{"min": 10, "items": [{"price": 5}]}`, true, false, "IF: { false }", []string{}},
+		{`FOR: i=0:items.size() IF: { items[i].price > min && tenant == "acme" }`, `{"tenant": "acme"}`, false, false, "FOR: i=0:items.size() IF: { items[i].price > min }", []string{"items", "min"}},
+		{`FOR: i=0:items.size() IF: { items[i].price > min && tenant == "acme" }`, `{"tenant": "other"}`, true, false, "FOR: i=0:items.size() IF: { false }", []string{"items"}},
+		{`FOR: i=0:items.size() IF: { items[i].price > min }`, `{"min": 10, "items": [{"price": 5}, {"price": 20}]}`, true, true, "FOR: i=0:items.size() IF: { items[i].price > 10 }", []string{}},
+	}
+	for _, test := range tests {
+		rule, err := NewRuleParser(test.src).ParseRule()
+		assert.Nil(t, err, test.src)
+		evaluation, err := PartialEvaluate(rule, []byte(test.known))
+		assert.Nil(t, err, test.src)
+		assert.Equal(t, test.decided, evaluation.Decided, "%s with %s", test.src, test.known)
+		assert.Equal(t, test.matched, evaluation.Matched, "%s with %s", test.src, test.known)
+		assert.Equal(t, test.residual, Format(evaluation.Residual), "%s with %s", test.src, test.known)
+		assert.Equal(t, test.unknown, evaluation.Unknown, "%s with %s", test.src, test.known)
+	}
+}
+
+func TestPartialEvaluateResidual(t *testing.T) {
+	// The residual evaluates as the rule on the events holding the known fields
+	src := `IF: { ( country == "IN" || country == "US" ) && ( amount > limit || vip == true ) }`
+	rule, err := NewRuleParser(src).ParseRule()
+	assert.Nil(t, err)
+	compiled, err := CompileRule(rule)
+	assert.Nil(t, err)
+	evaluation, err := PartialEvaluate(compiled, []byte(`{"country": "IN", "limit": 500}`))
+	assert.Nil(t, err)
+	assert.IsType(t, &CompiledRule{}, evaluation.Residual)
+	engine := NewRuleEngine()
+	for _, payload := range []string{
+		`{"country": "IN", "limit": 500, "amount": 600, "vip": false}`,
+		`{"country": "IN", "limit": 500, "amount": 400, "vip": false}`,
+		`{"country": "IN", "limit": 500, "amount": 400, "vip": true}`,
+	} {
+		expected, err := engine.Evaluate(rule, []byte(payload))
+		assert.Nil(t, err)
+		result, err := engine.Evaluate(evaluation.Residual, []byte(payload))
+		assert.Nil(t, err)
+		assert.Equal(t, expected, result, payload)
+	}
+
+	_, err = PartialEvaluate(rule, []byte(`{"country": `))
+	assert.IsType(t, &InvalidKnownDataError{}, err)
+}
+
+func TestPartialEvaluateRuleSet(t *testing.T) {
+	ruleSet, err := NewRuleParser(`
+		RULE "acme_large": IF: { tenant == "acme" && amount > 1000 }
+		RULE "other_large": IF: { tenant == "other" && amount > 1000 }
+		RULE "acme_all": IF: { tenant == "acme" }
+		RULE "any_small": IF: { amount < 10 }`).ParseRuleSet()
+	assert.Nil(t, err)
+	residuals, err := PartialEvaluateRuleSet(ruleSet, []byte(`{"tenant": "acme"}`))
+	assert.Nil(t, err)
+	assert.Equal(t, []string{"acme_large", "acme_all", "any_small"}, residuals.Names())
+	assert.Equal(t, "RULE \"acme_large\":\n\tIF: { amount > 1000 }\nRULE \"acme_all\":\n\tIF: { true }\nRULE \"any_small\":\n\tIF: { amount < 10 }\n",
+		FormatRuleSet(residuals))
+}
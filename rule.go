@@ -2,8 +2,6 @@
 // Implement the rule interface
 package gorule
 
-import "strings"
-
 // RuleType represents types of rule
 type RuleType int
 
@@ -14,20 +12,61 @@ const (
 	VectorRuleType RuleType = 2
 )
 
+// RuleMetadata represents the information of the rule header
+// ex. RULE "high_value_cc" PRIORITY 10 TAGS [fraud, cc] DESCRIPTION "High value card":
+type RuleMetadata struct {
+	Name        string   `json:"name,omitempty"`
+	Description string   `json:"description,omitempty"`
+	Priority    int      `json:"priority,omitempty"`
+	Tags        []string `json:"tags,omitempty"`
+}
+
+// GetName returns the stable identifier of the rule
+func (_m *RuleMetadata) GetName() string {
+	return _m.Name
+}
+
+// GetDescription returns the description of the rule
+func (_m *RuleMetadata) GetDescription() string {
+	return _m.Description
+}
+
+// GetPriority returns the priority (salience) of the rule
+func (_m *RuleMetadata) GetPriority() int {
+	return _m.Priority
+}
+
+// GetTags returns the tags of the rule
+func (_m *RuleMetadata) GetTags() []string {
+	return _m.Tags
+}
+
+// HasTag checks if the rule is tagged with tag
+func (_m *RuleMetadata) HasTag(tag string) bool {
+	for _, ruleTag := range _m.Tags {
+		if ruleTag == tag {
+			return true
+		}
+	}
+	return false
+}
+
 // Rule represent the common interface for any rule
 type Rule interface {
 	GetType() RuleType
+	GetMetadata() *RuleMetadata
 	Evaluate(ctx Context) (interface{}, error)
 	BuildContext(ipData []byte, ctx Context) error
 }
 
 // ScalarRule represents a structure of the If rule (created by parsing the user provided rules)
 type ScalarRule struct {
-	Type       RuleType    `json:"type"`
-	If         Condition   `json:"if"`
-	Then       Action      `json:"then"`
-	StartIndex interface{} `json:"start_index"`
-	IndexKey   interface{} `json:"index_key"`
+	Type       RuleType     `json:"type"`
+	If         Condition    `json:"if"`
+	Then       ActionList   `json:"then"`
+	StartIndex interface{}  `json:"start_index"`
+	IndexKey   interface{}  `json:"index_key"`
+	Metadata   RuleMetadata `json:"metadata"`
 }
 
 // Evaluate evalates the rule
@@ -43,31 +82,39 @@ func (_fgr *ScalarRule) GetType() RuleType {
 	return _fgr.Type
 }
 
+// GetMetadata gets the header information of the rule
+func (_fgr *ScalarRule) GetMetadata() *RuleMetadata {
+	return &_fgr.Metadata
+}
+
 func (_fgr *ScalarRule) getCondition() Condition {
 	return _fgr.If
 }
 
-// BuildContext builds the context
+// BuildContext attaches the input data to the context. Field values are
+// resolved lazily (and memoized) while the condition is evaluated
 func (_fgr *ScalarRule) BuildContext(ipData []byte, ctx Context) error {
-	_fgr.getCondition().buildContext(ipData, ctx)
-	// TODO: Above condition can return errors
+	ctx.SetValue(InputDataKey, ParseDocument(ipData))
 	return nil
 }
 
 // VectorRule represents a collection of Simple rules
 type VectorRule struct {
-	Type       RuleType    `json:"type"`
-	SRule      Rule        `json:"scalar_rule"`
-	StartIndex interface{} `json:"start_index"`
-	EndIndex   interface{} `json:"end_index"`
-	IndexKey   interface{} `json:"index_key"`
+	Type       RuleType     `json:"type"`
+	SRule      Rule         `json:"scalar_rule"`
+	StartIndex interface{}  `json:"start_index"`
+	EndIndex   interface{}  `json:"end_index"`
+	IndexKey   interface{}  `json:"index_key"`
+	Metadata   RuleMetadata `json:"metadata"`
 }
 
 // Evaluate evalates the rule
 func (_fgr *VectorRule) Evaluate(ctx Context) (interface{}, error) {
 	var result []bool
+	startIndex := _fgr.getInitialValue(ctx)
+	endIndex := _fgr.getFinalValue(ctx)
 	ctx.SetValue(IndexKey, _fgr.IndexKey)
-	for i := ctx.GetValue(StartIndexValue).(int); i < ctx.GetValue(EndIndexValue).(int); i++ {
+	for i := startIndex; i < endIndex; i++ {
 		var res interface{}
 		var err error
 		scalarRule := _fgr.SRule.(*ScalarRule)
@@ -86,32 +133,25 @@ func (_fgr *VectorRule) GetType() RuleType {
 	return _fgr.Type
 }
 
+// GetMetadata gets the header information of the rule
+func (_fgr *VectorRule) GetMetadata() *RuleMetadata {
+	return &_fgr.Metadata
+}
+
 // Evaluate string like "0"
-func (_fgr *VectorRule) getInitialValue(ipData []byte) int {
+func (_fgr *VectorRule) getInitialValue(ctx Context) int {
 	value := StringToInterface(_fgr.StartIndex.(string)).(int)
 	return value
 }
 
 // Evaluate string like a.size() => len(a)
-func (_fgr *VectorRule) getFinalValue(ipData []byte) int {
-	value := resolveLength(strings.Replace(_fgr.EndIndex.(string), ".size()", "", 1), ipData).(int)
-	return value
+func (_fgr *VectorRule) getFinalValue(ctx Context) int {
+	return resolveContextLength(ctx, _fgr.EndIndex.(string))
 }
 
-// BuildContext builds the context
+// BuildContext attaches the input data to the context. Field values are
+// resolved lazily (and memoized) while the rule is evaluated
 func (_fgr *VectorRule) BuildContext(ipData []byte, ctx Context) error {
-	startIndex := _fgr.getInitialValue(ipData)
-	ctx.SetValue(StartIndexValue, startIndex)
-	endIndex := _fgr.getFinalValue(ipData)
-	ctx.SetValue(EndIndexValue, endIndex)
-	// Set this IndexKey = "i"
-	ctx.SetValue(IndexKey, _fgr.IndexKey)
-	// For every value of "i", fetch the value
-	for i := startIndex; i < endIndex; i++ {
-		// Set this "[i] = 0"
-		ctx.SetValue(IndexCurrentValue, i)
-		scalarRule := _fgr.SRule.(*ScalarRule)
-		scalarRule.BuildContext(ipData, ctx)
-	}
+	ctx.SetValue(InputDataKey, ParseDocument(ipData))
 	return nil
 }
@@ -0,0 +1,125 @@
+// File: ruleset.go
+// Implements a collection of named rules evaluated together
+package gorule
+
+import "fmt"
+
+// DuplicateRuleError raised when a rule with the same name is already present
+type DuplicateRuleError struct {
+	Name string
+}
+
+func (_rt *DuplicateRuleError) Error() string {
+	return fmt.Sprintf("Duplicate rule : %s", _rt.Name)
+}
+
+// RuleNameMismatchError raised when a rule is added under a name other than
+// the one of its header
+type RuleNameMismatchError struct {
+	Name     string
+	RuleName string
+}
+
+func (_rt *RuleNameMismatchError) Error() string {
+	return fmt.Sprintf("Rule %s is named %q in its header", _rt.Name, _rt.RuleName)
+}
+
+// RuleEvaluationError raised when a rule of a rule set fails to evaluate
+type RuleEvaluationError struct {
+	Name string
+	Err  error
+}
+
+func (_rt *RuleEvaluationError) Error() string {
+	return fmt.Sprintf("Rule %s failed : %s", _rt.Name, _rt.Err)
+}
+
+// Unwrap returns the underlying error
+func (_rt *RuleEvaluationError) Unwrap() error {
+	return _rt.Err
+}
+
+// RuleSet represents a collection of named rules
+type RuleSet struct {
+	names []string
+	rules map[string]Rule
+}
+
+// NewRuleSet returns a fresh empty rule set
+func NewRuleSet() *RuleSet {
+	return &RuleSet{rules: make(map[string]Rule)}
+}
+
+// Add adds a named rule to the rule set. A rule whose header has another name
+// is rejected. A rule without header is stored as a copy carrying the name, the
+// rule passed in is left untouched so it can be added to other sets
+func (_rs *RuleSet) Add(name string, rule Rule) error {
+	if _, ok := _rs.rules[name]; ok {
+		return &DuplicateRuleError{Name: name}
+	}
+	if ruleName := rule.GetMetadata().GetName(); ruleName == "" {
+		rule = namedRule(rule, name)
+	} else if ruleName != name {
+		return &RuleNameMismatchError{Name: name, RuleName: ruleName}
+	}
+	_rs.names = append(_rs.names, name)
+	_rs.rules[name] = rule
+	return nil
+}
+
+// namedRule returns a shallow copy of the rule with the name in its header
+func namedRule(rule Rule, name string) Rule {
+	switch fgRule := rule.(type) {
+	case *ScalarRule:
+		named := *fgRule
+		named.Metadata.Name = name
+		return &named
+	case *VectorRule:
+		named := *fgRule
+		named.Metadata.Name = name
+		return &named
+	case *CompiledRule:
+		named := *fgRule
+		named.rule = namedRule(fgRule.rule, name)
+		return &named
+	}
+	return rule
+}
+
+// AddRule adds a rule to the rule set using the name of its header
+func (_rs *RuleSet) AddRule(rule Rule) error {
+	name := rule.GetMetadata().GetName()
+	if name == "" {
+		return &MalformedRuleError{}
+	}
+	return _rs.Add(name, rule)
+}
+
+// Get returns the rule with the given name
+func (_rs *RuleSet) Get(name string) (Rule, bool) {
+	rule, ok := _rs.rules[name]
+	return rule, ok
+}
+
+// Names returns the names of the rules in the order they were added
+func (_rs *RuleSet) Names() []string {
+	names := make([]string, len(_rs.names))
+	copy(names, _rs.names)
+	return names
+}
+
+// Len returns the number of rules in the rule set
+func (_rs *RuleSet) Len() int {
+	return len(_rs.names)
+}
+
+// FilterByTag returns a rule set holding only the rules tagged with tag
+func (_rs *RuleSet) FilterByTag(tag string) *RuleSet {
+	filtered := NewRuleSet()
+	for _, name := range _rs.names {
+		if rule := _rs.rules[name]; rule.GetMetadata().HasTag(tag) {
+			_ = filtered.Add(name, rule)
+		}
+	}
+	return filtered
+}
@@ -0,0 +1,141 @@
+// File: ruleset_test.go
+// Tests for rule set
+package gorule
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+const testRuleSetSource = `
+RULE "high_value":
+	IF: { amount >= 10000 } THEN: { }
+RULE "credit_card":
+	IF: { type == "CREDIT_CARD" }
+RULE "all_valid":
+	IF: { FOR: i=0:attributes.size() { attributes[i].type == "VALID" } } THEN: { }
+RULE "per_attribute":
+	FOR: i=0:attributes.size() IF: { attributes[i].type == "VALID" }
+`
+
+func TestParseRuleSet(t *testing.T) {
+	ruleSet, err := NewRuleParser(testRuleSetSource).ParseRuleSet()
+	assert.Nil(t, err)
+	assert.Equal(t, 4, ruleSet.Len())
+	assert.Equal(t, []string{"high_value", "credit_card", "all_valid", "per_attribute"}, ruleSet.Names())
+	rule, ok := ruleSet.Get("per_attribute")
+	assert.True(t, ok)
+	assert.Equal(t, VectorRuleType, rule.GetType())
+	_, ok = ruleSet.Get("missing")
+	assert.False(t, ok)
+}
+
+func TestParseRuleSetDuplicateName(t *testing.T) {
+	_, err := NewRuleParser("RULE \"a\": IF: { a == 1 } RULE \"a\": IF: { a == 2 }").ParseRuleSet()
+	assert.IsType(t, &DuplicateRuleError{}, err)
+}
+
+func TestRuleSetAddName(t *testing.T) {
+	rule, err := NewRuleParser("IF: { a == 1 }").ParseRule()
+	assert.Nil(t, err)
+	// A rule without header is stored under the name, the rule itself is kept
+	// as is so it can be added to another set under another name
+	ruleSet := NewRuleSet()
+	assert.Nil(t, ruleSet.Add("a", rule))
+	assert.Equal(t, "", rule.GetMetadata().GetName())
+	added, _ := ruleSet.Get("a")
+	assert.Equal(t, "a", added.GetMetadata().GetName())
+	assert.Nil(t, NewRuleSet().Add("other", rule))
+
+	named, err := NewRuleParser("RULE \"b\": IF: { b == 1 }").ParseRule()
+	assert.Nil(t, err)
+	assert.IsType(t, &RuleNameMismatchError{}, ruleSet.Add("c", named))
+	assert.Equal(t, []string{"a"}, ruleSet.Names())
+	assert.Nil(t, ruleSet.Add("b", named))
+	added, _ = ruleSet.Get("b")
+	assert.Same(t, named, added)
+}
+
+func TestParseRuleSetMissingHeader(t *testing.T) {
+	_, err := NewRuleParser("IF: { a == 1 }").ParseRuleSet()
+	assert.IsType(t, &SyntaxError{}, err)
+	_, err = NewRuleParser("RULE IF: { a == 1 }").ParseRuleSet()
+	assert.NotNil(t, err)
+}
+
+func TestEngineEvaluateRuleSet(t *testing.T) {
+	ruleSet, err := NewRuleParser(testRuleSetSource).ParseRuleSet()
+	assert.Nil(t, err)
+	testdata := []byte(`
+	{
+		"amount": 10000,
+		"type": "CREDIT_CARD",
+		"attributes": [
+			{ "type": "VALID" },
+			{ "type": "FRAUD" }
+		]
+	}
+	`)
+	results, err := NewRuleEngine().EvaluateRuleSet(ruleSet, testdata)
+	assert.Nil(t, err)
+	assert.Equal(t, []bool{true}, results["high_value"])
+	assert.Equal(t, []bool{true}, results["credit_card"])
+	assert.Equal(t, []bool{false}, results["all_valid"])
+	assert.Equal(t, []bool{true, false}, results["per_attribute"])
+}
+
+func TestParseRuleHeader(t *testing.T) {
+	rule, err := NewRuleParser("RULE \"high_value_cc\" PRIORITY 10 TAGS [fraud, cc] DESCRIPTION \"High value credit card\": IF: { amount >= 10000 && type == \"CREDIT_CARD\" }").ParseRule()
+	assert.Nil(t, err)
+	metadata := rule.GetMetadata()
+	assert.Equal(t, "high_value_cc", metadata.GetName())
+	assert.Equal(t, 10, metadata.GetPriority())
+	assert.Equal(t, []string{"fraud", "cc"}, metadata.GetTags())
+	assert.Equal(t, "High value credit card", metadata.GetDescription())
+	assert.True(t, metadata.HasTag("fraud"))
+	assert.False(t, metadata.HasTag("pricing"))
+}
+
+func TestParseRuleHeaderVectorRule(t *testing.T) {
+	rule, err := NewRuleParser("RULE \"per_txn\" TAGS [fraud]: FOR: i=0:txns.size() IF: { txns[i].amount > 10 }").ParseRule()
+	assert.Nil(t, err)
+	assert.Equal(t, VectorRuleType, rule.GetType())
+	assert.Equal(t, "per_txn", rule.GetMetadata().GetName())
+	assert.Equal(t, []string{"fraud"}, rule.GetMetadata().GetTags())
+}
+
+func TestParseRuleWithoutHeader(t *testing.T) {
+	rule, err := NewRuleParser("IF: { a == 1 }").ParseRule()
+	assert.Nil(t, err)
+	assert.Equal(t, "", rule.GetMetadata().GetName())
+}
+
+func TestParseRuleHeaderMalformed(t *testing.T) {
+	_, err := NewRuleParser("RULE \"a\" PRIORITY high: IF: { a == 1 }").ParseRule()
+	assert.IsType(t, &MalformedRuleError{}, err)
+	_, err = NewRuleParser("RULE \"a\" TAGS [a, b: IF: { a == 1 }").ParseRule()
+	assert.IsType(t, &MalformedRuleError{}, err)
+	_, err = NewRuleParser("RULE \"a\" IF: { a == 1 }").ParseRule()
+	assert.IsType(t, &SyntaxError{}, err)
+}
+
+func TestQuotedStringWithSpaces(t *testing.T) {
+	rule, err := NewRuleParser("IF: { type == \"CREDIT CARD\" }").ParseRule()
+	assert.Nil(t, err)
+	result, err := NewRuleEngine().Evaluate(rule, []byte(`{ "type": "CREDIT CARD" }`))
+	assert.Nil(t, err)
+	assert.Equal(t, []bool{true}, result)
+}
+
+func TestRuleSetFilterByTag(t *testing.T) {
+	ruleSet, err := NewRuleParser(`
+	RULE "a" TAGS [fraud, cc]: IF: { a == 1 }
+	RULE "b" TAGS [pricing]: IF: { b == 1 }
+	RULE "c" TAGS [fraud]: IF: { c == 1 }
+	`).ParseRuleSet()
+	assert.Nil(t, err)
+	assert.Equal(t, []string{"a", "c"}, ruleSet.FilterByTag("fraud").Names())
+	assert.Equal(t, []string{"b"}, ruleSet.FilterByTag("pricing").Names())
+	assert.Equal(t, 0, ruleSet.FilterByTag("missing").Len())
+}
@@ -0,0 +1,292 @@
+// File: ruletest.go
+// Implements declarative test fixtures of rules (YAML or JSON)
+package gorule
+
+import (
+	"encoding/json"
+	"fmt"
+	"os"
+	"path/filepath"
+	"reflect"
+	"sort"
+	"strings"
+
+	"gopkg.in/yaml.v3"
+)
+
+// TestSuite represents a test fixture: a rule file and the cases run against it.
+// JSON fixtures use the same keys
+//
+//	rules: transaction.rules   # relative to the fixture
+//	strategy: all              # first, all (default), priority or action
+//	cases:
+//	  - name: high value credit card
+//	    input: { amount: 20000, type: CREDIT_CARD }
+//	    expect:
+//	      matched: [high_value_cc]
+//	      facts: { risk: HIGH }
+type TestSuite struct {
+	Rules    string     `yaml:"rules"`
+	Strategy string     `yaml:"strategy,omitempty"`
+	Cases    []TestCase `yaml:"cases"`
+	// Cover records the coverage of the conditions over the inputs of the cases
+	Cover bool `yaml:"-"`
+	// path of the fixture, the files of the suite are relative to it
+	path string
+}
+
+// TestCase represents one input payload and the expected verdict
+type TestCase struct {
+	Name string `yaml:"name"`
+	// Input is the payload, either a mapping or a JSON string
+	Input interface{} `yaml:"input,omitempty"`
+	// InputFile is a JSON file holding the payload (relative to the fixture)
+	InputFile string          `yaml:"input_file,omitempty"`
+	Expect    TestExpectation `yaml:"expect"`
+}
+
+// TestExpectation represents the expected verdict of a case. Only the given
+// expectations are checked
+type TestExpectation struct {
+	// Match checks if any rule matched
+	Match *bool `yaml:"match,omitempty"`
+	// Matched holds exactly the winning rules (in any order)
+	Matched []string `yaml:"matched,omitempty"`
+	// NotMatched holds the rules which must not win
+	NotMatched []string `yaml:"not_matched,omitempty"`
+	// Facts holds the facts asserted by forward chaining the actions of the
+	// matching rules (see Infer). Other facts are ignored
+	Facts map[string]interface{} `yaml:"facts,omitempty"`
+}
+
+// TestCaseResult represents the outcome of a case
+type TestCaseResult struct {
+	Name     string                 `json:"name"`
+	Passed   bool                   `json:"passed"`
+	Failures []string               `json:"failures,omitempty"`
+	Winners  []string               `json:"winners"`
+	Facts    map[string]interface{} `json:"facts,omitempty"`
+}
+
+// TestReport represents the outcome of all the cases of a suite
+type TestReport struct {
+	Suite   string           `json:"suite"`
+	Results []TestCaseResult `json:"results"`
+	// Coverage is set if the suite is run with Cover
+	Coverage *CoverageReport `json:"coverage,omitempty"`
+}
+
+// TestSuiteError raised when the fixture or the files it refers to are invalid
+type TestSuiteError struct {
+	Path string
+	Err  error
+}
+
+func (_rt *TestSuiteError) Error() string {
+	return fmt.Sprintf("%s: %s", _rt.Path, _rt.Err)
+}
+
+func (_rt *TestSuiteError) Unwrap() error {
+	return _rt.Err
+}
+
+// LoadTestSuite reads the fixture at path (YAML or JSON)
+func LoadTestSuite(path string) (*TestSuite, error) {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return nil, &TestSuiteError{Path: path, Err: err}
+	}
+	suite := &TestSuite{path: path}
+	// JSON is a subset of YAML, a single decoder handles both
+	if err = yaml.Unmarshal(data, suite); err != nil {
+		return nil, &TestSuiteError{Path: path, Err: err}
+	}
+	if suite.Rules == "" {
+		return nil, &TestSuiteError{Path: path, Err: fmt.Errorf("rules is not set")}
+	}
+	return suite, nil
+}
+
+// Run runs all the cases of the suite. Errors in the cases (ex. invalid input)
+// are reported as failures of the case
+func (_ts *TestSuite) Run() (*TestReport, error) {
+	strategyName := _ts.Strategy
+	if strategyName == "" {
+		strategyName = "all"
+	}
+	strategy, err := ParseConflictStrategy(strategyName)
+	if err != nil {
+		return nil, &TestSuiteError{Path: _ts.path, Err: err}
+	}
+	rulesPath := _ts.resolvePath(_ts.Rules)
+	source, err := os.ReadFile(rulesPath)
+	if err != nil {
+		return nil, &TestSuiteError{Path: _ts.path, Err: err}
+	}
+	name := strings.TrimSuffix(filepath.Base(rulesPath), filepath.Ext(rulesPath))
+	ruleSet, err := NewRuleParser(string(source)).ParseRules(name)
+	if err != nil {
+		return nil, &TestSuiteError{Path: rulesPath, Err: err}
+	}
+	report := &TestReport{Suite: _ts.path}
+	var coverage *Coverage
+	if _ts.Cover {
+		coverage = NewCoverage(ruleSet)
+	}
+	for i, testCase := range _ts.Cases {
+		result := _ts.runCase(ruleSet, strategy, testCase, coverage)
+		if result.Name == "" {
+			result.Name = fmt.Sprintf("case %d", i+1)
+		}
+		report.Results = append(report.Results, result)
+	}
+	if coverage != nil {
+		report.Coverage = coverage.Report()
+	}
+	return report, nil
+}
+
+func (_ts *TestSuite) resolvePath(path string) string {
+	if filepath.IsAbs(path) {
+		return path
+	}
+	return filepath.Join(filepath.Dir(_ts.path), path)
+}
+
+// runCase evaluates the rules for the input of the case and checks the
+// expectations. A panic of the engine fails the case
+func (_ts *TestSuite) runCase(ruleSet *RuleSet, strategy ConflictStrategy, testCase TestCase, coverage *Coverage) TestCaseResult {
+	result := TestCaseResult{Name: testCase.Name, Winners: []string{}}
+	if err := SafeEvaluate(func() error {
+		_ts.checkCase(ruleSet, strategy, testCase, coverage, &result)
+		return nil
+	}); err != nil {
+		result.Failures = append(result.Failures, fmt.Sprintf("evaluation failed: %v", err))
+	}
+	result.Passed = len(result.Failures) == 0
+	return result
+}
+
+// checkCase evaluates the rules for the input of the case and records the
+// failed expectations in the result
+func (_ts *TestSuite) checkCase(ruleSet *RuleSet, strategy ConflictStrategy, testCase TestCase, coverage *Coverage, result *TestCaseResult) {
+	input, err := _ts.caseInput(testCase)
+	if err != nil {
+		result.Failures = append(result.Failures, err.Error())
+		return
+	}
+	engine := NewRuleEngine()
+	doc := ParseDocument(input)
+	if coverage != nil {
+		if err = coverage.RecordDocument(doc); err != nil {
+			result.Failures = append(result.Failures, err.Error())
+			return
+		}
+	}
+	ruleSetResult, err := engine.EvaluateRuleSetDocumentWithStrategy(ruleSet, doc, strategy)
+	if err != nil {
+		result.Failures = append(result.Failures, err.Error())
+		return
+	}
+	result.Winners = ruleSetResult.Winners
+	expect := testCase.Expect
+	if expect.Match != nil && *expect.Match != (len(result.Winners) > 0) {
+		result.Failures = append(result.Failures, fmt.Sprintf("match: expected %t got %t (winners %v)", *expect.Match, !*expect.Match, result.Winners))
+	}
+	if expect.Matched != nil && !sameNames(expect.Matched, result.Winners) {
+		result.Failures = append(result.Failures, fmt.Sprintf("matched: expected %v got %v", expect.Matched, result.Winners))
+	}
+	for _, name := range expect.NotMatched {
+		for _, winner := range result.Winners {
+			if name == winner {
+				result.Failures = append(result.Failures, fmt.Sprintf("not_matched: %s matched", name))
+			}
+		}
+	}
+	if len(expect.Facts) > 0 {
+		inference, err := engine.InferDocument(ruleSet, doc, DefaultMaxIterations)
+		if err != nil {
+			result.Failures = append(result.Failures, err.Error())
+			return
+		}
+		result.Facts = inference.Facts
+		for _, path := range sortedKeys(expect.Facts) {
+			value, ok := inference.Facts[path]
+			switch {
+			case !ok:
+				result.Failures = append(result.Failures, fmt.Sprintf("facts: %s expected %v but not asserted", path, expect.Facts[path]))
+			case !sameValue(expect.Facts[path], value):
+				result.Failures = append(result.Failures, fmt.Sprintf("facts: %s expected %v got %v", path, expect.Facts[path], value))
+			}
+		}
+	}
+}
+
+// caseInput returns the JSON payload of the case
+func (_ts *TestSuite) caseInput(testCase TestCase) ([]byte, error) {
+	if testCase.InputFile != "" {
+		return os.ReadFile(_ts.resolvePath(testCase.InputFile))
+	}
+	if text, ok := testCase.Input.(string); ok {
+		if !json.Valid([]byte(text)) {
+			return nil, fmt.Errorf("input is not valid JSON")
+		}
+		return []byte(text), nil
+	}
+	if testCase.Input == nil {
+		return []byte("{}"), nil
+	}
+	return json.Marshal(testCase.Input)
+}
+
+// sameNames checks if both hold the same names in any order
+func sameNames(expected []string, actual []string) bool {
+	if len(expected) != len(actual) {
+		return false
+	}
+	sortedExpected := append([]string(nil), expected...)
+	sortedActual := append([]string(nil), actual...)
+	sort.Strings(sortedExpected)
+	sort.Strings(sortedActual)
+	return reflect.DeepEqual(sortedExpected, sortedActual)
+}
+
+// sameValue compares the value of the fixture with the value of a fact. String
+// facts hold the quotes of the literal and numbers compare by value
+func sameValue(expected interface{}, actual interface{}) bool {
+	normalize := func(value interface{}) interface{} {
+		switch v := value.(type) {
+		case string:
+			return unquote(v)
+		case int:
+			return float64(v)
+		}
+		return value
+	}
+	return reflect.DeepEqual(normalize(expected), normalize(actual))
+}
+
+func sortedKeys(values map[string]interface{}) []string {
+	keys := make([]string, 0, len(values))
+	for key := range values {
+		keys = append(keys, key)
+	}
+	sort.Strings(keys)
+	return keys
+}
+
+// Passed checks if all the cases passed
+func (_tr *TestReport) Passed() bool {
+	return _tr.Failed() == 0
+}
+
+// Failed returns the number of failed cases
+func (_tr *TestReport) Failed() int {
+	failed := 0
+	for _, result := range _tr.Results {
+		if !result.Passed {
+			failed++
+		}
+	}
+	return failed
+}
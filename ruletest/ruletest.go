@@ -0,0 +1,51 @@
+// File: ruletest.go
+// Runs the rule test fixtures inside go test
+package ruletest
+
+import (
+	"path/filepath"
+	"testing"
+
+	"github.com/praks-1529/gorule"
+)
+
+// Run runs the fixtures matching the patterns (ex. testdata/*.yaml) as sub
+// tests, one per case
+//
+//	func TestRules(t *testing.T) {
+//		ruletest.Run(t, "testdata/*.yaml")
+//	}
+func Run(t *testing.T, patterns ...string) {
+	t.Helper()
+	var paths []string
+	for _, pattern := range patterns {
+		matches, err := filepath.Glob(pattern)
+		if err != nil {
+			t.Fatalf("%s: %s", pattern, err)
+		}
+		paths = append(paths, matches...)
+	}
+	if len(paths) == 0 {
+		t.Fatalf("no fixture found for %v", patterns)
+	}
+	for _, path := range paths {
+		suite, err := gorule.LoadTestSuite(path)
+		if err != nil {
+			t.Error(err)
+			continue
+		}
+		report, err := suite.Run()
+		if err != nil {
+			t.Error(err)
+			continue
+		}
+		for _, result := range report.Results {
+			result := result
+			t.Run(filepath.Base(path)+"/"+result.Name, func(t *testing.T) {
+				for _, failure := range result.Failures {
+					t.Error(failure)
+				}
+			})
+		}
+	}
+}
@@ -0,0 +1,25 @@
+// File: ruletest_test.go
+package ruletest
+
+import (
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestRun(t *testing.T) {
+	dir := t.TempDir()
+	assert.Nil(t, os.WriteFile(filepath.Join(dir, "txn.rules"), []byte(`RULE "credit_card":
+	IF: { type == "CREDIT_CARD" }
+`), 0o644))
+	assert.Nil(t, os.WriteFile(filepath.Join(dir, "txn_test.yaml"), []byte(`
+rules: txn.rules
+cases:
+  - name: credit card
+    input: { amount: 10, type: CREDIT_CARD }
+    expect: { matched: [credit_card] }
+`), 0o644))
+	Run(t, filepath.Join(dir, "*_test.yaml"))
+}
@@ -0,0 +1,82 @@
+// File: ruletest_test.go
+package gorule
+
+import (
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+const ruleTestRules = `RULE "high_value" PRIORITY 10:
+	IF: { amount >= 10000 } THEN: { risk = "HIGH" ; score = 90 }
+RULE "credit_card":
+	IF: { type == "CREDIT_CARD" }
+`
+
+func writeTestSuite(t *testing.T, name string, suite string) string {
+	dir := t.TempDir()
+	assert.Nil(t, os.WriteFile(filepath.Join(dir, "txn.rules"), []byte(ruleTestRules), 0o644))
+	assert.Nil(t, os.WriteFile(filepath.Join(dir, "payload.json"), []byte(`{ "amount": 500, "type": "CREDIT_CARD" }`), 0o644))
+	path := filepath.Join(dir, name)
+	assert.Nil(t, os.WriteFile(path, []byte(suite), 0o644))
+	return path
+}
+
+func TestRunTestSuite(t *testing.T) {
+	path := writeTestSuite(t, "txn.yaml", `
+rules: txn.rules
+strategy: first
+cases:
+  - name: passes
+    input: { amount: 20000, type: CREDIT_CARD }
+    expect: { match: true, matched: [high_value], facts: { risk: HIGH, score: 90 } }
+  - name: fails
+    input_file: payload.json
+    expect: { match: false, matched: [high_value], not_matched: [credit_card], facts: { risk: LOW } }
+  - input: '{ "amount": 500'
+    expect: { match: false }
+`)
+	suite, err := LoadTestSuite(path)
+	assert.Nil(t, err)
+	report, err := suite.Run()
+	assert.Nil(t, err)
+	assert.False(t, report.Passed())
+	assert.Equal(t, 2, report.Failed())
+	assert.Equal(t, TestCaseResult{Name: "passes", Passed: true, Winners: []string{"high_value"},
+		Facts: map[string]interface{}{"risk": "\"HIGH\"", "score": 90}}, report.Results[0])
+	assert.Equal(t, []string{
+		"match: expected false got true (winners [credit_card])",
+		"matched: expected [high_value] got [credit_card]",
+		"not_matched: credit_card matched",
+		"facts: risk expected LOW but not asserted",
+	}, report.Results[1].Failures)
+	assert.Equal(t, "case 3", report.Results[2].Name)
+	assert.Equal(t, []string{"input is not valid JSON"}, report.Results[2].Failures)
+}
+
+func TestRunTestSuiteJSON(t *testing.T) {
+	path := writeTestSuite(t, "txn.json", `{ "rules": "txn.rules", "cases": [
+		{ "name": "all matches", "input": { "amount": 20000, "type": "CREDIT_CARD" }, "expect": { "matched": [ "credit_card", "high_value" ] } } ] }`)
+	suite, err := LoadTestSuite(path)
+	assert.Nil(t, err)
+	report, err := suite.Run()
+	assert.Nil(t, err)
+	assert.True(t, report.Passed())
+}
+
+func TestRunTestSuiteErrors(t *testing.T) {
+	_, err := LoadTestSuite(writeTestSuite(t, "no_rules.yaml", "cases: []"))
+	assert.IsType(t, &TestSuiteError{}, err)
+
+	suite, err := LoadTestSuite(writeTestSuite(t, "missing.yaml", "rules: missing.rules"))
+	assert.Nil(t, err)
+	_, err = suite.Run()
+	assert.IsType(t, &TestSuiteError{}, err)
+
+	suite, err = LoadTestSuite(writeTestSuite(t, "strategy.yaml", "rules: txn.rules\nstrategy: best"))
+	assert.Nil(t, err)
+	_, err = suite.Run()
+	assert.IsType(t, &UnknownStrategyError{}, err.(*TestSuiteError).Err)
+}
@@ -0,0 +1,493 @@
+// File: schema.go
+// Implements static type checking of the rules against a JSON Schema of the payload
+package gorule
+
+import (
+	"encoding/json"
+	"fmt"
+	"sort"
+	"strconv"
+	"strings"
+)
+
+// Schema represents the subset of JSON Schema used to type check the rules:
+// type, properties, additionalProperties, items, anyOf, oneOf and local $ref
+// (#/definitions/x, #/$defs/x)
+type Schema struct {
+	Type                 interface{}        `json:"type,omitempty"`
+	Properties           map[string]*Schema `json:"properties,omitempty"`
+	AdditionalProperties interface{}        `json:"additionalProperties,omitempty"`
+	Items                *Schema            `json:"items,omitempty"`
+	AnyOf                []*Schema          `json:"anyOf,omitempty"`
+	OneOf                []*Schema          `json:"oneOf,omitempty"`
+	Ref                  string             `json:"$ref,omitempty"`
+	Definitions          map[string]*Schema `json:"definitions,omitempty"`
+	Defs                 map[string]*Schema `json:"$defs,omitempty"`
+	// root is the schema the references are resolved against
+	root *Schema
+}
+
+// SchemaError raised when the schema can not be used for type checking
+type SchemaError struct {
+	Reason string
+}
+
+func (_rt *SchemaError) Error() string {
+	return fmt.Sprintf("Invalid schema : %s", _rt.Reason)
+}
+
+// ParseSchema parses the JSON Schema of the payload
+func ParseSchema(data []byte) (*Schema, error) {
+	schema := &Schema{}
+	if err := json.Unmarshal(data, schema); err != nil {
+		return nil, &SchemaError{Reason: err.Error()}
+	}
+	schema.root = schema
+	return schema, nil
+}
+
+// TypeErrorSeverity represents how certain a type error is
+type TypeErrorSeverity string
+
+const (
+	// ErrorSeverity represents a rule which fails (or never matches) for any
+	// payload valid against the schema
+	ErrorSeverity TypeErrorSeverity = "error"
+	// WarningSeverity represents a rule which fails for some of the payloads
+	// (ex. a number field compared with an integer fails for 10.5)
+	WarningSeverity TypeErrorSeverity = "warning"
+)
+
+// TypeError represents a problem found while type checking a rule
+type TypeError struct {
+	Rule       string            `json:"rule,omitempty"`
+	Severity   TypeErrorSeverity `json:"severity"`
+	Expression string            `json:"expression"`
+	Reason     string            `json:"reason"`
+	// Offset is the offset in the source (-1 if unknown, ex. no source map)
+	Offset int `json:"offset"`
+}
+
+func (_rt *TypeError) Error() string {
+	return fmt.Sprintf("Type %s : %s in %s", _rt.Severity, _rt.Reason, _rt.Expression)
+}
+
+// valueType represents the set of the types a value can have at runtime
+type valueType uint8
+
+const (
+	stringType valueType = 1 << iota
+	integerType
+	floatType
+	booleanType
+	objectType
+	arrayType
+	nullType
+	numberType = integerType | floatType
+	anyType    = stringType | numberType | booleanType | objectType | arrayType | nullType
+)
+
+var valueTypeNames = []struct {
+	valueType valueType
+	name      string
+}{{stringType, "string"}, {numberType, "number"}, {integerType, "integer"}, {floatType, "float"},
+	{booleanType, "boolean"}, {objectType, "object"}, {arrayType, "array"}, {nullType, "null"}}
+
+func (_t valueType) String() string {
+	var names []string
+	remaining := _t
+	for _, typeName := range valueTypeNames {
+		if remaining&typeName.valueType == typeName.valueType {
+			names = append(names, typeName.name)
+			remaining &^= typeName.valueType
+		}
+	}
+	return strings.Join(names, "|")
+}
+
+// schemaTypes maps the JSON Schema types to the runtime types. JSON numbers are
+// int or float64 depending on the value
+var schemaTypes = map[string]valueType{
+	"string": stringType, "integer": integerType, "number": numberType, "boolean": booleanType,
+	"object": objectType, "array": arrayType, "null": nullType,
+}
+
+// resolve returns the alternatives of the schema with the references followed
+func (_s *Schema) resolve(root *Schema) []*Schema {
+	if _s == nil {
+		return []*Schema{nil}
+	}
+	if _s.Ref != "" {
+		return root.lookupRef(_s.Ref).resolve(root)
+	}
+	alternatives := make([]*Schema, 0, len(_s.AnyOf)+len(_s.OneOf))
+	alternatives = append(append(alternatives, _s.AnyOf...), _s.OneOf...)
+	if len(alternatives) == 0 {
+		return []*Schema{_s}
+	}
+	var resolved []*Schema
+	for _, alternative := range alternatives {
+		resolved = append(resolved, alternative.resolve(root)...)
+	}
+	return resolved
+}
+
+// lookupRef returns the schema of a local reference. Unknown references are
+// any value (nil)
+func (_s *Schema) lookupRef(ref string) *Schema {
+	for prefix, definitions := range map[string]map[string]*Schema{"#/definitions/": _s.Definitions, "#/$defs/": _s.Defs} {
+		if name := strings.TrimPrefix(ref, prefix); name != ref {
+			return definitions[name]
+		}
+	}
+	return nil
+}
+
+// types returns the runtime types of the schema. A schema without type is any
+// value
+func (_s *Schema) types() valueType {
+	if _s == nil {
+		return anyType
+	}
+	switch schemaType := _s.Type.(type) {
+	case string:
+		return schemaTypes[schemaType]
+	case []interface{}:
+		var types valueType
+		for _, name := range schemaType {
+			if typeName, ok := name.(string); ok {
+				types |= schemaTypes[typeName]
+			}
+		}
+		return types
+	}
+	switch {
+	case _s.Properties != nil:
+		return objectType
+	case _s.Items != nil:
+		return arrayType
+	}
+	return anyType
+}
+
+// child returns the schema of the property (or the array element if the part
+// is an index). Returns false if the property is not allowed
+func (_s *Schema) child(part string, isIndex bool) (*Schema, bool) {
+	if _s == nil {
+		return nil, true
+	}
+	types := _s.types()
+	if isIndex {
+		if types&arrayType == 0 {
+			return nil, false
+		}
+		return _s.Items, true
+	}
+	if types&objectType == 0 {
+		return nil, false
+	}
+	if property, ok := _s.Properties[part]; ok {
+		return property, true
+	}
+	switch additional := _s.AdditionalProperties.(type) {
+	case bool:
+		return nil, additional
+	case map[string]interface{}:
+		data, _ := json.Marshal(additional)
+		additionalSchema := &Schema{}
+		json.Unmarshal(data, additionalSchema)
+		return additionalSchema, true
+	}
+	// Unlike JSON Schema, the properties of an object declaring its properties
+	// are closed unless additionalProperties is set, so typos are reported
+	return nil, _s.Properties == nil
+}
+
+// pathParts splits a path like a.b[i].c (or a.b.0.c) into the parts, marking
+// the array indexes
+func pathParts(path string) ([]string, []bool) {
+	var parts []string
+	var isIndex []bool
+	for _, part := range strings.Split(path, ".") {
+		name := part
+		if bracket := strings.Index(part, "["); bracket >= 0 {
+			name = part[:bracket]
+		}
+		if name != "" {
+			_, err := strconv.Atoi(name)
+			parts = append(parts, name)
+			isIndex = append(isIndex, err == nil)
+		}
+		for i := strings.Count(part, "["); i > 0; i-- {
+			parts = append(parts, "[]")
+			isIndex = append(isIndex, true)
+		}
+	}
+	return parts, isIndex
+}
+
+// typeChecker checks the rules of a rule set against the schema
+type typeChecker struct {
+	schema *Schema
+	// root is the schema the references are resolved against
+	root      *Schema
+	sourceMap SourceMap
+	// facts holds the types of the facts asserted by the actions
+	facts  map[string]valueType
+	rule   string
+	errors []*TypeError
+}
+
+// CheckSchema type checks the rule against the schema of the payload. Pass the
+// source map of the parser (RuleParser.SourceMap) to get the offsets of the
+// errors
+func CheckSchema(rule Rule, schema *Schema, sourceMap SourceMap) []*TypeError {
+	ruleSet := NewRuleSet()
+	ruleSet.Add(rule.GetMetadata().GetName(), rule)
+	return CheckRuleSetSchema(ruleSet, schema, sourceMap)
+}
+
+// CheckRuleSetSchema type checks all the rules of the rule set. Facts asserted
+// by the actions of any rule are known paths
+func CheckRuleSetSchema(ruleSet *RuleSet, schema *Schema, sourceMap SourceMap) []*TypeError {
+	root := schema.root
+	if root == nil {
+		// Built in code instead of ParseSchema
+		root = schema
+	}
+	checker := &typeChecker{schema: schema, root: root, sourceMap: sourceMap, facts: make(map[string]valueType)}
+	for _, name := range ruleSet.names {
+		checker.collectFacts(ruleSet.rules[name])
+	}
+	for _, name := range ruleSet.names {
+		checker.rule = name
+		checker.checkRule(ruleSet.rules[name])
+	}
+	sort.SliceStable(checker.errors, func(i, j int) bool {
+		return checker.errors[i].Offset < checker.errors[j].Offset
+	})
+	return checker.errors
+}
+
+func (_tc *typeChecker) report(node interface{}, severity TypeErrorSeverity, expression string, reason string, args ...interface{}) {
+	offset, ok := _tc.sourceMap[node]
+	if !ok {
+		offset = -1
+	}
+	_tc.errors = append(_tc.errors, &TypeError{Rule: _tc.rule, Severity: severity, Expression: expression,
+		Reason: fmt.Sprintf(reason, args...), Offset: offset})
+}
+
+// collectFacts records the types of the facts asserted by the rule
+func (_tc *typeChecker) collectFacts(rule Rule) {
+	switch fgRule := rule.(type) {
+	case *CompiledRule:
+		_tc.collectFacts(fgRule.GetRule())
+	case *VectorRule:
+		_tc.collectFacts(fgRule.SRule)
+	case *ScalarRule:
+		for _, action := range fgRule.Then {
+			if assignAction, ok := action.(*AssignAction); ok {
+				_tc.facts[assignAction.Path] |= actionType(assignAction.Value)
+			}
+		}
+	}
+}
+
+// actionType returns the type of the value of an action. Arithmetic yields a
+// number, paths are any value
+func actionType(value Condition) valueType {
+	if cond, ok := value.(*ScalarCondition); ok && isArithmetic(cond.GetOperator()) {
+		return numberType
+	}
+	return literalType(value.GetValue())
+}
+
+// literalType returns the type of a literal. Paths are any value
+func literalType(value interface{}) valueType {
+	switch v := value.(type) {
+	case int:
+		return integerType
+	case float64:
+		return floatType
+	case bool:
+		return booleanType
+	case string:
+		if strings.HasPrefix(v, "\"") {
+			return stringType
+		}
+	}
+	return anyType
+}
+
+func (_tc *typeChecker) checkRule(rule Rule) {
+	switch fgRule := rule.(type) {
+	case *CompiledRule:
+		_tc.checkRule(fgRule.GetRule())
+	case *VectorRule:
+		_tc.checkRange(fgRule, fgRule.StartIndex, fgRule.EndIndex)
+		_tc.checkRule(fgRule.SRule)
+	case *ScalarRule:
+		_tc.checkCondition(fgRule.If)
+		for _, action := range fgRule.Then {
+			if assignAction, ok := action.(*AssignAction); ok {
+				_tc.checkCondition(assignAction.Value)
+			}
+		}
+	}
+}
+
+// checkRange checks the range of a FOR loop i.e. the start is an integer and
+// the end is the size of an array (ex. a.size())
+func (_tc *typeChecker) checkRange(node interface{}, startIndex interface{}, endIndex interface{}) {
+	expression := fmt.Sprintf("%v:%v", startIndex, endIndex)
+	if _, ok := StringToInterface(fmt.Sprint(startIndex)).(int); !ok {
+		_tc.report(node, ErrorSeverity, expression, "start index %v is not an integer", startIndex)
+	}
+	path, ok := strings.CutSuffix(fmt.Sprint(endIndex), ".size()")
+	if !ok {
+		_tc.report(node, ErrorSeverity, expression, "end index %v is not a size (ex. a.size())", endIndex)
+		return
+	}
+	types, found := _tc.pathType(path)
+	switch {
+	case !found:
+		_tc.report(node, ErrorSeverity, expression, "unknown path %s", path)
+	case types&arrayType == 0:
+		_tc.report(node, ErrorSeverity, expression, "size() of %s which is %s, expecting array", path, types)
+	}
+}
+
+// checkCondition checks the condition and returns the types of its value
+func (_tc *typeChecker) checkCondition(condition Condition) valueType {
+	switch cond := condition.(type) {
+	case *VectorCondition:
+		_tc.checkRange(cond, cond.StartIndex, cond.EndIndex)
+		_tc.checkCondition(cond.SCondition)
+		return booleanType
+	case *ScalarCondition:
+		if cond.GetOperator() == NilOperator {
+			return _tc.checkLeaf(cond)
+		}
+		types1 := _tc.checkCondition(cond.GetOperand1())
+		types2 := _tc.checkCondition(cond.GetOperand2())
+		if isArithmetic(cond.GetOperator()) {
+			return _tc.checkArithmetic(cond, types1, types2)
+		}
+		_tc.checkOperation(cond, types1, types2)
+		return booleanType
+	case *LogicalCondition:
+		for _, operand := range cond.GetOperands() {
+			_tc.checkCondition(operand)
+		}
+		return booleanType
+	}
+	return anyType
+}
+
+// checkLeaf returns the types of a literal or a field
+func (_tc *typeChecker) checkLeaf(cond *ScalarCondition) valueType {
+	path, ok := cond.GetValue().(string)
+	if !ok || strings.HasPrefix(path, "\"") {
+		return literalType(cond.GetValue())
+	}
+	if isGJSONPath(path) {
+		// Result of gjson queries is not known statically
+		return anyType
+	}
+	if types, ok := _tc.facts[path]; ok {
+		return types
+	}
+	types, found := _tc.pathType(path)
+	if !found {
+		_tc.report(cond, ErrorSeverity, path, "unknown path %s", path)
+		return anyType
+	}
+	return types
+}
+
+// pathType returns the types of the field at path. Returns false if the path
+// is not allowed by the schema
+func (_tc *typeChecker) pathType(path string) (valueType, bool) {
+	if types, ok := _tc.facts[path]; ok {
+		return types, true
+	}
+	schemas := _tc.schema.resolve(_tc.root)
+	parts, isIndex := pathParts(path)
+	for i, part := range parts {
+		var children []*Schema
+		for _, schema := range schemas {
+			if child, ok := schema.child(part, isIndex[i]); ok {
+				children = append(children, child.resolve(_tc.root)...)
+			}
+		}
+		if len(children) == 0 {
+			return 0, false
+		}
+		schemas = children
+	}
+	var types valueType
+	for _, schema := range schemas {
+		types |= schema.types()
+	}
+	return types, true
+}
+
+// checkArithmetic checks the operands of an arithmetic operator are numbers
+// and returns the type of the result
+func (_tc *typeChecker) checkArithmetic(cond *ScalarCondition, types1 valueType, types2 valueType) valueType {
+	for _, types := range []valueType{types1, types2} {
+		if types&numberType == 0 {
+			_tc.report(cond, ErrorSeverity, FormatCondition(cond), "operand of %s is %s, expecting number", cond.GetOperator(), types)
+			return numberType
+		}
+	}
+	number1, number2 := types1&numberType, types2&numberType
+	switch {
+	case cond.GetOperator() == DivideOperator || number1 == floatType || number2 == floatType:
+		return floatType
+	case number1 == integerType && number2 == integerType:
+		return integerType
+	}
+	return numberType
+}
+
+// checkOperation checks the types of the operands of the operator
+func (_tc *typeChecker) checkOperation(cond *ScalarCondition, types1 valueType, types2 valueType) {
+	optor := cond.GetOperator()
+	expression := FormatCondition(cond)
+	if optor == AndOperator || optor == OrOperator {
+		for _, types := range []valueType{types1, types2} {
+			if types&booleanType == 0 {
+				_tc.report(cond, ErrorSeverity, expression, "operand of %s is %s, expecting boolean", optor, types)
+			}
+		}
+		return
+	}
+	// Missing (null) values are not type errors of the rule
+	if types1 != nullType {
+		types1 &^= nullType
+	}
+	if types2 != nullType {
+		types2 &^= nullType
+	}
+	for _, types := range []valueType{types1, types2} {
+		if types&(objectType|arrayType|nullType) == types {
+			_tc.report(cond, ErrorSeverity, expression, "can not compare %s", types)
+			return
+		}
+	}
+	if types1&types2 == 0 {
+		_tc.report(cond, ErrorSeverity, expression, "comparing %s with %s", types1, types2)
+		return
+	}
+	if optor != EqualOperator {
+		if common := types1 & types2; common&numberType == 0 {
+			_tc.report(cond, ErrorSeverity, expression, "%s is not supported on %s (always false)", optor, common)
+			return
+		}
+	}
+	if known := anyType &^ nullType; types1 != known && types2 != known && types1 != types2 {
+		_tc.report(cond, WarningSeverity, expression, "comparing %s with %s fails when the types differ at runtime", types1, types2)
+	}
+}
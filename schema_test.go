@@ -0,0 +1,106 @@
+// File: schema_test.go
+package gorule
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+const paymentSchema = `{
+	"type": "object",
+	"properties": {
+		"amount": { "type": "number" },
+		"count": { "type": "integer" },
+		"type": { "type": "string" },
+		"card": { "$ref": "#/definitions/card" },
+		"items": { "type": "array", "items": { "type": "object", "properties": { "price": { "type": "integer" } } } },
+		"tags": { "type": "object", "additionalProperties": { "type": "string" } }
+	},
+	"definitions": {
+		"card": { "type": "object", "properties": { "expired": { "type": "boolean" } } }
+	}
+}`
+
+func checkSchema(t *testing.T, src string) ([]*TypeError, string) {
+	schema, err := ParseSchema([]byte(paymentSchema))
+	assert.Nil(t, err)
+	parser := NewRuleParser(src)
+	ruleSet, err := parser.ParseRules("rule")
+	assert.Nil(t, err)
+	return CheckRuleSetSchema(ruleSet, schema, parser.SourceMap()), src
+}
+
+func TestCheckSchemaValid(t *testing.T) {
+	errors, _ := checkSchema(t, `RULE "a":
+	IF: { count > 100 && type == "CC" && card.expired == false && tags.x == "y" } THEN: { risk = "HIGH" }
+RULE "b":
+	FOR: i=0:items.size() IF: { items[i].price > 1 && risk == "HIGH" && count >= 2 }`)
+	assert.Empty(t, errors)
+}
+
+func TestCheckSchemaErrors(t *testing.T) {
+	errors, src := checkSchema(t, `RULE "a":
+	IF: { amont > 1 && type > "A" && card.expired == 1 }
+RULE "b":
+	IF: { FOR: i=0:type.size() { type == "x" } }`)
+	assert.Equal(t, 4, len(errors))
+	assert.Equal(t, "a", errors[0].Rule)
+	assert.Equal(t, "unknown path amont", errors[0].Reason)
+	assert.Equal(t, "amont", src[errors[0].Offset:errors[0].Offset+5])
+	assert.Equal(t, "> is not supported on string (always false)", errors[1].Reason)
+	assert.Equal(t, "type", src[errors[1].Offset:errors[1].Offset+4])
+	assert.Equal(t, "comparing boolean with integer", errors[2].Reason)
+	assert.Equal(t, ErrorSeverity, errors[2].Severity)
+	assert.Equal(t, "size() of type which is string, expecting array", errors[3].Reason)
+	assert.Equal(t, "type.size()", src[errors[3].Offset:errors[3].Offset+11])
+}
+
+func TestCheckSchemaNumbers(t *testing.T) {
+	errors, _ := checkSchema(t, `IF: { amount > 100 }`)
+	assert.Equal(t, 1, len(errors))
+	assert.Equal(t, WarningSeverity, errors[0].Severity)
+	assert.Equal(t, "Type warning : comparing number with integer fails when the types differ at runtime in amount > 100", errors[0].Error())
+}
+
+func TestCheckSchemaArithmetic(t *testing.T) {
+	errors, src := checkSchema(t, `RULE "a":
+	IF: { count > 1 } THEN: { score = count * 2 + type ; fee = count / 2 }
+RULE "b":
+	IF: { fee > 1 && score > 1.5 }`)
+	assert.Equal(t, 3, len(errors))
+	assert.Equal(t, "operand of + is string, expecting number", errors[0].Reason)
+	assert.Equal(t, "count * 2 + type", src[errors[0].Offset:errors[0].Offset+16])
+	// Facts assigned by arithmetic are numbers
+	assert.Equal(t, "b", errors[1].Rule)
+	assert.Equal(t, "comparing number with integer fails when the types differ at runtime", errors[1].Reason)
+	assert.Equal(t, "fee > 1", errors[1].Expression)
+	assert.Equal(t, "score > 1.5", errors[2].Expression)
+}
+
+func TestCheckSchemaWithoutSourceMap(t *testing.T) {
+	schema, err := ParseSchema([]byte(paymentSchema))
+	assert.Nil(t, err)
+	rule, err := NewRuleParser(`IF: { card.number == 1 }`).ParseRule()
+	assert.Nil(t, err)
+	errors := CheckSchema(rule, schema, nil)
+	assert.Equal(t, 1, len(errors))
+	assert.Equal(t, -1, errors[0].Offset)
+
+	_, err = ParseSchema([]byte(`{ "type": `))
+	assert.IsType(t, &SchemaError{}, err)
+}
+
+func TestCheckSchemaBuiltInCode(t *testing.T) {
+	card := &Schema{Type: "object", Properties: map[string]*Schema{"expired": {Type: "boolean"}}}
+	anyOf := make([]*Schema, 1, 2)
+	anyOf[0] = &Schema{Type: "object", Properties: map[string]*Schema{"card": card}}
+	schema := &Schema{AnyOf: anyOf, OneOf: []*Schema{{Type: "null"}}}
+	rule, err := NewRuleParser(`IF: { card.expired == "no" }`).ParseRule()
+	assert.Nil(t, err)
+	errors := CheckSchema(rule, schema, nil)
+	assert.Equal(t, 1, len(errors))
+	// The schema of the caller is left untouched
+	assert.Nil(t, schema.root)
+	assert.Nil(t, anyOf[:2][1])
+}
@@ -0,0 +1,466 @@
+// File: serialize.go
+// Implements the versioned JSON format of the parsed rules
+package gorule
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"strconv"
+	"strings"
+)
+
+// ASTVersion is the version of the JSON format of the parsed rules
+const ASTVersion = 1
+
+const (
+	intValueType    = "int"
+	floatValueType  = "float"
+	stringValueType = "string"
+	boolValueType   = "bool"
+)
+
+// astOperators are the operators of the conditions (and of the action values)
+// a rule JSON can hold
+var astOperators = map[Operator]bool{
+	NilOperator: true, AndOperator: true, OrOperator: true, EqualOperator: true, GreaterOperator: true,
+	GreaterThanOrEqualOperator: true, LesserOperator: true, LesserThanOrEqualOperator: true,
+	AddOperator: true, SubtractOperator: true, MultiplyOperator: true, DivideOperator: true,
+}
+
+// ASTError raised when the JSON of a rule is malformed
+type ASTError struct {
+	Message string
+}
+
+func (_rt *ASTError) Error() string {
+	return fmt.Sprintf("Malformed rule JSON : %s", _rt.Message)
+}
+
+// ruleDocument represents the versioned envelope of a rule
+type ruleDocument struct {
+	Version int             `json:"version"`
+	Rule    json.RawMessage `json:"rule,omitempty"`
+	Rules   json.RawMessage `json:"rules,omitempty"`
+}
+
+// MarshalRule returns the versioned JSON of the rule
+// Format: { "version": 1, "rule": { ... } }
+func MarshalRule(rule Rule) ([]byte, error) {
+	ruleJSON, err := json.Marshal(rule)
+	if err != nil {
+		return nil, err
+	}
+	return json.Marshal(&ruleDocument{Version: ASTVersion, Rule: ruleJSON})
+}
+
+// UnmarshalRule parses the versioned JSON of a rule
+func UnmarshalRule(data []byte) (Rule, error) {
+	document, err := unmarshalRuleDocument(data)
+	if err != nil {
+		return nil, err
+	}
+	if document.Rule == nil {
+		return nil, &ASTError{Message: "rule not found"}
+	}
+	return unmarshalRuleNode(document.Rule)
+}
+
+// MarshalRuleSet returns the versioned JSON of the rules of the rule set in the
+// order they were added. Every rule carries its name in the metadata
+// Format: { "version": 1, "rules": [ { ... }, ... ] }
+func MarshalRuleSet(ruleSet *RuleSet) ([]byte, error) {
+	var rules []json.RawMessage
+	for _, name := range ruleSet.names {
+		rule := ruleSet.rules[name]
+		if rule.GetMetadata().GetName() != name {
+			// Rule was added with a name different from its header
+			return nil, &ASTError{Message: fmt.Sprintf("rule %s is named %q in its header", name, rule.GetMetadata().GetName())}
+		}
+		ruleJSON, err := json.Marshal(rule)
+		if err != nil {
+			return nil, err
+		}
+		rules = append(rules, ruleJSON)
+	}
+	rulesJSON, err := json.Marshal(rules)
+	if err != nil {
+		return nil, err
+	}
+	return json.Marshal(&ruleDocument{Version: ASTVersion, Rules: rulesJSON})
+}
+
+// UnmarshalRuleSet parses the versioned JSON of a rule set
+func UnmarshalRuleSet(data []byte) (*RuleSet, error) {
+	document, err := unmarshalRuleDocument(data)
+	if err != nil {
+		return nil, err
+	}
+	var rules []json.RawMessage
+	if err = json.Unmarshal(document.Rules, &rules); err != nil {
+		return nil, &ASTError{Message: err.Error()}
+	}
+	ruleSet := NewRuleSet()
+	for _, ruleJSON := range rules {
+		rule, err := unmarshalRuleNode(ruleJSON)
+		if err != nil {
+			return nil, err
+		}
+		if err = ruleSet.AddRule(rule); err != nil {
+			return nil, err
+		}
+	}
+	return ruleSet, nil
+}
+
+func unmarshalRuleDocument(data []byte) (*ruleDocument, error) {
+	document := &ruleDocument{}
+	if err := json.Unmarshal(data, document); err != nil {
+		return nil, &ASTError{Message: err.Error()}
+	}
+	if document.Version < 1 || document.Version > ASTVersion {
+		return nil, &ASTError{Message: fmt.Sprintf("unsupported version %d", document.Version)}
+	}
+	return document, nil
+}
+
+// readNodeType reads the "type" of a node
+func readNodeType(data []byte) (int, error) {
+	var node struct {
+		Type int `json:"type"`
+	}
+	if err := json.Unmarshal(data, &node); err != nil {
+		return 0, &ASTError{Message: err.Error()}
+	}
+	return node.Type, nil
+}
+
+func isNull(data json.RawMessage) bool {
+	return len(data) == 0 || string(data) == "null"
+}
+
+func unmarshalRuleNode(data json.RawMessage) (Rule, error) {
+	nodeType, err := readNodeType(data)
+	if err != nil {
+		return nil, err
+	}
+	var rule Rule
+	switch RuleType(nodeType) {
+	case ScalarRuleType:
+		rule = &ScalarRule{}
+	case VectorRuleType:
+		rule = &VectorRule{}
+	default:
+		return nil, &ASTError{Message: fmt.Sprintf("unknown rule type %d", nodeType)}
+	}
+	if err = json.Unmarshal(data, rule); err != nil {
+		return nil, wrapASTError(err)
+	}
+	return rule, nil
+}
+
+func unmarshalConditionNode(data json.RawMessage) (Condition, error) {
+	if isNull(data) {
+		return nil, nil
+	}
+	nodeType, err := readNodeType(data)
+	if err != nil {
+		return nil, err
+	}
+	var condition Condition
+	switch ConditionType(nodeType) {
+	case ScalarConditionType:
+		condition = &ScalarCondition{}
+	case VectorConditionType:
+		condition = &VectorCondition{}
+	case LogicalConditionType:
+		condition = &LogicalCondition{}
+	default:
+		return nil, &ASTError{Message: fmt.Sprintf("unknown condition type %d", nodeType)}
+	}
+	if err = json.Unmarshal(data, condition); err != nil {
+		return nil, wrapASTError(err)
+	}
+	return condition, nil
+}
+
+func unmarshalActionNode(data json.RawMessage) (Action, error) {
+	nodeType, err := readNodeType(data)
+	if err != nil {
+		return nil, err
+	}
+	switch ActionType(nodeType) {
+	case AssignActionType:
+		action := &AssignAction{}
+		if err = json.Unmarshal(data, action); err != nil {
+			return nil, wrapASTError(err)
+		}
+		return action, nil
+	}
+	return nil, &ASTError{Message: fmt.Sprintf("unknown action type %d", nodeType)}
+}
+
+// unmarshalIndexes returns the bounds of a vector node as the parser builds
+// them: an integer start (a JSON integer is accepted) and the length of an
+// array as end (ex. "0", "a.size()")
+func unmarshalIndexes(startIndex interface{}, endIndex interface{}) (string, string, error) {
+	var start string
+	switch value := startIndex.(type) {
+	case string:
+		start = value
+	case float64:
+		if value == float64(int(value)) {
+			start = strconv.Itoa(int(value))
+		}
+	}
+	if _, err := strconv.Atoi(start); err != nil {
+		return "", "", &ASTError{Message: fmt.Sprintf("start_index needs an integer, found %v", startIndex)}
+	}
+	end, ok := endIndex.(string)
+	if !ok || !strings.HasSuffix(end, ".size()") || end == ".size()" {
+		return "", "", &ASTError{Message: fmt.Sprintf("end_index needs the size of an array (ex. a.size()), found %v", endIndex)}
+	}
+	return start, end, nil
+}
+
+func wrapASTError(err error) error {
+	if _, ok := err.(*ASTError); ok {
+		return err
+	}
+	return &ASTError{Message: err.Error()}
+}
+
+// literalValueType returns the type of a literal value of a leaf
+func literalValueType(value interface{}) string {
+	switch value.(type) {
+	case int:
+		return intValueType
+	case float64:
+		return floatValueType
+	case string:
+		return stringValueType
+	case bool:
+		return boolValueType
+	}
+	return ""
+}
+
+// unmarshalLiteral parses the value of a leaf. Without the value type (ex. JSON
+// written by hand) numbers are typed the same as the parser does
+func unmarshalLiteral(data json.RawMessage, valueType string) (interface{}, error) {
+	if isNull(data) {
+		return nil, nil
+	}
+	decoder := json.NewDecoder(bytes.NewReader(data))
+	decoder.UseNumber()
+	var value interface{}
+	if err := decoder.Decode(&value); err != nil {
+		return nil, &ASTError{Message: err.Error()}
+	}
+	if number, ok := value.(json.Number); ok {
+		switch valueType {
+		case floatValueType:
+			return number.Float64()
+		case intValueType:
+			intValue, err := number.Int64()
+			return int(intValue), err
+		}
+		return StringToInterface(number.String()), nil
+	}
+	switch value.(type) {
+	case string, bool:
+		return value, nil
+	}
+	return nil, &ASTError{Message: fmt.Sprintf("unsupported value %s", string(data))}
+}
+
+type scalarConditionJSON ScalarCondition
+
+// MarshalJSON returns the JSON of the condition along with the type of the value
+func (_c *ScalarCondition) MarshalJSON() ([]byte, error) {
+	return json.Marshal(&struct {
+		*scalarConditionJSON
+		ValueType string `json:"value_type,omitempty"`
+	}{(*scalarConditionJSON)(_c), literalValueType(_c.Value)})
+}
+
+// UnmarshalJSON parses the JSON of the condition
+func (_c *ScalarCondition) UnmarshalJSON(data []byte) error {
+	var node struct {
+		Type          ConditionType   `json:"type"`
+		Operator      Operator        `json:"optor"`
+		Operand1      json.RawMessage `json:"o1"`
+		Operand2      json.RawMessage `json:"o2"`
+		Value         json.RawMessage `json:"value"`
+		ValueType     string          `json:"value_type"`
+		HasArrayIndex bool            `json:"has_array_index"`
+	}
+	if err := json.Unmarshal(data, &node); err != nil {
+		return &ASTError{Message: err.Error()}
+	}
+	var err error
+	_c.Type, _c.Operator, _c.HasArrayIndex = node.Type, node.Operator, node.HasArrayIndex
+	if _c.Operator == "" {
+		_c.Operator = NilOperator
+	}
+	if !astOperators[_c.Operator] {
+		return &ASTError{Message: fmt.Sprintf("unsupported operator %s", _c.Operator)}
+	}
+	if _c.Operand1, err = unmarshalConditionNode(node.Operand1); err != nil {
+		return err
+	}
+	if _c.Operand2, err = unmarshalConditionNode(node.Operand2); err != nil {
+		return err
+	}
+	if _c.Value, err = unmarshalLiteral(node.Value, node.ValueType); err != nil {
+		return err
+	}
+	if _c.Operator == NilOperator {
+		if _c.Value == nil {
+			return &ASTError{Message: "a leaf needs a value"}
+		}
+		if key, ok := _c.Value.(string); ok && hasArrayIndex(key) {
+			_c.HasArrayIndex = true
+		}
+	} else if _c.Operand1 == nil || _c.Operand2 == nil {
+		return &ASTError{Message: fmt.Sprintf("operator %s needs two operands", _c.Operator)}
+	}
+	return nil
+}
+
+// UnmarshalJSON parses the JSON of the condition
+func (_c *VectorCondition) UnmarshalJSON(data []byte) error {
+	var node struct {
+		Type       ConditionType   `json:"type"`
+		Operator   Operator        `json:"optor"`
+		SCondition json.RawMessage `json:"scalar_condition"`
+		StartIndex interface{}     `json:"start_index"`
+		EndIndex   interface{}     `json:"end_index"`
+		IndexKey   string          `json:"index_key"`
+	}
+	if err := json.Unmarshal(data, &node); err != nil {
+		return &ASTError{Message: err.Error()}
+	}
+	start, end, err := unmarshalIndexes(node.StartIndex, node.EndIndex)
+	if err != nil {
+		return err
+	}
+	_c.Type, _c.Operator, _c.IndexKey = node.Type, node.Operator, node.IndexKey
+	_c.StartIndex, _c.EndIndex = start, end
+	if _c.SCondition, err = unmarshalConditionNode(node.SCondition); err != nil {
+		return err
+	}
+	switch _c.SCondition.(type) {
+	case *ScalarCondition, *LogicalCondition:
+	default:
+		return &ASTError{Message: "vector condition needs a scalar condition"}
+	}
+	return nil
+}
+
+// UnmarshalJSON parses the JSON of the condition
+func (_c *LogicalCondition) UnmarshalJSON(data []byte) error {
+	var node struct {
+		Type     ConditionType     `json:"type"`
+		Operator Operator          `json:"optor"`
+		Operands []json.RawMessage `json:"operands"`
+	}
+	if err := json.Unmarshal(data, &node); err != nil {
+		return &ASTError{Message: err.Error()}
+	}
+	if node.Operator != AndOperator && node.Operator != OrOperator {
+		return &ASTError{Message: fmt.Sprintf("operator %s is not a logical operator", node.Operator)}
+	}
+	_c.Type, _c.Operator, _c.Operands = node.Type, node.Operator, make([]Condition, len(node.Operands))
+	for i, operand := range node.Operands {
+		condition, err := unmarshalConditionNode(operand)
+		if err != nil {
+			return err
+		}
+		if condition == nil {
+			return &ASTError{Message: fmt.Sprintf("operator %s needs non null operands", _c.Operator)}
+		}
+		_c.Operands[i] = condition
+	}
+	return nil
+}
+
+// UnmarshalJSON parses the JSON of the rule
+func (_fgr *ScalarRule) UnmarshalJSON(data []byte) error {
+	var node struct {
+		Type     RuleType          `json:"type"`
+		If       json.RawMessage   `json:"if"`
+		Then     []json.RawMessage `json:"then"`
+		Metadata RuleMetadata      `json:"metadata"`
+	}
+	if err := json.Unmarshal(data, &node); err != nil {
+		return &ASTError{Message: err.Error()}
+	}
+	var err error
+	_fgr.Type, _fgr.Metadata = node.Type, node.Metadata
+	if _fgr.If, err = unmarshalConditionNode(node.If); err != nil {
+		return err
+	}
+	if _fgr.If == nil {
+		return &ASTError{Message: "rule needs a condition"}
+	}
+	for _, actionJSON := range node.Then {
+		action, err := unmarshalActionNode(actionJSON)
+		if err != nil {
+			return err
+		}
+		_fgr.Then = append(_fgr.Then, action)
+	}
+	return nil
+}
+
+// UnmarshalJSON parses the JSON of the rule
+func (_fgr *VectorRule) UnmarshalJSON(data []byte) error {
+	var node struct {
+		Type       RuleType        `json:"type"`
+		SRule      json.RawMessage `json:"scalar_rule"`
+		StartIndex interface{}     `json:"start_index"`
+		EndIndex   interface{}     `json:"end_index"`
+		IndexKey   interface{}     `json:"index_key"`
+		Metadata   RuleMetadata    `json:"metadata"`
+	}
+	if err := json.Unmarshal(data, &node); err != nil {
+		return &ASTError{Message: err.Error()}
+	}
+	start, end, err := unmarshalIndexes(node.StartIndex, node.EndIndex)
+	if err != nil {
+		return err
+	}
+	_fgr.Type, _fgr.Metadata = node.Type, node.Metadata
+	_fgr.StartIndex, _fgr.EndIndex, _fgr.IndexKey = start, end, node.IndexKey
+	if isNull(node.SRule) {
+		return &ASTError{Message: "vector rule needs a scalar rule"}
+	}
+	scalarRule := &ScalarRule{}
+	if err := json.Unmarshal(node.SRule, scalarRule); err != nil {
+		return wrapASTError(err)
+	}
+	_fgr.SRule = scalarRule
+	return nil
+}
+
+// UnmarshalJSON parses the JSON of the action
+func (_a *AssignAction) UnmarshalJSON(data []byte) error {
+	var node struct {
+		Type  ActionType      `json:"type"`
+		Path  string          `json:"path"`
+		Value json.RawMessage `json:"value"`
+	}
+	if err := json.Unmarshal(data, &node); err != nil {
+		return &ASTError{Message: err.Error()}
+	}
+	var err error
+	_a.Type, _a.Path = node.Type, node.Path
+	if _a.Value, err = unmarshalConditionNode(node.Value); err != nil {
+		return err
+	}
+	if _a.Path == "" || _a.Value == nil {
+		return &ASTError{Message: "assign action needs a path and a value"}
+	}
+	return nil
+}
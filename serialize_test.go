@@ -0,0 +1,155 @@
+// File: serialize_test.go
+// Tests for the JSON format of the parsed rules
+package gorule
+
+import (
+	"encoding/json"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+var serializeTestRules = []string{
+	"IF: { a == 10 && c == true }",
+	"RULE \"typed\" PRIORITY 3 TAGS [a, b] DESCRIPTION \"All types\": IF: { a == 10.0 || b >= 5.9 || c == \"FOO BAR\" || d == false }",
+	"IF: { FOR: i=0:domino.size() { domino[i].type == 10 && domino[i].dpEnabled == true } } THEN: { }",
+	"FOR: i=0:domino.size() IF: { domino[i].type == 1 } THEN: { domino[i].flagged = true ; tier = \"GOLD\" }",
+	"RULE \"gold\": IF: { amount >= 10000 } THEN: { tier = \"GOLD\"; bonus = amount }",
+	"RULE \"score\": IF: { amount > 0 } THEN: { score = amount * 0.01 + bonus / ( 2 - rate ); bucket = \"HIGH\" }",
+}
+
+func TestRuleJSONRoundTrip(t *testing.T) {
+	for _, src := range serializeTestRules {
+		rule, err := NewRuleParser(src).ParseRule()
+		assert.Nil(t, err)
+		data, err := MarshalRule(rule)
+		assert.Nil(t, err)
+		unmarshalledRule, err := UnmarshalRule(data)
+		assert.Nil(t, err, src)
+		assert.Equal(t, rule, unmarshalledRule, src)
+		dataAgain, err := MarshalRule(unmarshalledRule)
+		assert.Nil(t, err)
+		assert.JSONEq(t, string(data), string(dataAgain))
+	}
+}
+
+func TestRuleJSONKeepsLiteralTypes(t *testing.T) {
+	rule, err := NewRuleParser("IF: { a == 10.0 }").ParseRule()
+	assert.Nil(t, err)
+	data, err := MarshalRule(rule)
+	assert.Nil(t, err)
+	unmarshalledRule, err := UnmarshalRule(data)
+	assert.Nil(t, err)
+	literal := unmarshalledRule.(*ScalarRule).If.(*ScalarCondition).GetOperand2().GetValue()
+	assert.Equal(t, float64(10), literal)
+	result, err := NewRuleEngine().Evaluate(unmarshalledRule, []byte(`{ "a": 10.0 }`))
+	assert.Nil(t, err)
+	assert.Equal(t, []bool{true}, result)
+}
+
+func TestRuleJSONHandWritten(t *testing.T) {
+	// JSON produced by a UI builder, without the value types
+	rule, err := UnmarshalRule([]byte(`
+	{
+		"version": 1,
+		"rule": {
+			"type": 1,
+			"metadata": { "name": "high_value" },
+			"if": {
+				"type": 1, "optor": ">=",
+				"o1": { "type": 1, "value": "amount" },
+				"o2": { "type": 1, "value": 10000 }
+			}
+		}
+	}
+	`))
+	assert.Nil(t, err)
+	assert.Equal(t, "high_value", rule.GetMetadata().GetName())
+	result, err := NewRuleEngine().Evaluate(rule, []byte(`{ "amount": 10000 }`))
+	assert.Nil(t, err)
+	assert.Equal(t, []bool{true}, result)
+}
+
+func TestRuleJSONErrors(t *testing.T) {
+	_, err := UnmarshalRule([]byte(`{ "version": 2, "rule": { "type": 1 } }`))
+	assert.IsType(t, &ASTError{}, err)
+	_, err = UnmarshalRule([]byte(`{ "version": 1 }`))
+	assert.IsType(t, &ASTError{}, err)
+	_, err = UnmarshalRule([]byte(`{ "version": 1, "rule": { "type": 9 } }`))
+	assert.IsType(t, &ASTError{}, err)
+	_, err = UnmarshalRule([]byte(`{ "version": 1, "rule": { "type": 1 } }`))
+	assert.IsType(t, &ASTError{}, err)
+	_, err = UnmarshalRule([]byte(`{ "version": 1, "rule": { "type": 1, "if": { "type": 1, "optor": "==", "o1": { "type": 1, "value": "a" } } } }`))
+	assert.IsType(t, &ASTError{}, err)
+	_, err = UnmarshalRule([]byte(`{ "version": 1, "rule": { "type": 1, "if": { "type": 1, "value": [1] } } }`))
+	assert.IsType(t, &ASTError{}, err)
+	_, err = UnmarshalRule([]byte(`{ "version": 1, "rule": { "type": 1, "if": { "type": 1, "value": true }, "then": [ { "type": 5 } ] } }`))
+	assert.IsType(t, &ASTError{}, err)
+}
+
+func TestRuleJSONVectorIndexes(t *testing.T) {
+	vector := func(startIndex string, endIndex string) []byte {
+		return []byte(`{ "version": 1, "rule": { "type": 1, "if": { "type": 2, "optor": "&&", "index_key": "i",
+			"start_index": ` + startIndex + `, "end_index": ` + endIndex + `,
+			"scalar_condition": { "type": 1, "optor": ">", "o1": { "type": 1, "value": "items[i]" }, "o2": { "type": 1, "value": 10 } } } } }`)
+	}
+	// JSON integers are taken as the indexes the parser builds
+	rule, err := UnmarshalRule(vector(`0`, `"items.size()"`))
+	assert.Nil(t, err)
+	vectorCondition := rule.(*ScalarRule).If.(*VectorCondition)
+	assert.Equal(t, "0", vectorCondition.StartIndex)
+	assert.Equal(t, "items.size()", vectorCondition.EndIndex)
+	result, err := NewRuleEngine().Evaluate(rule, []byte(`{ "items": [20, 30] }`))
+	assert.Nil(t, err)
+	assert.Equal(t, []bool{true}, result)
+	for _, indexes := range [][]string{{`"x"`, `"items.size()"`}, {`0.5`, `"items.size()"`}, {`null`, `"items.size()"`}, {`0`, `null`}, {`0`, `true`}, {`0`, `"5"`}, {`0`, `5`}, {`0`, `".size()"`}} {
+		_, err = UnmarshalRule(vector(indexes[0], indexes[1]))
+		assert.IsType(t, &ASTError{}, err, indexes)
+	}
+	_, err = UnmarshalRule([]byte(`{ "version": 1, "rule": { "type": 2, "start_index": 0.5, "end_index": "items.size()", "index_key": "i",
+		"scalar_rule": { "type": 1, "if": { "type": 1, "value": true } } } }`))
+	assert.IsType(t, &ASTError{}, err)
+}
+
+func TestRuleJSONOperators(t *testing.T) {
+	// != is not an operator of the rules, it is not read as an always false condition
+	_, err := UnmarshalRule([]byte(`{ "version": 1, "rule": { "type": 1, "if": { "type": 1, "optor": "!=",
+		"o1": { "type": 1, "value": "a" }, "o2": { "type": 1, "value": 1 } } } }`))
+	assert.IsType(t, &ASTError{}, err)
+	_, err = UnmarshalRule([]byte(`{ "version": 1, "rule": { "type": 1, "if": { "type": 1, "optor": "NIL" } } }`))
+	assert.IsType(t, &ASTError{}, err)
+	_, err = UnmarshalRule([]byte(`{ "version": 1, "rule": { "type": 1, "if": { "type": 1, "optor": "==",
+		"o1": { "type": 1, "value": "a" }, "o2": { "type": 1, "value": null } } } }`))
+	assert.IsType(t, &ASTError{}, err)
+	rule, err := UnmarshalRule([]byte(`{ "version": 1, "rule": { "type": 1, "if": { "type": 1, "optor": "||",
+		"o1": { "type": 1, "optor": "<", "o1": { "type": 1, "value": "a" }, "o2": { "type": 1, "value": 1 } },
+		"o2": { "type": 1, "optor": "==", "o1": { "type": 1, "value": "b" }, "o2": { "type": 1, "value": true } } } } }`))
+	assert.Nil(t, err)
+	result, err := NewRuleEngine().Evaluate(rule, []byte(`{ "a": 0, "b": false }`))
+	assert.Nil(t, err)
+	assert.Equal(t, []bool{true}, result)
+}
+
+func TestRuleSetJSONRoundTrip(t *testing.T) {
+	ruleSet, err := NewRuleParser(testPricingRules).ParseRuleSet()
+	assert.Nil(t, err)
+	data, err := MarshalRuleSet(ruleSet)
+	assert.Nil(t, err)
+	var document map[string]interface{}
+	assert.Nil(t, json.Unmarshal(data, &document))
+	assert.Equal(t, float64(ASTVersion), document["version"])
+	unmarshalledRuleSet, err := UnmarshalRuleSet(data)
+	assert.Nil(t, err)
+	assert.Equal(t, ruleSet, unmarshalledRuleSet)
+}
+
+func TestRuleSetJSONNameMismatch(t *testing.T) {
+	rule, err := NewRuleParser("RULE \"a\": IF: { a == 1 }").ParseRule()
+	assert.Nil(t, err)
+	ruleSet := NewRuleSet()
+	assert.Nil(t, ruleSet.Add("a", rule))
+	// Renamed after it was added
+	rule.GetMetadata().Name = "b"
+	_, err = MarshalRuleSet(ruleSet)
+	assert.IsType(t, &ASTError{}, err)
+}
@@ -0,0 +1,327 @@
+// File: sql.go
+// Exports the conditions of the rules as parameterized SQL WHERE clauses
+package gorule
+
+import (
+	"fmt"
+	"regexp"
+	"strings"
+)
+
+// SQLDialect represents how the fields of the rules are read in SQL
+type SQLDialect int
+
+const (
+	// JSONBDialect reads the fields from a PostgreSQL JSONB column holding the
+	// payload (ex. (data #>> '{amount}')::numeric > $1)
+	JSONBDialect SQLDialect = iota
+	// ColumnsDialect reads the fields from flat columns (ex. amount > $1)
+	ColumnsDialect
+)
+
+// SQLPlaceholder represents the style of the placeholders of the arguments
+type SQLPlaceholder int
+
+const (
+	// DollarPlaceholder numbers the placeholders (ex. $1, $2)
+	DollarPlaceholder SQLPlaceholder = iota
+	// QuestionPlaceholder uses ? for every placeholder
+	QuestionPlaceholder
+)
+
+// defaultJSONBColumn is the JSONB column of the payload unless set
+const defaultJSONBColumn = "data"
+
+// sqlPathPart matches the parts of a path which are safe in a PostgreSQL path
+var sqlPathPart = regexp.MustCompile(`^[A-Za-z0-9_]+$`)
+
+// sqlOperators maps the operators of the rules to SQL
+var sqlOperators = map[Operator]string{
+	AndOperator: "AND", OrOperator: "OR", EqualOperator: "=",
+	GreaterOperator: ">", GreaterThanOrEqualOperator: ">=", LesserOperator: "<", LesserThanOrEqualOperator: "<=",
+}
+
+// SQLExportError raised when a condition can not be expressed in SQL
+type SQLExportError struct {
+	Rule       string
+	Expression string
+	Message    string
+}
+
+func (_rt *SQLExportError) Error() string {
+	if _rt.Rule == "" {
+		return fmt.Sprintf("SQL export error : %s in %s", _rt.Message, _rt.Expression)
+	}
+	return fmt.Sprintf("SQL export error in rule %s : %s in %s", _rt.Rule, _rt.Message, _rt.Expression)
+}
+
+// SQLOptions represents the options of the SQL export
+type SQLOptions struct {
+	Dialect SQLDialect
+	// Column is the JSONB column holding the payload (data if empty)
+	Column string
+	// Columns maps the paths of the fields to their column (ex. txn.amount to
+	// amount) for the columns dialect. Fields which are not mapped are an error
+	Columns     map[string]string
+	Placeholder SQLPlaceholder
+}
+
+// SQLWhere represents a parameterized WHERE clause
+type SQLWhere struct {
+	// Clause is the condition of the WHERE clause, without the WHERE keyword
+	Clause string `json:"clause"`
+	// Args holds the values of the placeholders in order
+	Args []interface{} `json:"args"`
+}
+
+// sqlLoop represents the FOR the condition being exported is in
+type sqlLoop struct {
+	indexKey string
+	// alias is the alias of the elements of the array in the sub query
+	alias string
+}
+
+// sqlExporter builds the WHERE clause of one rule
+type sqlExporter struct {
+	opts  SQLOptions
+	rule  string
+	args  []interface{}
+	loops []sqlLoop
+}
+
+// ExportSQL translates the condition of the rule into a WHERE clause selecting
+// the rows the rule matches. The condition is simplified first (see
+// OptimizeCondition). The fields are typed after the literals they are
+// compared with (ex. ::numeric for a number). A FOR is a NOT EXISTS sub query
+// over the elements of the array and a vector rule an EXISTS one, both only
+// in the JSONB dialect. gjson paths and comparisons which are always false in
+// the rules (ex. ordering strings) are an error
+func ExportSQL(rule Rule, opts SQLOptions) (*SQLWhere, error) {
+	if opts.Column == "" {
+		opts.Column = defaultJSONBColumn
+	}
+	exporter := &sqlExporter{opts: opts, rule: rule.GetMetadata().GetName(), args: []interface{}{}}
+	clause, err := exporter.exportRule(rule)
+	if err != nil {
+		return nil, err
+	}
+	return &SQLWhere{Clause: clause, Args: exporter.args}, nil
+}
+
+func (_se *sqlExporter) exportRule(rule Rule) (string, error) {
+	switch fgRule := rule.(type) {
+	case *CompiledRule:
+		return _se.exportRule(fgRule.GetRule())
+	case *ScalarRule:
+		return _se.export(OptimizeCondition(fgRule.If), false)
+	case *VectorRule:
+		// The rule matches if any element matches
+		from, err := _se.enterLoop(fmt.Sprint(fgRule.IndexKey), fgRule.StartIndex, fgRule.EndIndex, Format(fgRule))
+		if err != nil {
+			return "", err
+		}
+		inner, err := _se.exportRule(fgRule.SRule)
+		if err != nil {
+			return "", err
+		}
+		_se.exitLoop()
+		return fmt.Sprintf("EXISTS (%s AND (%s))", from, inner), nil
+	}
+	return "", _se.errorf(nil, "unsupported rule %T", rule)
+}
+
+// export returns the SQL of a condition evaluating to bool. Chains are grouped
+// in ( ) if group is set
+func (_se *sqlExporter) export(condition Condition, group bool) (string, error) {
+	switch cond := condition.(type) {
+	case *VectorCondition:
+		// Every element matches, the ones failing to evaluate (NULL) do not
+		from, err := _se.enterLoop(cond.IndexKey, cond.StartIndex, cond.EndIndex, FormatCondition(cond))
+		if err != nil {
+			return "", err
+		}
+		inner, err := _se.export(cond.SCondition, false)
+		if err != nil {
+			return "", err
+		}
+		_se.exitLoop()
+		return fmt.Sprintf("NOT EXISTS (%s AND NOT COALESCE(%s, FALSE))", from, inner), nil
+	case *LogicalCondition:
+		return _se.exportChain(cond.GetOperator(), cond.GetOperands(), group)
+	case *ScalarCondition:
+		optor := cond.GetOperator()
+		switch optor {
+		case NilOperator:
+			if value, ok := literalBool(cond); ok {
+				return strings.ToUpper(fmt.Sprint(value)), nil
+			}
+			// A field holding a bool (ex. a && b)
+			return _se.value(cond, booleanType)
+		case AndOperator, OrOperator:
+			return _se.exportChain(optor, []Condition{cond.GetOperand1(), cond.GetOperand2()}, group)
+		}
+		return _se.exportComparison(cond)
+	}
+	return "", _se.errorf(condition, "unsupported condition %T", condition)
+}
+
+func (_se *sqlExporter) exportChain(optor Operator, operands []Condition, group bool) (string, error) {
+	texts := make([]string, len(operands))
+	for i, operand := range operands {
+		text, err := _se.export(operand, true)
+		if err != nil {
+			return "", err
+		}
+		texts[i] = text
+	}
+	text := strings.Join(texts, fmt.Sprintf(" %s ", sqlOperators[optor]))
+	if group {
+		return fmt.Sprintf("(%s)", text), nil
+	}
+	return text, nil
+}
+
+func (_se *sqlExporter) exportComparison(cond *ScalarCondition) (string, error) {
+	operand1, operand2 := cond.GetOperand1(), cond.GetOperand2()
+	types1, types2 := _se.operandType(operand1), _se.operandType(operand2)
+	types := types1
+	switch {
+	case types1 == anyType:
+		types = types2
+	case types2 != anyType && types1 != types2:
+		return "", _se.errorf(cond, "operands of different types (%s and %s)", types1, types2)
+	}
+	if (types == stringType || types == booleanType) && cond.GetOperator() != EqualOperator {
+		return "", _se.errorf(cond, "%s on %s is always false", cond.GetOperator(), types)
+	}
+	value1, err := _se.value(operand1, types)
+	if err != nil {
+		return "", err
+	}
+	value2, err := _se.value(operand2, types)
+	if err != nil {
+		return "", err
+	}
+	return fmt.Sprintf("%s %s %s", value1, sqlOperators[cond.GetOperator()], value2), nil
+}
+
+// operandType returns the type of a literal or predicate operand, anyType for a
+// field whose type is unknown
+func (_se *sqlExporter) operandType(operand Condition) valueType {
+	if isPredicate(operand) {
+		return booleanType
+	}
+	if !isLiteral(operand) {
+		return anyType
+	}
+	types := literalType(operand.GetValue())
+	if types&numberType != 0 {
+		return numberType
+	}
+	return types
+}
+
+// value returns the SQL of an operand. Literals are arguments, fields are read
+// as types (anyType for a comparison of two fields)
+func (_se *sqlExporter) value(operand Condition, types valueType) (string, error) {
+	if isPredicate(operand) {
+		return _se.export(operand, true)
+	}
+	if isLiteral(operand) {
+		_se.args = append(_se.args, literalValue(operand.GetValue()))
+		if _se.opts.Placeholder == QuestionPlaceholder {
+			return "?", nil
+		}
+		return fmt.Sprintf("$%d", len(_se.args)), nil
+	}
+	path, _ := leafPath(operand)
+	if isGJSONPath(path) {
+		return "", _se.errorf(operand, "gjson path %s", path)
+	}
+	if _se.opts.Dialect == ColumnsDialect {
+		column, ok := _se.opts.Columns[path]
+		if !ok {
+			return "", _se.errorf(operand, "no column for %s", path)
+		}
+		return column, nil
+	}
+	base, parts, err := _se.jsonPath(operand, path)
+	if err != nil {
+		return "", err
+	}
+	switch types {
+	case numberType:
+		return fmt.Sprintf("(%s #>> '{%s}')::numeric", base, strings.Join(parts, ",")), nil
+	case booleanType:
+		return fmt.Sprintf("(%s #>> '{%s}')::boolean", base, strings.Join(parts, ",")), nil
+	case stringType:
+		return fmt.Sprintf("%s #>> '{%s}'", base, strings.Join(parts, ",")), nil
+	}
+	// Two fields are compared as JSONB which orders numbers as numbers
+	return fmt.Sprintf("%s #> '{%s}'", base, strings.Join(parts, ",")), nil
+}
+
+// jsonPath returns the JSONB value a path is read from and the parts of the
+// path in it. Fields indexed by a FOR are read from the element of the array
+func (_se *sqlExporter) jsonPath(operand Condition, path string) (string, []string, error) {
+	base := _se.opts.Column
+	if operand.(*ScalarCondition).HasArrayIndex {
+		open := strings.Index(path, "[")
+		closing := strings.Index(path, "]")
+		loop, ok := _se.loop(path[open+1 : closing])
+		if !ok {
+			return "", nil, _se.errorf(operand, "%s is not indexed by a FOR", path)
+		}
+		base = loop.alias + ".value"
+		path = strings.TrimPrefix(path[closing+1:], ".")
+	}
+	if path == "" {
+		return base, nil, nil
+	}
+	parts := strings.Split(path, ".")
+	for _, part := range parts {
+		if !sqlPathPart.MatchString(part) {
+			return "", nil, _se.errorf(operand, "unsupported path %s", path)
+		}
+	}
+	return base, parts, nil
+}
+
+// enterLoop returns the sub query over the elements of the array of a FOR
+// range (ex. 0:items.size()) without its closing parenthesis
+func (_se *sqlExporter) enterLoop(indexKey string, startIndex interface{}, endIndex interface{}, expression string) (string, error) {
+	if _se.opts.Dialect == ColumnsDialect {
+		return "", &SQLExportError{Rule: _se.rule, Expression: expression, Message: "FOR on flat columns"}
+	}
+	start, isInt := StringToInterface(fmt.Sprint(startIndex)).(int)
+	arrayPath, isSize := strings.CutSuffix(fmt.Sprint(endIndex), ".size()")
+	if !isInt || !isSize {
+		return "", &SQLExportError{Rule: _se.rule, Expression: expression, Message: "range is not a start index up to the size of an array"}
+	}
+	array, err := _se.value(&ScalarCondition{Type: ScalarConditionType, Operator: NilOperator, Value: arrayPath, HasArrayIndex: hasArrayIndex(arrayPath)}, anyType)
+	if err != nil {
+		return "", err
+	}
+	alias := fmt.Sprintf("%s_%d", indexKey, len(_se.loops))
+	_se.loops = append(_se.loops, sqlLoop{indexKey: indexKey, alias: alias})
+	return fmt.Sprintf("SELECT 1 FROM jsonb_array_elements(%s) WITH ORDINALITY AS %s(value, ordinality) WHERE %s.ordinality > %d",
+		array, alias, alias, start), nil
+}
+
+func (_se *sqlExporter) exitLoop() {
+	_se.loops = _se.loops[:len(_se.loops)-1]
+}
+
+// loop returns the innermost FOR of the index
+func (_se *sqlExporter) loop(indexKey string) (sqlLoop, bool) {
+	for i := len(_se.loops) - 1; i >= 0; i-- {
+		if _se.loops[i].indexKey == indexKey {
+			return _se.loops[i], true
+		}
+	}
+	return sqlLoop{}, false
+}
+
+func (_se *sqlExporter) errorf(condition Condition, format string, args ...interface{}) error {
+	return &SQLExportError{Rule: _se.rule, Expression: FormatCondition(condition), Message: fmt.Sprintf(format, args...)}
+}
@@ -0,0 +1,78 @@
+// File: sql_test.go
+// Tests for exporting the rules as SQL WHERE clauses
+package gorule
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestExportSQL(t *testing.T) {
+	tests := []struct {
+		src    string
+		clause string
+		args   []interface{}
+	}{
+		{`IF: { amount > 100 }`, `(data #>> '{amount}')::numeric > $1`, []interface{}{100}},
+		{`IF: { amount >= 10.5 && ( type == "CC" || card.verified == true ) }`,
+			`(data #>> '{amount}')::numeric >= $1 AND (data #>> '{type}' = $2 OR (data #>> '{card,verified}')::boolean = $3)`,
+			[]interface{}{10.5, "CC", true}},
+		{`IF: { 100 < amount && 1 == 1 }`, `$1 < (data #>> '{amount}')::numeric`, []interface{}{100}},
+		{`IF: { amount > limit }`, `data #> '{amount}' > data #> '{limit}'`, []interface{}{}},
+		{`IF: { blocked && vip }`, `(data #>> '{blocked}')::boolean AND (data #>> '{vip}')::boolean`, []interface{}{}},
+		{`IF: { 1 > 2 || false }`, `FALSE`, []interface{}{}},
+		{`IF: { FOR: i=1:items.size() { items[i].price > 10 && items[i].tags.0 == "x" } }`,
+			`NOT EXISTS (SELECT 1 FROM jsonb_array_elements(data #> '{items}') WITH ORDINALITY AS i_0(value, ordinality) WHERE i_0.ordinality > 1 ` +
+				`AND NOT COALESCE((i_0.value #>> '{price}')::numeric > $1 AND i_0.value #>> '{tags,0}' = $2, FALSE))`,
+			[]interface{}{10, "x"}},
+		{`FOR: i=0:items.size() IF: { items[i] == "x" || a.b == c }`,
+			`EXISTS (SELECT 1 FROM jsonb_array_elements(data #> '{items}') WITH ORDINALITY AS i_0(value, ordinality) WHERE i_0.ordinality > 0 ` +
+				`AND (i_0.value #>> '{}' = $1 OR data #> '{a,b}' = data #> '{c}'))`,
+			[]interface{}{"x"}},
+	}
+	for _, test := range tests {
+		rule, err := NewRuleParser(test.src).ParseRule()
+		assert.Nil(t, err, test.src)
+		where, err := ExportSQL(rule, SQLOptions{})
+		assert.Nil(t, err, test.src)
+		assert.Equal(t, test.clause, where.Clause, test.src)
+		assert.Equal(t, test.args, where.Args, test.src)
+	}
+}
+
+func TestExportSQLColumns(t *testing.T) {
+	rule, err := NewRuleParser(`RULE "r": IF: { txn.amount > 100 && ( txn.type == "CC" || blocked ) }`).ParseRule()
+	assert.Nil(t, err)
+	opts := SQLOptions{Dialect: ColumnsDialect, Placeholder: QuestionPlaceholder,
+		Columns: map[string]string{"txn.amount": "amount", "txn.type": "t.type", "blocked": "is_blocked"}}
+	where, err := ExportSQL(rule, opts)
+	assert.Nil(t, err)
+	assert.Equal(t, "amount > ? AND (t.type = ? OR is_blocked)", where.Clause)
+	assert.Equal(t, []interface{}{100, "CC"}, where.Args)
+
+	delete(opts.Columns, "blocked")
+	_, err = ExportSQL(rule, opts)
+	assert.EqualError(t, err, "SQL export error in rule r : no column for blocked in blocked")
+
+	rule, err = NewRuleParser(`IF: { FOR: i=0:items.size() { items[i] > 1 } }`).ParseRule()
+	assert.Nil(t, err)
+	_, err = ExportSQL(rule, opts)
+	assert.EqualError(t, err, "SQL export error : FOR on flat columns in FOR: i=0:items.size() { items[i] > 1 }")
+}
+
+func TestExportSQLErrors(t *testing.T) {
+	tests := map[string]string{
+		`IF: { type > "CC" }`:                    `SQL export error : > on string is always false in type > "CC"`,
+		`IF: { items.# > 1 }`:                    `SQL export error : gjson path items.# in items.#`,
+		`IF: { a == 1 && ( b == 2 ) == "x" }`:    `SQL export error : operands of different types (boolean and string) in ( b == 2 ) == "x"`,
+		`IF: { a-b.c == 1 }`:                     `SQL export error : unsupported path a-b.c in a-b.c`,
+		`IF: { FOR: i=0:a.size() { b[j] > 1 } }`: `SQL export error : b[j] is not indexed by a FOR in b[j]`,
+	}
+	for src, expected := range tests {
+		rule, err := NewRuleParser(src).ParseRule()
+		assert.Nil(t, err, src)
+		_, err = ExportSQL(rule, SQLOptions{})
+		assert.EqualError(t, err, expected, src)
+	}
+}
@@ -0,0 +1,180 @@
+// File: strategy.go
+// Implements salience ordering and conflict resolution of rule sets
+package gorule
+
+import (
+	"fmt"
+	"sort"
+)
+
+// ConflictStrategy represents how the winners are picked when multiple rules of
+// a rule set match
+type ConflictStrategy int
+
+const (
+	// FirstMatchStrategy stops at the first matching rule in salience order
+	FirstMatchStrategy ConflictStrategy = 1
+	// AllMatchesStrategy evaluates every rule and returns all the matching rules
+	AllMatchesStrategy ConflictStrategy = 2
+	// HighestPriorityStrategy returns all the matching rules having the highest
+	// priority among the matching rules
+	HighestPriorityStrategy ConflictStrategy = 3
+	// StopOnActionStrategy returns the matching rules in salience order up to
+	// (and including) the first matching rule which has an action
+	StopOnActionStrategy ConflictStrategy = 4
+)
+
+// UnsupportedStrategyError raised when the conflict strategy is unknown
+type UnsupportedStrategyError struct {
+	Strategy ConflictStrategy
+}
+
+func (_rt *UnsupportedStrategyError) Error() string {
+	return fmt.Sprintf("Unsupported conflict strategy : %d", _rt.Strategy)
+}
+
+// strategyNames maps the names of the strategies used in the tools and the test
+// fixtures to the strategies
+var strategyNames = map[string]ConflictStrategy{
+	"first":    FirstMatchStrategy,
+	"all":      AllMatchesStrategy,
+	"priority": HighestPriorityStrategy,
+	"action":   StopOnActionStrategy,
+}
+
+// UnknownStrategyError raised when the name of the conflict strategy is unknown
+type UnknownStrategyError struct {
+	Name string
+}
+
+func (_rt *UnknownStrategyError) Error() string {
+	return fmt.Sprintf("Unknown conflict strategy : %s (expecting first, all, priority or action)", _rt.Name)
+}
+
+// ParseConflictStrategy returns the strategy of the name: first, all, priority
+// or action
+func ParseConflictStrategy(name string) (ConflictStrategy, error) {
+	strategy, ok := strategyNames[name]
+	if !ok {
+		return 0, &UnknownStrategyError{Name: name}
+	}
+	return strategy, nil
+}
+
+// RuleSetResult represents the outcome of evaluating a rule set with a strategy
+type RuleSetResult struct {
+	// Winners holds the names of the winning rules in salience order
+	Winners []string `json:"winners"`
+	// Results holds the result of every evaluated rule keyed by rule name
+	Results map[string]interface{} `json:"results"`
+	// Outputs holds the outputs of the winning rules having actions keyed by
+	// rule name (see EvaluateOutputs)
+	Outputs map[string]*RuleOutput `json:"outputs,omitempty"`
+}
+
+// SalienceOrder returns the names of the rules ordered by priority (highest
+// first). Rules with the same priority keep the order they were added in
+func (_rs *RuleSet) SalienceOrder() []string {
+	names := _rs.Names()
+	sort.SliceStable(names, func(i, j int) bool {
+		return _rs.rules[names[i]].GetMetadata().GetPriority() > _rs.rules[names[j]].GetMetadata().GetPriority()
+	})
+	return names
+}
+
+// isMatch checks if the evaluation result of a rule is a match. A vector rule
+// matches if the rule matches for any of the index
+func isMatch(result interface{}) bool {
+	switch res := result.(type) {
+	case bool:
+		return res
+	case []bool:
+		for _, value := range res {
+			if value {
+				return true
+			}
+		}
+	}
+	return false
+}
+
+// hasAction checks if the rule defines any action
+func hasAction(rule Rule) bool {
+	switch fgRule := rule.(type) {
+	case *ScalarRule:
+		return len(fgRule.Then) > 0
+	case *VectorRule:
+		return hasAction(fgRule.SRule)
+	case *CompiledRule:
+		return hasAction(fgRule.GetRule())
+	}
+	return false
+}
+
+// EvaluateRuleSetWithStrategy evaluates the rules of the rule set in salience
+// order for the given jsonData and picks the winners using the strategy
+// args:
+//
+//	ruleSet: The rules to evaluate
+//	jsonData: The data to be used during evaluation
+//	strategy: The conflict resolution strategy
+//
+// Return
+//
+//	RuleSetResult: Winning rules and the result of the evaluated rules
+//	error: Any error during evaluation
+func (_re *RuleEngine) EvaluateRuleSetWithStrategy(ruleSet *RuleSet, jsonData []byte, strategy ConflictStrategy) (*RuleSetResult, error) {
+	return _re.EvaluateRuleSetDocumentWithStrategy(ruleSet, ParseDocument(jsonData), strategy)
+}
+
+// EvaluateRuleSetDocumentWithStrategy evaluates the rules of the rule set in
+// salience order for an already parsed document and picks the winners using
+// the strategy
+func (_re *RuleEngine) EvaluateRuleSetDocumentWithStrategy(ruleSet *RuleSet, doc *Document, strategy ConflictStrategy) (*RuleSetResult, error) {
+	switch strategy {
+	case FirstMatchStrategy, AllMatchesStrategy, HighestPriorityStrategy, StopOnActionStrategy:
+	default:
+		return nil, &UnsupportedStrategyError{Strategy: strategy}
+	}
+	ctx := _re.buildContext(doc)
+	ruleSetResult := &RuleSetResult{Winners: []string{}, Results: make(map[string]interface{})}
+	for _, name := range ruleSet.SalienceOrder() {
+		rule := ruleSet.rules[name]
+		if strategy == HighestPriorityStrategy && len(ruleSetResult.Winners) > 0 {
+			winner := ruleSet.rules[ruleSetResult.Winners[0]]
+			if rule.GetMetadata().GetPriority() < winner.GetMetadata().GetPriority() {
+				// Rest of the rules have lower priority than the winners
+				break
+			}
+		}
+		result, err := rule.Evaluate(ctx)
+		if err != nil {
+			return nil, &RuleEvaluationError{Name: name, Err: err}
+		}
+		ruleSetResult.Results[name] = result
+		if !isMatch(result) {
+			continue
+		}
+		ruleSetResult.Winners = append(ruleSetResult.Winners, name)
+		if strategy == FirstMatchStrategy || (strategy == StopOnActionStrategy && hasAction(rule)) {
+			break
+		}
+	}
+	for _, name := range ruleSetResult.Winners {
+		rule := ruleSet.rules[name]
+		if !hasAction(rule) {
+			continue
+		}
+		// The outputs are computed apart so that they are not read by the
+		// conditions of the other rules
+		output, err := _re.EvaluateDocumentOutputs(rule, doc)
+		if err != nil {
+			return nil, &RuleEvaluationError{Name: name, Err: err}
+		}
+		if ruleSetResult.Outputs == nil {
+			ruleSetResult.Outputs = make(map[string]*RuleOutput)
+		}
+		ruleSetResult.Outputs[name] = output
+	}
+	return ruleSetResult, nil
+}
@@ -0,0 +1,88 @@
+// File: strategy_test.go
+// Tests for conflict resolution strategies
+package gorule
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+const testPricingRules = `
+RULE "bronze" PRIORITY 1: IF: { amount >= 100 }
+RULE "gold" PRIORITY 10: IF: { amount >= 10000 }
+RULE "silver" PRIORITY 5: IF: { amount >= 1000 }
+RULE "silver_cc" PRIORITY 5: IF: { type == "CREDIT_CARD" }
+RULE "platinum" PRIORITY 20: IF: { amount >= 100000 }
+`
+
+func testStrategy(t *testing.T, strategy ConflictStrategy) *RuleSetResult {
+	ruleSet, err := NewRuleParser(testPricingRules).ParseRuleSet()
+	assert.Nil(t, err)
+	result, err := NewRuleEngine().EvaluateRuleSetWithStrategy(ruleSet, []byte(`{ "amount": 5000, "type": "CREDIT_CARD" }`), strategy)
+	assert.Nil(t, err)
+	return result
+}
+
+func TestSalienceOrder(t *testing.T) {
+	ruleSet, err := NewRuleParser(testPricingRules).ParseRuleSet()
+	assert.Nil(t, err)
+	assert.Equal(t, []string{"platinum", "gold", "silver", "silver_cc", "bronze"}, ruleSet.SalienceOrder())
+}
+
+func TestFirstMatchStrategy(t *testing.T) {
+	result := testStrategy(t, FirstMatchStrategy)
+	assert.Equal(t, []string{"silver"}, result.Winners)
+	// Rules after the first match are never evaluated
+	assert.Equal(t, 3, len(result.Results))
+}
+
+func TestAllMatchesStrategy(t *testing.T) {
+	result := testStrategy(t, AllMatchesStrategy)
+	assert.Equal(t, []string{"silver", "silver_cc", "bronze"}, result.Winners)
+	assert.Equal(t, 5, len(result.Results))
+}
+
+func TestHighestPriorityStrategy(t *testing.T) {
+	result := testStrategy(t, HighestPriorityStrategy)
+	assert.Equal(t, []string{"silver", "silver_cc"}, result.Winners)
+	assert.Equal(t, 4, len(result.Results))
+}
+
+func TestStopOnActionStrategy(t *testing.T) {
+	ruleSet, err := NewRuleParser(`
+RULE "bronze" PRIORITY 1: IF: { amount >= 100 } THEN: { discount = 1 }
+RULE "silver" PRIORITY 5: IF: { amount >= 1000 }
+RULE "silver_cc" PRIORITY 5: IF: { type == "CREDIT_CARD" } THEN: { discount = 10 }
+RULE "gold" PRIORITY 10: IF: { amount >= 10000 } THEN: { discount = 20 }
+`).ParseRuleSet()
+	assert.Nil(t, err)
+	// The evaluation stops at the first winner with actions
+	result, err := NewRuleEngine().EvaluateRuleSetWithStrategy(ruleSet, []byte(`{ "amount": 5000, "type": "CREDIT_CARD" }`), StopOnActionStrategy)
+	assert.Nil(t, err)
+	assert.Equal(t, []string{"silver", "silver_cc"}, result.Winners)
+	assert.Equal(t, 3, len(result.Results))
+	assert.Equal(t, map[string]*RuleOutput{"silver_cc": {Matched: true, Outputs: map[string]interface{}{"discount": 10}}}, result.Outputs)
+}
+
+func TestUnsupportedStrategy(t *testing.T) {
+	ruleSet := NewRuleSet()
+	_, err := NewRuleEngine().EvaluateRuleSetWithStrategy(ruleSet, []byte(`{}`), ConflictStrategy(100))
+	assert.IsType(t, &UnsupportedStrategyError{}, err)
+}
+
+func TestNoMatch(t *testing.T) {
+	ruleSet, err := NewRuleParser(testPricingRules).ParseRuleSet()
+	assert.Nil(t, err)
+	result, err := NewRuleEngine().EvaluateRuleSetWithStrategy(ruleSet, []byte(`{ "amount": 1, "type": "CASH" }`), FirstMatchStrategy)
+	assert.Nil(t, err)
+	assert.Equal(t, []string{}, result.Winners)
+}
+
+func TestParseConflictStrategy(t *testing.T) {
+	strategy, err := ParseConflictStrategy("priority")
+	assert.Nil(t, err)
+	assert.Equal(t, HighestPriorityStrategy, strategy)
+	_, err = ParseConflictStrategy("best")
+	assert.IsType(t, &UnknownStrategyError{}, err)
+}
@@ -0,0 +1,196 @@
+// File: trace.go
+// Implements the explain trace recording how a rule was evaluated
+package gorule
+
+import (
+	"fmt"
+	"strings"
+)
+
+// TraceStep represents the evaluation of one condition of the rule
+type TraceStep struct {
+	Condition  Condition   `json:"-"`
+	Expression string      `json:"expression"`
+	Result     interface{} `json:"result"`
+	// Field is the resolved path (ex. a.0.b for a[i].b) if the condition is a field
+	Field string `json:"field,omitempty"`
+	// Missing is set if the field is not present in the input
+	Missing bool `json:"missing,omitempty"`
+	// Iteration is the value of the FOR index the step was evaluated for
+	Iteration *int `json:"iteration,omitempty"`
+	// ShortCircuit is set if the second operand was not evaluated
+	ShortCircuit bool         `json:"short_circuit,omitempty"`
+	Steps        []*TraceStep `json:"steps,omitempty"`
+}
+
+// FieldValue represents a field resolved from the input (or the working memory)
+// while evaluating the rule
+type FieldValue struct {
+	Path    string      `json:"path"`
+	Value   interface{} `json:"value"`
+	Missing bool        `json:"missing,omitempty"`
+}
+
+// Explanation represents the result of a rule along with how it was reached
+type Explanation struct {
+	Result interface{} `json:"result"`
+	// Steps holds the evaluation tree of the condition, one per iteration of a
+	// vector rule
+	Steps []*TraceStep `json:"steps"`
+	// Fields holds the resolved fields in the order they were first resolved
+	Fields []FieldValue `json:"fields"`
+}
+
+// tracer records the trace steps while the conditions are evaluated. It is
+// attached to the RuleContext only while explaining, so the regular evaluation
+// pays a type assertion per condition
+type tracer struct {
+	stack  []*TraceStep
+	steps  []*TraceStep
+	fields []FieldValue
+	seen   map[string]bool
+}
+
+func newTracer() *tracer {
+	return &tracer{seen: make(map[string]bool)}
+}
+
+// getTracer returns the tracer attached to the context (if any)
+func getTracer(ctx Context) (*tracer, bool) {
+	ruleCtx, ok := ctx.(*RuleContext)
+	if !ok || ruleCtx.tracer == nil {
+		return nil, false
+	}
+	return ruleCtx.tracer, true
+}
+
+// enter starts the step of the condition
+func (_t *tracer) enter(cond Condition, ctx Context) *TraceStep {
+	step := &TraceStep{Condition: cond, Expression: FormatCondition(cond)}
+	var parent *TraceStep
+	if len(_t.stack) > 0 {
+		parent = _t.stack[len(_t.stack)-1]
+	}
+	if _, isVector := cond.(*VectorCondition); !isVector && ctx.KeyExists(IndexCurrentValue) {
+		// Steps directly under a loop are evaluated once per index
+		if _, inLoop := parentCondition(parent).(*VectorCondition); inLoop || parent == nil {
+			iteration, _ := ctx.GetValue(IndexCurrentValue).(int)
+			step.Iteration = &iteration
+		}
+	}
+	if parent == nil {
+		_t.steps = append(_t.steps, step)
+	} else {
+		parent.Steps = append(parent.Steps, step)
+	}
+	_t.stack = append(_t.stack, step)
+	return step
+}
+
+// exit completes the step with the result of the condition
+func (_t *tracer) exit(step *TraceStep, result interface{}, ctx Context) {
+	_t.stack = _t.stack[:len(_t.stack)-1]
+	step.Result = result
+	if logicalCond, ok := step.Condition.(*LogicalCondition); ok {
+		step.ShortCircuit = len(step.Steps) < len(logicalCond.GetOperands())
+		return
+	}
+	cond, ok := step.Condition.(*ScalarCondition)
+	if !ok {
+		return
+	}
+	if optor := cond.GetOperator(); optor == AndOperator || optor == OrOperator {
+		step.ShortCircuit = len(step.Steps) == 1
+		return
+	}
+	key, ok := cond.GetValue().(string)
+	if cond.GetOperator() != NilOperator || !ok || strings.HasPrefix(key, "\"") {
+		// Only the fields are recorded, not the literals
+		return
+	}
+	step.Field = cond.getContextKey(ctx)
+	step.Missing = result == step.Field
+	if !_t.seen[step.Field] {
+		_t.seen[step.Field] = true
+		_t.fields = append(_t.fields, FieldValue{Path: step.Field, Value: result, Missing: step.Missing})
+	}
+}
+
+func parentCondition(step *TraceStep) Condition {
+	if step == nil {
+		return nil
+	}
+	return step.Condition
+}
+
+// Explain evaluates the rule for the given jsonData and records how the result
+// was reached
+// args:
+//
+//	fgRule: The rule to evaluate
+//	jsonData: The data to be used during evaluation
+//
+// Return
+//
+//	*Explanation: Result along with the evaluation tree and the resolved fields
+//	error: Any error during evaluation
+func (_re *RuleEngine) Explain(fgRule Rule, jsonData []byte) (*Explanation, error) {
+	return _re.ExplainDocument(fgRule, ParseDocument(jsonData))
+}
+
+// ExplainDocument explains the rule for an already parsed document
+func (_re *RuleEngine) ExplainDocument(fgRule Rule, doc *Document) (*Explanation, error) {
+	if compiledRule, ok := fgRule.(*CompiledRule); ok {
+		// Closures do not record the steps, trace the rule they were compiled from
+		fgRule = compiledRule.GetRule()
+	}
+	ctx := _re.buildContext(doc)
+	tr := newTracer()
+	ctx.(*RuleContext).tracer = tr
+	result, err := fgRule.Evaluate(ctx)
+	if err != nil {
+		return nil, err
+	}
+	return &Explanation{Result: result, Steps: tr.steps, Fields: tr.fields}, nil
+}
+
+// String returns the evaluation tree as indented text. Literals are omitted
+//
+//	[true]
+//	true  amount > 100 && type == "CC"
+//	  true  amount > 100
+//	    200  amount
+func (_e *Explanation) String() string {
+	var sb strings.Builder
+	fmt.Fprintf(&sb, "%v\n", _e.Result)
+	for _, step := range _e.Steps {
+		writeTraceStep(&sb, step, 0)
+	}
+	return sb.String()
+}
+
+func writeTraceStep(sb *strings.Builder, step *TraceStep, depth int) {
+	if cond, ok := step.Condition.(*ScalarCondition); ok && cond.GetOperator() == NilOperator && step.Field == "" {
+		return
+	}
+	sb.WriteString(strings.Repeat("  ", depth))
+	if step.Iteration != nil {
+		fmt.Fprintf(sb, "[%d] ", *step.Iteration)
+	}
+	switch {
+	case step.Missing:
+		fmt.Fprintf(sb, "<missing>  %s", step.Expression)
+	default:
+		fmt.Fprintf(sb, "%v  %s", step.Result, step.Expression)
+	}
+	if step.Field != "" && step.Field != step.Expression {
+		fmt.Fprintf(sb, " (%s)", step.Field)
+	}
+	if step.ShortCircuit {
+		sb.WriteString(" (short-circuit)")
+	}
+	sb.WriteString("\n")
+	for _, child := range step.Steps {
+		writeTraceStep(sb, child, depth+1)
+	}
+}
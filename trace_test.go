@@ -0,0 +1,71 @@
+// File: trace_test.go
+package gorule
+
+import (
+	"encoding/json"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+const traceData = `{ "amount": 200, "type": "CC", "items": [ { "price": 30 }, { "price": 5 } ] }`
+
+func TestExplainScalarRule(t *testing.T) {
+	rule, err := NewRuleParser(`IF: { amount > 100 && ( type == "CC" || country == "IN" ) }`).ParseRule()
+	assert.Nil(t, err)
+	explanation, err := NewRuleEngine().Explain(rule, []byte(traceData))
+	assert.Nil(t, err)
+	assert.Equal(t, []bool{true}, explanation.Result)
+	assert.Equal(t, `[true]
+true  amount > 100 && ( type == "CC" || country == "IN" )
+  true  amount > 100
+    200  amount
+  true  type == "CC" || country == "IN" (short-circuit)
+    true  type == "CC"
+      "CC"  type
+`, explanation.String())
+	// country is never resolved due to the short circuit
+	assert.Equal(t, []FieldValue{{Path: "amount", Value: 200}, {Path: "type", Value: "\"CC\""}}, explanation.Fields)
+}
+
+func TestExplainMissingField(t *testing.T) {
+	rule, err := NewRuleParser(`IF: { country == "IN" }`).ParseRule()
+	assert.Nil(t, err)
+	explanation, err := NewRuleEngine().Explain(rule, []byte(traceData))
+	assert.Nil(t, err)
+	assert.Equal(t, []FieldValue{{Path: "country", Value: "country", Missing: true}}, explanation.Fields)
+	assert.Equal(t, "[false]\nfalse  country == \"IN\"\n  <missing>  country\n", explanation.String())
+}
+
+func TestExplainVectorRule(t *testing.T) {
+	rule, err := NewRuleParser(`FOR: i=0:items.size() IF: { items[i].price > 10 }`).ParseRule()
+	assert.Nil(t, err)
+	compiledRule, err := CompileRule(rule)
+	assert.Nil(t, err)
+	// Compiled rules are explained with the rule they were compiled from
+	explanation, err := NewRuleEngine().Explain(compiledRule, []byte(traceData))
+	assert.Nil(t, err)
+	assert.Equal(t, []bool{true, false}, explanation.Result)
+	assert.Equal(t, `[true false]
+[0] true  items[i].price > 10
+  30  items[i].price (items.0.price)
+[1] false  items[i].price > 10
+  5  items[i].price (items.1.price)
+`, explanation.String())
+	data, err := json.Marshal(explanation.Steps[1])
+	assert.Nil(t, err)
+	assert.JSONEq(t, `{ "expression": "items[i].price > 10", "result": false, "iteration": 1, "steps": [
+		{ "expression": "items[i].price", "result": 5, "field": "items.1.price" },
+		{ "expression": "10", "result": 10 } ] }`, string(data))
+}
+
+func TestExplainVectorCondition(t *testing.T) {
+	rule, err := NewRuleParser(`IF: { FOR: i=0:items.size() { items[i].price > 10 } }`).ParseRule()
+	assert.Nil(t, err)
+	explanation, err := NewRuleEngine().Explain(rule, []byte(traceData))
+	assert.Nil(t, err)
+	assert.Equal(t, []bool{false}, explanation.Result)
+	assert.Len(t, explanation.Steps, 1)
+	assert.Len(t, explanation.Steps[0].Steps, 2)
+	assert.Equal(t, 1, *explanation.Steps[0].Steps[1].Iteration)
+}